@@ -0,0 +1,38 @@
+// Package api holds the OpenAPI specification of the HTTP API. The typed
+// Go client generated from it lives in api/client.
+package api
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+//go:embed openapi.yaml
+var spec []byte
+
+// Load parses the specification and checks that it is a valid OpenAPI 3
+// document.
+func Load() (*openapi3.T, error) {
+	const op = "api.Load"
+
+	doc, err := openapi3.NewLoader().LoadFromData(spec)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := doc.Validate(context.Background()); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return doc, nil
+}
+
+// JSON returns the specification as JSON, the way /openapi.json serves it.
+func JSON() ([]byte, error) {
+	doc, err := Load()
+	if err != nil {
+		return nil, err
+	}
+	return doc.MarshalJSON()
+}
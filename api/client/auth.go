@@ -0,0 +1,22 @@
+package client
+
+import (
+	"context"
+	"net/http"
+)
+
+// WithAPIKey authenticates every request with a static key in X-API-Key.
+func WithAPIKey(key string) ClientOption {
+	return WithRequestEditorFn(func(_ context.Context, req *http.Request) error {
+		req.Header.Set("X-API-Key", key)
+		return nil
+	})
+}
+
+// WithBearerToken authenticates every request with a JWT.
+func WithBearerToken(token string) ClientOption {
+	return WithRequestEditorFn(func(_ context.Context, req *http.Request) error {
+		req.Header.Set("Authorization", "Bearer "+token)
+		return nil
+	})
+}
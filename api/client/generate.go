@@ -0,0 +1,5 @@
+// Package client is a typed client of the order service HTTP API, generated
+// from api/openapi.yaml. After changing the specification run make client.
+package client
+
+//go:generate oapi-codegen -config oapi-codegen.yaml ../openapi.yaml
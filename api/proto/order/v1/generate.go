@@ -0,0 +1,5 @@
+// Package orderv1 is the gRPC API of the order service, generated from
+// order.proto. After changing it run make proto.
+package orderv1
+
+//go:generate buf generate ../.. --template ../../buf.gen.yaml -o ../..
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/Egor-Pomidor-pdf/order-service/internal/app"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/config"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/db"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/export"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order/repository"
+)
+
+// runExport writes orders to a file or stdout:
+//
+//	order-service export -format parquet -set items -from 2025-01-01 -to 2025-02-01 -out jan.parquet
+func runExport(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	format := fs.String("format", export.FormatCSV, "csv, jsonl or parquet")
+	set := fs.String("set", export.DefaultSet, "column set: orders, payments or items")
+	columns := fs.String("columns", "", "comma separated columns, overrides -set")
+	from := fs.String("from", "", "orders created at or after (YYYY-MM-DD or RFC 3339)")
+	to := fs.String("to", "", "orders created before (YYYY-MM-DD or RFC 3339)")
+	customer := fs.String("customer", "", "only orders of this customer_id")
+	out := fs.String("out", "-", "output file, - for stdout")
+	batchSize := fs.Int("batch-size", 500, "orders fetched from the cursor at a time")
+	includePII := fs.Bool("include-pii", false, "write delivery name, phone, email and address unmasked")
+	fs.Parse(args)
+
+	filter := repository.OrderFilter{CustomerID: *customer}
+	var err error
+	if *from != "" {
+		if filter.From, err = export.ParseTime(*from); err != nil {
+			return fmt.Errorf("invalid -from: %w", err)
+		}
+	}
+	if *to != "" {
+		if filter.To, err = export.ParseTime(*to); err != nil {
+			return fmt.Errorf("invalid -to: %w", err)
+		}
+	}
+	req, err := export.NewRequest(*format, *set, *columns, filter)
+	if err != nil {
+		return err
+	}
+	req.IncludePII = *includePII
+
+	database, err := db.NewPostgresDB(cfg.Database)
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	orderRepo, err := app.NewOrderRepository(cfg, database)
+	if err != nil {
+		return err
+	}
+	defer orderRepo.Close()
+
+	var w io.Writer = os.Stdout
+	var f *os.File
+	if *out != "-" {
+		if f, err = os.Create(*out); err != nil {
+			return err
+		}
+		w = f
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	rows, err := export.NewExporter(orderRepo, *batchSize).Export(ctx, w, req)
+	if f != nil {
+		// ошибка Close может означать, что файл записан не полностью
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(*out)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	// stdout может быть занят самой выгрузкой
+	fmt.Fprintf(os.Stderr, "exported %d rows\n", rows)
+	return nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/Egor-Pomidor-pdf/order-service/internal/app"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/config"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/db"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/importer"
+)
+
+// runImport loads orders from a JSON array, JSONL or CSV file. An interrupted
+// import continues from its checkpoint when run again:
+//
+//	order-service import -batch-size 1000 orders.jsonl
+func runImport(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	format := fs.String("format", "", "json, jsonl or csv, by default guessed from the extension")
+	rejectPath := fs.String("rejects", "", "reject file, default <file>.rejects.jsonl")
+	checkpointPath := fs.String("checkpoint", "", "checkpoint file, default <file>.checkpoint")
+	batchSize := fs.Int("batch-size", 500, "orders written in one transaction")
+	restart := fs.Bool("restart", false, "ignore the checkpoint and truncate the reject file")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: import [flags] <file>")
+	}
+	path, err := filepath.Abs(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if *format == "" {
+		if *format, err = importer.FormatFromPath(path); err != nil {
+			return err
+		}
+	}
+	if *rejectPath == "" {
+		*rejectPath = path + ".rejects.jsonl"
+	}
+	if *checkpointPath == "" {
+		*checkpointPath = path + ".checkpoint"
+	}
+
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	cp := importer.Checkpoint{File: path, Size: info.Size()}
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if !*restart {
+		saved, err := importer.LoadCheckpoint(*checkpointPath)
+		if err != nil {
+			return fmt.Errorf("load checkpoint: %w", err)
+		}
+		if saved.File != "" {
+			if saved.File != cp.File || saved.Size != cp.Size {
+				return fmt.Errorf("checkpoint %s belongs to another version of the input, use -restart", *checkpointPath)
+			}
+			cp = saved
+			// отклонённые записи до checkpoint уже в файле
+			flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+		}
+	}
+
+	rejects, err := os.OpenFile(*rejectPath, flags, 0o600)
+	if err != nil {
+		return err
+	}
+	defer rejects.Close()
+
+	database, err := db.NewPostgresDB(cfg.Database)
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	orderRepo, err := app.NewOrderRepository(cfg, database)
+	if err != nil {
+		return err
+	}
+	defer orderRepo.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	save := func(cp importer.Checkpoint) error {
+		// checkpoint не должен опережать файл отказов
+		if err := rejects.Sync(); err != nil {
+			return err
+		}
+		return importer.SaveCheckpoint(*checkpointPath, cp)
+	}
+	stats, err := importer.NewImporter(orderRepo, *batchSize).Import(ctx, in, *format, rejects, cp, save)
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(stats)
+	return err
+}
@@ -2,8 +2,8 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
-	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
@@ -11,13 +11,9 @@ import (
 
 	"log/slog"
 
+	"github.com/Egor-Pomidor-pdf/order-service/internal/app"
 	"github.com/Egor-Pomidor-pdf/order-service/internal/config"
-	"github.com/Egor-Pomidor-pdf/order-service/internal/db"
-	"github.com/Egor-Pomidor-pdf/order-service/internal/kafka"
-	"github.com/Egor-Pomidor-pdf/order-service/internal/order/handler"
-	"github.com/Egor-Pomidor-pdf/order-service/internal/order/repository"
-	"github.com/Egor-Pomidor-pdf/order-service/internal/order/service"
-	"github.com/Egor-Pomidor-pdf/order-service/internal/server"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/redact"
 	"github.com/joho/godotenv"
 )
 
@@ -33,71 +29,62 @@ func main() {
 	cfg := config.MustLoad()
     setupLogger(cfg.Env) 
 
+    // Административные команды: order-service <command> [flags]
+    if len(os.Args) > 1 {
+        if err := runCommand(cfg, os.Args[1], os.Args[2:]); err != nil {
+            log.Fatalf("%s: %v", os.Args[1], err)
+        }
+        return
+    }
+
 	slog.Info("starting app", slog.String("env", cfg.Env))
 	slog.Debug("debug messages are enabled")
 
-
-    // Подключение к базе данных
-	db, err := db.NewPostgresDB(cfg.Database)
-	if err != nil {
-		log.Fatalf("failed to connect to database: %v", err)
-	}
-	// Проверка подключения
-	if err := db.Ping(); err != nil {
-		log.Fatalf("database ping failed: %v", err)
-	}
-	log.Println("Successfully connected to PostgreSQL")
-
-    // Инициализация репозиториев, сервисов и HTTP сервера
-	orderRepo := repository.NewOrderRepository(db)
-	orderService := service.NewOrderService(orderRepo)
-    orderHandler := handler.NewOrderHandler(orderService)
-    srv := server.NewServer(cfg.Server, orderService)
-
-    // Запуск HTTP сервера в отдельной горутине
-    go func() {
-        slog.Info("starting HTTP server", slog.String("address", cfg.Server.Address))
-        if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-            slog.Error("failed to start server", slog.String("error", err.Error()))
-            os.Exit(1)
-        }
-    }()
-    
-    // Инициализация и запуск Kafka consumer
-    consumer, err := kafka.NewConsumer(orderHandler, cfg.Kafka)
+    // Подключение к базам данных, инициализация репозиториев, сервисов, HTTP сервера и consumer
+    application, err := app.New(cfg)
     if err != nil {
-        slog.Error("failed to create Kafka consumer", slog.String("error", err.Error()))
-        return
+        log.Fatalf("failed to create app: %v", err)
+    }
+    if err := application.Start(); err != nil {
+        log.Fatalf("failed to start app: %v", err)
     }
-    go consumer.Start()
 
     // Ожидание сигнала завершения (например, SIGINT или SIGTERM)
     sigChan := make(chan os.Signal, 1)
     signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-    <-sigChan
-
-    slog.Info("received shutdown signal, shutting down...")
-
-    // Остановка Kafka consumer
-    if err := consumer.Stop(); err != nil {
-        slog.Error("failed to stop Kafka consumer", slog.String("error", err.Error()))
+    select {
+    case <-sigChan:
+        slog.Info("received shutdown signal, shutting down...")
+    case err := <-application.Failed():
+        slog.Error("app failed, shutting down...", slog.String("error", err.Error()))
     }
 
-    // Завершение работы HTTP сервера
     ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-    if err := srv.Shutdown(ctx); err != nil {
-        slog.Error("failed to shutdown server", slog.String("error", err.Error()))
-    }
     defer cancel()
-
-    // Закрытие подключения к базе данных
-    if err := db.Close();err != nil {
-        slog.Error("failed to close database", slog.String("error", err.Error()))
+    if err := application.Shutdown(ctx); err != nil {
+        slog.Error("failed to shut down cleanly", slog.String("error", err.Error()))
     }
-    
     slog.Info("shutting down application")
 }
 
+// runCommand dispatches administrative subcommands
+func runCommand(cfg *config.Config, name string, args []string) error {
+    switch name {
+    case "replay":
+        return runReplay(cfg, args)
+    case "retention":
+        return runRetention(cfg, args)
+    case "rebalance":
+        return runRebalance(cfg, args)
+    case "export":
+        return runExport(cfg, args)
+    case "import":
+        return runImport(cfg, args)
+    default:
+        return fmt.Errorf("unknown command %q", name)
+    }
+}
+
 // setupLogger configures the global logger based on the environment
 func setupLogger(env string) {
     var handler slog.Handler
@@ -109,5 +96,5 @@ func setupLogger(env string) {
     case envProd:
         handler = slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo})
     }
-    slog.SetDefault(slog.New(handler))  // ← Устанавливаем global logger
+    slog.SetDefault(slog.New(redact.NewLogHandler(handler)))  // ← Устанавливаем global logger, PII вычищаются из логов
 }
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/Egor-Pomidor-pdf/order-service/internal/app"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/config"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/db"
+)
+
+// runRebalance moves orders to the shard their ShardKey maps to and prints
+// the result. Stop the consumer first, running replicas keep their cache:
+//
+//	order-service rebalance -dry-run
+func runRebalance(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("rebalance", flag.ExitOnError)
+	batchSize := fs.Int("batch-size", 500, "orders read from a shard at a time")
+	dryRun := fs.Bool("dry-run", false, "only count orders that would be moved")
+	fs.Parse(args)
+
+	database, err := db.NewPostgresDB(cfg.Database)
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	orderRepo, err := app.NewOrderRepository(cfg, database)
+	if err != nil {
+		return err
+	}
+	defer orderRepo.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	stats, err := orderRepo.Rebalance(ctx, *batchSize, *dryRun)
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(stats)
+	return err
+}
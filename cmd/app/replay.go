@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Egor-Pomidor-pdf/order-service/internal/app"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/config"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/db"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/kafka"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order/handler"
+)
+
+// runReplay reprocesses a range of the orders topic and prints the report:
+//
+//	order-service replay -since 2024-05-01T00:00:00Z -dry-run
+//	order-service replay -offsets 0:1200,1:980
+func runReplay(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	since := fs.String("since", "", "RFC3339 timestamp to replay from (partitions without -offsets)")
+	offsets := fs.String("offsets", "", "start offsets per partition, e.g. 0:1200,1:980")
+	dryRun := fs.Bool("dry-run", false, "validate and count messages without writing orders")
+	fs.Parse(args)
+
+	req := kafka.ReplayRequest{DryRun: *dryRun}
+	if *since != "" {
+		t, err := time.Parse(time.RFC3339, *since)
+		if err != nil {
+			return fmt.Errorf("invalid -since: %w", err)
+		}
+		req.Since = t
+	}
+	if *offsets != "" {
+		parsed, err := parseOffsets(*offsets)
+		if err != nil {
+			return fmt.Errorf("invalid -offsets: %w", err)
+		}
+		req.Offsets = parsed
+	}
+
+	database, err := db.NewPostgresDB(cfg.Database)
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	orderRepo, err := app.NewOrderRepository(cfg, database)
+	if err != nil {
+		return err
+	}
+	defer orderRepo.Close()
+	orderService, closeCache := app.NewOrderService(cfg, orderRepo)
+	defer closeCache()
+	replayer := kafka.NewReplayer(handler.NewOrderHandler(orderService), cfg.Kafka)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	report, err := replayer.Replay(ctx, req)
+	if report != nil {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(report)
+	}
+	return err
+}
+
+func parseOffsets(s string) (map[int32]int64, error) {
+	offsets := make(map[int32]int64)
+	for _, pair := range strings.Split(s, ",") {
+		partition, offset, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if !ok {
+			return nil, fmt.Errorf("expected partition:offset, got %q", pair)
+		}
+		p, err := strconv.ParseInt(partition, 10, 32)
+		if err != nil {
+			return nil, err
+		}
+		o, err := strconv.ParseInt(offset, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		offsets[int32(p)] = o
+	}
+	return offsets, nil
+}
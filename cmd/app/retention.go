@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/Egor-Pomidor-pdf/order-service/internal/app"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/config"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/db"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/retention"
+)
+
+// noopEvictor is used by the CLI, which has no cache of its own. Running
+// replicas drop the removed orders on the order_changes notifications the
+// repository sends with every archived batch; only replicas started with
+// CACHE_INVALIDATION=false keep them until CACHE_TTL expires the entry or
+// the process restarts.
+type noopEvictor struct{}
+
+func (noopEvictor) Evict(...string) {}
+
+// runRetention applies the retention policy once and prints the result:
+//
+//	order-service retention -max-age-days 180 -mode export
+func runRetention(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("retention", flag.ExitOnError)
+	maxAge := fs.Int("max-age-days", cfg.Retention.MaxAgeDays, "move orders older than this many days")
+	mode := fs.String("mode", cfg.Retention.Mode, "archive (to archive tables) or export (to JSONL files)")
+	exportDir := fs.String("export-dir", cfg.Retention.ExportDir, "directory for exported files")
+	fs.Parse(args)
+
+	retentionCfg := cfg.Retention
+	retentionCfg.MaxAgeDays = *maxAge
+	retentionCfg.Mode = *mode
+	retentionCfg.ExportDir = *exportDir
+
+	database, err := db.NewPostgresDB(cfg.Database)
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	orderRepo, err := app.NewOrderRepository(cfg, database)
+	if err != nil {
+		return err
+	}
+	defer orderRepo.Close()
+	archiver, err := retention.NewArchiver(retentionCfg, orderRepo, noopEvictor{})
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	stats, err := archiver.RunOnce(ctx)
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(stats)
+	return err
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+)
+
+// latencyReport is the produce-to-visible latency of the sampled orders:
+// from handing the message to the writer until GET /order/{uid} returns it.
+type latencyReport struct {
+	Samples  int     `json:"samples"`
+	Timeouts int     `json:"timeouts"`
+	Skipped  int     `json:"skipped"`
+	P50      float64 `json:"p50_ms"`
+	P90      float64 `json:"p90_ms"`
+	P99      float64 `json:"p99_ms"`
+	Max      float64 `json:"max_ms"`
+}
+
+// poller polls the HTTP API for produced orders until they become visible.
+type poller struct {
+	client   *http.Client
+	baseURL  string
+	apiKey   string
+	interval time.Duration
+	timeout  time.Duration
+	sem      chan struct{}
+	wg       sync.WaitGroup
+
+	mu        sync.Mutex
+	latencies []time.Duration
+	timeouts  int
+	skipped   int
+}
+
+func newPoller(baseURL, apiKey string, interval, timeout time.Duration, concurrency int) *poller {
+	return &poller{
+		client:   &http.Client{Timeout: 5 * time.Second},
+		baseURL:  baseURL,
+		apiKey:   apiKey,
+		interval: interval,
+		timeout:  timeout,
+		sem:      make(chan struct{}, concurrency),
+	}
+}
+
+// track starts polling for uid in the background. When all pollers are busy
+// the sample is skipped, so polling never slows down producing.
+func (p *poller) track(ctx context.Context, uid string, produced time.Time) {
+	select {
+	case p.sem <- struct{}{}:
+	default:
+		p.mu.Lock()
+		p.skipped++
+		p.mu.Unlock()
+		return
+	}
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		defer func() { <-p.sem }()
+
+		ctx, cancel := context.WithDeadline(ctx, produced.Add(p.timeout))
+		defer cancel()
+		for {
+			if p.visible(ctx, uid) {
+				p.mu.Lock()
+				p.latencies = append(p.latencies, time.Since(produced))
+				p.mu.Unlock()
+				return
+			}
+			select {
+			case <-ctx.Done():
+				p.mu.Lock()
+				p.timeouts++
+				p.mu.Unlock()
+				return
+			case <-time.After(p.interval):
+			}
+		}
+	}()
+}
+
+func (p *poller) visible(ctx context.Context, uid string) bool {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.baseURL+"/order/"+url.PathEscape(uid), nil)
+	if err != nil {
+		return false
+	}
+	if p.apiKey != "" {
+		req.Header.Set("X-API-Key", p.apiKey)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode == http.StatusOK
+}
+
+// report waits for the running pollers and summarizes the samples.
+func (p *poller) report() *latencyReport {
+	p.wg.Wait()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	rep := &latencyReport{Samples: len(p.latencies), Timeouts: p.timeouts, Skipped: p.skipped}
+	if len(p.latencies) == 0 {
+		return rep
+	}
+	sort.Slice(p.latencies, func(i, j int) bool { return p.latencies[i] < p.latencies[j] })
+	rep.P50 = millis(percentile(p.latencies, 0.50))
+	rep.P90 = millis(percentile(p.latencies, 0.90))
+	rep.P99 = millis(percentile(p.latencies, 0.99))
+	rep.Max = millis(p.latencies[len(p.latencies)-1])
+	return rep
+}
+
+// percentile uses the nearest-rank method on sorted values.
+func percentile(sorted []time.Duration, q float64) time.Duration {
+	i := int(q*float64(len(sorted))+0.999999) - 1
+	if i < 0 {
+		i = 0
+	}
+	return sorted[i]
+}
+
+func millis(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
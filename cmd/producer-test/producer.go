@@ -1,89 +1,189 @@
+// Command producer-test sends generated orders to Kafka to load-test the
+// consumer and to reproduce dead letter scenarios:
+//
+//	go run ./cmd/producer-test -n 10000 -rate 500 -invalid 0.05 -duplicate 0.02
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
 	"log"
+	"math/rand/v2"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 
-	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order/fixture"
+	kafkago "github.com/segmentio/kafka-go"
 )
 
+// recent is how many sent valid messages are kept to be sent again as duplicates.
+const recent = 1000
+
+type report struct {
+	Sent       int            `json:"sent"`
+	Valid      int            `json:"valid"`
+	Invalid    int            `json:"invalid"`
+	Duplicates int            `json:"duplicates"`
+	Delivered  int            `json:"delivered"`
+	Failed     int            `json:"failed"`
+	Errors     map[string]int `json:"errors,omitempty"`
+	Elapsed    string         `json:"elapsed"`
+	Rate       float64        `json:"messages_per_second"`
+	Latency    *latencyReport `json:"latency,omitempty"`
+}
+
 func main() {
-	p, err := kafka.NewProducer(&kafka.ConfigMap{
-		"bootstrap.servers": "localhost:9093",
+	brokers := flag.String("brokers", "localhost:9093", "comma separated Kafka brokers")
+	topic := flag.String("topic", "orders", "topic to produce to")
+	n := flag.Int("n", 1000, "number of messages")
+	rate := flag.Float64("rate", 0, "target messages per second, 0 for as fast as possible")
+	minItems := flag.Int("min-items", 1, "minimum items per order")
+	maxItems := flag.Int("max-items", 3, "maximum items per order")
+	locales := flag.String("locales", "en,ru", "comma separated locales")
+	currencies := flag.String("currencies", "USD,RUB,EUR", "comma separated currencies")
+	invalidFraction := flag.Float64("invalid", 0, "fraction of messages that fail validation")
+	duplicateFraction := flag.Float64("duplicate", 0, "fraction of messages that repeat an already sent order")
+	seed := flag.Uint64("seed", 0, "random seed, 0 for a random one")
+	batchSize := flag.Int("batch-size", 100, "messages per produce request")
+	interval := flag.Duration("report-interval", 5*time.Second, "progress log interval")
+	pollURL := flag.String("poll-url", "", "service URL, e.g. http://localhost:8080, to measure produce-to-visible latency")
+	pollSample := flag.Float64("poll-sample", 0.1, "fraction of valid orders to poll for")
+	pollInterval := flag.Duration("poll-interval", 50*time.Millisecond, "delay between polls of one order")
+	pollTimeout := flag.Duration("poll-timeout", 30*time.Second, "give up on an order after this long")
+	pollers := flag.Int("pollers", 32, "concurrent pollers, samples are skipped when all are busy")
+	apiKey := flag.String("api-key", "", "X-API-Key with the reader role")
+	flag.Parse()
+
+	if *invalidFraction+*duplicateFraction > 1 {
+		log.Fatal("-invalid and -duplicate must not exceed 1 together")
+	}
+
+	gen := fixture.New(fixture.Options{
+		MinItems:   *minItems,
+		MaxItems:   *maxItems,
+		Locales:    strings.Split(*locales, ","),
+		Currencies: strings.Split(*currencies, ","),
+		Seed:       *seed,
 	})
-	if err != nil {
-		log.Fatalf("failed to create producer: %v", err)
+
+	rep := report{Errors: make(map[string]int)}
+	var mu sync.Mutex
+
+	writer := &kafkago.Writer{
+		Addr:                   kafkago.TCP(strings.Split(*brokers, ",")...),
+		Topic:                  *topic,
+		Balancer:               &kafkago.Hash{},
+		RequiredAcks:           kafkago.RequireAll,
+		AllowAutoTopicCreation: true,
+		BatchSize:              *batchSize,
+		BatchTimeout:           10 * time.Millisecond,
+		Async:                  true,
+		Completion: func(messages []kafkago.Message, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				rep.Failed += len(messages)
+				rep.Errors[err.Error()] += len(messages)
+				return
+			}
+			rep.Delivered += len(messages)
+		},
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	var latency *poller
+	if *pollURL != "" {
+		latency = newPoller(strings.TrimRight(*pollURL, "/"), *apiKey, *pollInterval, *pollTimeout, *pollers)
+	}
+
+	var sent []kafkago.Message
+	var produceErr error
+	start := time.Now()
+	lastReport := start
+	for i := 0; i < *n && ctx.Err() == nil; i++ {
+		if *rate > 0 {
+			// выравниваем по расписанию, а не по паузе после отправки
+			next := start.Add(time.Duration(float64(i) / *rate * float64(time.Second)))
+			if d := time.Until(next); d > 0 {
+				select {
+				case <-time.After(d):
+				case <-ctx.Done():
+				}
+			}
+		}
+
+		var msg kafkago.Message
+		counter := &rep.Valid
+		switch r := gen.Float(); {
+		case r < *invalidFraction:
+			o, data, _ := gen.InvalidMessage()
+			msg = kafkago.Message{Key: []byte(o.OrderUID), Value: data}
+			counter = &rep.Invalid
+		case r < *invalidFraction+*duplicateFraction && len(sent) > 0:
+			msg = sent[int(gen.Float()*float64(len(sent)))]
+			counter = &rep.Duplicates
+		default:
+			o, data := gen.Message()
+			msg = kafkago.Message{Key: []byte(o.OrderUID), Value: data}
+		}
+
+		produced := time.Now()
+		if err := writer.WriteMessages(ctx, msg); err != nil {
+			produceErr = err
+			log.Printf("produce failed: %v", err)
+			break
+		}
+		if counter == &rep.Valid {
+			if latency != nil && rand.Float64() < *pollSample {
+				latency.track(ctx, string(msg.Key), produced)
+			}
+			if len(sent) < recent {
+				sent = append(sent, msg)
+			} else {
+				sent[rep.Valid%recent] = msg
+			}
+		}
+		*counter++
+		rep.Sent++
+
+		if time.Since(lastReport) >= *interval {
+			lastReport = time.Now()
+			mu.Lock()
+			log.Printf("sent %d, delivered %d, failed %d, %.0f msg/s",
+				rep.Sent, rep.Delivered, rep.Failed, float64(rep.Sent)/time.Since(start).Seconds())
+			mu.Unlock()
+		}
 	}
-	defer p.Close()
-
-	topic := "orders"
-
-	message := `{
-   "order_uid": "52",
-   "track_number": "WBILMTESTTRACK",
-   "entry": "WBIL",
-   "delivery": {
-      "name": "Test Testov",
-      "phone": "+9720000000",
-      "zip": "2639809",
-      "city": "Kiryat Mozkin",
-      "address": "Ploshad Mira 15",
-      "region": "Kraiot",
-      "email": "test@gmail.com"
-   },
-   "payment": {
-      "transaction": "b563feb7b2b84b6test",
-      "request_id": "",
-      "currency": "USD",
-      "provider": "wbpay",
-      "amount": 1817,
-      "payment_dt": 1637907727,
-      "bank": "alpha",
-      "delivery_cost": 1500,
-      "goods_total": 317,
-      "custom_fee": 0
-   },
-   "items": [
-      {
-         "chrt_id": 9934930,
-         "track_number": "WBILMTESTTRACK",
-         "price": 453,
-         "rid": "ab4219087a764ae0btest",
-         "name": "Mascaras",
-         "sale": 30,
-         "size": "0",
-         "total_price": 317,
-         "nm_id": 2389212,
-         "brand": "Vivienne Sabo",
-         "status": 202
-      }
-   ],
-   "locale": "en",
-   "internal_signature": "",
-   "customer_id": "test",
-   "delivery_service": "meest",
-   "shardkey": "9",
-   "sm_id": 99,
-   "date_created": "2021-11-26T06:22:19Z",
-   "oof_shard": "1"
-}`
-
-	// Отправляем сообщение
-	err = p.Produce(&kafka.Message{
-		TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kafka.PartitionAny},
-		Value:          []byte(message),
-	}, nil)
-
-	if err != nil {
-		log.Fatalf("failed to produce message: %v", err)
+
+	// Close дожидается подтверждения отправленных сообщений
+	if err := writer.Close(); err != nil {
+		log.Printf("close writer: %v", err)
 	}
 
-	// Ждём подтверждения
-	e := <-p.Events()
-	m, ok := e.(*kafka.Message)
-	if ok && m.TopicPartition.Error != nil {
-		log.Fatalf("delivery failed: %v", m.TopicPartition.Error)
-	} else {
-		log.Printf("delivered message to topic %s [%d] at offset %v",
-			*m.TopicPartition.Topic, m.TopicPartition.Partition, m.TopicPartition.Offset)
+	elapsed := time.Since(start)
+	if latency != nil {
+		rep.Latency = latency.report()
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	rep.Elapsed = elapsed.Round(time.Millisecond).String()
+	rep.Rate = float64(rep.Delivered) / elapsed.Seconds()
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(rep)
+	if rep.Failed > 0 {
+		fmt.Fprintf(os.Stderr, "%d messages were not delivered\n", rep.Failed)
+	}
+	if produceErr != nil || rep.Failed > 0 {
+		os.Exit(1)
 	}
 }
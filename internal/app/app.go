@@ -0,0 +1,348 @@
+// Package app wires the order service together: database, cache, Kafka
+// consumers, HTTP API and background jobs. cmd/app runs it, scenario tests
+// boot it in-process (see apptest).
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+
+	"github.com/Egor-Pomidor-pdf/order-service/internal/auth"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/config"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/db"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/export"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/grpcapi"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/kafka"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order/cachesync"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order/handler"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order/repository"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order/service"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/partition"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/retention"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/server"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/stats"
+	"github.com/jmoiron/sqlx"
+	"google.golang.org/grpc"
+)
+
+// App is a running order service.
+type App struct {
+	cfg *config.Config
+
+	db           *sqlx.DB
+	orderRepo    *repository.OrderRepository
+	orderService *service.OrderService
+	closeCache   func() error
+	statsRepo    *stats.Repository
+	archiver     *retention.Archiver
+
+	srv             *http.Server
+	listener        net.Listener
+	grpcSrv         *grpc.Server
+	grpcListener    net.Listener
+	consumer        *kafka.Consumer
+	erasureConsumer *kafka.Consumer
+
+	stopJobs context.CancelFunc
+	failed   chan error
+}
+
+type options struct {
+	source kafka.MessageSource
+	dlq    kafka.DeadLetterSink
+}
+
+type Option func(*options)
+
+// WithOrderSource makes the order consumer read from source instead of the
+// Kafka topic, e.g. from a kafka.MemorySource.
+func WithOrderSource(source kafka.MessageSource) Option {
+	return func(o *options) {
+		o.source = source
+	}
+}
+
+// WithDeadLetterSink replaces the dead letter topic of the order consumer.
+// Messages are redacted before reaching it if cfg.Kafka.DLQRedact is set.
+func WithDeadLetterSink(sink kafka.DeadLetterSink) Option {
+	return func(o *options) {
+		o.dlq = sink
+	}
+}
+
+// New connects to the databases and builds every component. Nothing runs
+// until Start.
+func New(cfg *config.Config, opts ...Option) (*App, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	a := &App{cfg: cfg, closeCache: func() error { return nil }, failed: make(chan error, 1)}
+	if err := a.build(o); err != nil {
+		a.close()
+		return nil, err
+	}
+	return a, nil
+}
+
+func (a *App) build(o options) error {
+	cfg := a.cfg
+
+	database, err := db.NewPostgresDB(cfg.Database)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	a.db = database
+	if err := a.db.Ping(); err != nil {
+		return fmt.Errorf("database ping failed: %w", err)
+	}
+
+	a.orderRepo, err = NewOrderRepository(cfg, a.db)
+	if err != nil {
+		return fmt.Errorf("failed to create order repository: %w", err)
+	}
+	a.orderService, a.closeCache = NewOrderService(cfg, a.orderRepo)
+	orderHandler := handler.NewOrderHandler(a.orderService)
+
+	authenticator, err := auth.New(cfg.Server.Auth)
+	if err != nil {
+		return fmt.Errorf("failed to configure auth: %w", err)
+	}
+	a.statsRepo = NewStatsRepository(cfg, a.orderRepo)
+	replayer := kafka.NewReplayer(orderHandler, cfg.Kafka)
+	exporter := export.NewExporter(a.orderRepo, 0)
+	a.srv = server.NewServer(cfg.Server, a.orderService, replayer, a.statsRepo, exporter, authenticator)
+	if cfg.GRPC.Address != "" {
+		a.grpcSrv = grpcapi.NewServer(cfg.Server, a.orderService, authenticator)
+	}
+
+	if cfg.Retention.Enabled {
+		a.archiver, err = retention.NewArchiver(cfg.Retention, a.orderRepo, a.orderService)
+		if err != nil {
+			return fmt.Errorf("failed to configure retention: %w", err)
+		}
+	}
+
+	if o.source != nil {
+		a.consumer = kafka.NewConsumerWithSource(orderHandler, o.source).WithRetryBackoff(cfg.Kafka.RetryBackoff)
+		if o.dlq != nil {
+			if cfg.Kafka.DLQRedact {
+				o.dlq = kafka.NewRedactingSink(o.dlq)
+			}
+			a.consumer.WithDeadLetterSink(o.dlq)
+		}
+	} else {
+		a.consumer, err = kafka.NewConsumer(orderHandler, cfg.Kafka)
+		if err != nil {
+			return fmt.Errorf("failed to create Kafka consumer: %w", err)
+		}
+	}
+
+	// Consumer запросов на удаление персональных данных
+	if cfg.Kafka.ErasureTopic != "" {
+		erasureCfg := cfg.Kafka
+		erasureCfg.Topic = cfg.Kafka.ErasureTopic
+		erasureCfg.GroupID = cfg.Kafka.GroupID + "-erasure"
+		erasureCfg.DLQTopic = ""
+		a.erasureConsumer, err = kafka.NewConsumer(handler.NewErasureHandler(a.orderService), erasureCfg)
+		if err != nil {
+			return fmt.Errorf("failed to create erasure consumer: %w", err)
+		}
+	}
+	return nil
+}
+
+// Start starts the HTTP and gRPC servers, the consumers and the background
+// jobs.
+func (a *App) Start() error {
+	listener, err := net.Listen("tcp", a.cfg.Server.Address)
+	if err != nil {
+		return fmt.Errorf("failed to start server: %w", err)
+	}
+	if a.grpcSrv != nil {
+		a.grpcListener, err = net.Listen("tcp", a.cfg.GRPC.Address)
+		if err != nil {
+			listener.Close()
+			return fmt.Errorf("failed to start gRPC server: %w", err)
+		}
+	}
+	a.listener = listener
+
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	a.stopJobs = stopJobs
+
+	// Прогрев кэша в фоне, до его окончания /readyz отвечает 503
+	if err := a.orderService.StartWarmUp(jobsCtx, warmUpConfig(a.cfg.Cache)); err != nil {
+		stopJobs()
+		listener.Close()
+		if a.grpcListener != nil {
+			a.grpcListener.Close()
+			a.grpcListener = nil
+		}
+		a.listener = nil
+		return fmt.Errorf("failed to configure cache warm-up: %w", err)
+	}
+
+	go func() {
+		slog.Info("starting HTTP server", slog.String("address", listener.Addr().String()))
+		if err := a.srv.Serve(listener); err != nil && err != http.ErrServerClosed {
+			a.failed <- fmt.Errorf("HTTP server: %w", err)
+		}
+	}()
+
+	if a.grpcListener != nil {
+		go func() {
+			slog.Info("starting gRPC server", slog.String("address", a.grpcListener.Addr().String()))
+			if err := a.grpcSrv.Serve(a.grpcListener); err != nil {
+				a.failed <- fmt.Errorf("gRPC server: %w", err)
+			}
+		}()
+	}
+
+	go a.consumer.Start()
+	if a.erasureConsumer != nil {
+		go a.erasureConsumer.Start()
+	}
+
+	// Перенос старых заказов в архив по расписанию
+	if a.archiver != nil {
+		go a.archiver.Run(jobsCtx)
+	}
+
+	// Создание секций orders/items на следующие месяцы
+	if a.cfg.Partitions.Enabled {
+		for _, shard := range a.orderRepo.Shards() {
+			go partition.NewManager(shard.DB, a.cfg.Partitions).Run(jobsCtx)
+		}
+	}
+
+	// Обновление материализованных представлений статистики
+	if a.cfg.Stats.UseViews {
+		go a.statsRepo.RunRefresh(jobsCtx, a.cfg.Stats.RefreshInterval)
+	}
+
+	// Периодический снимок кэша в файл
+	if a.cfg.Cache.Snapshot.Path != "" {
+		go a.orderService.RunSnapshots(jobsCtx, a.cfg.Cache.Snapshot.Interval)
+	}
+
+	// Сброс кэша при изменении заказов другими репликами
+	if a.cfg.Cache.Invalidation {
+		go cachesync.NewListener(ChangeSources(a.cfg.Database), a.orderService).Run(jobsCtx)
+	}
+	return nil
+}
+
+// Addr returns the address the HTTP server listens on, nil before Start.
+func (a *App) Addr() net.Addr {
+	if a.listener == nil {
+		return nil
+	}
+	return a.listener.Addr()
+}
+
+// GRPCAddr returns the address the gRPC server listens on, nil before Start
+// or when it is disabled.
+func (a *App) GRPCAddr() net.Addr {
+	if a.grpcListener == nil {
+		return nil
+	}
+	return a.grpcListener.Addr()
+}
+
+// Failed reports an error the service cannot continue after, e.g. a failed
+// HTTP listener.
+func (a *App) Failed() <-chan error {
+	return a.failed
+}
+
+// Shutdown stops the consumers first, so no message is left half processed,
+// then saves the cache snapshot, stops the HTTP and gRPC servers and closes
+// the connections.
+func (a *App) Shutdown(ctx context.Context) error {
+	var errs []error
+	if a.stopJobs != nil {
+		a.stopJobs()
+	}
+
+	// Остановка Kafka consumer
+	if err := a.consumer.Stop(); err != nil {
+		errs = append(errs, fmt.Errorf("failed to stop Kafka consumer: %w", err))
+	}
+	if a.erasureConsumer != nil {
+		if err := a.erasureConsumer.Stop(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to stop erasure consumer: %w", err))
+		}
+	}
+
+	// Последний снимок после остановки consumer, чтобы в него попали все заказы
+	if err := a.orderService.SaveSnapshot(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("failed to save cache snapshot: %w", err))
+	}
+
+	// Потоки /orders/stream и WatchOrders сами не завершаются, закрываем
+	// подписки до остановки серверов
+	a.orderService.CloseWatchers()
+
+	// Завершение работы HTTP сервера
+	if a.listener != nil {
+		if err := a.srv.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("failed to shutdown server: %w", err))
+		}
+	}
+
+	if a.grpcListener != nil {
+		if err := stopGRPC(ctx, a.grpcSrv); err != nil {
+			errs = append(errs, fmt.Errorf("failed to shutdown gRPC server: %w", err))
+		}
+	}
+
+	if err := a.close(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// stopGRPC waits for running calls, long ListOrders streams are cut off when
+// ctx is done.
+func stopGRPC(ctx context.Context, srv *grpc.Server) error {
+	done := make(chan struct{})
+	go func() {
+		srv.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		srv.Stop()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// close releases the cache and the database connections.
+func (a *App) close() error {
+	var errs []error
+	if err := a.closeCache(); err != nil {
+		errs = append(errs, fmt.Errorf("failed to close cache: %w", err))
+	}
+
+	// Закрытие подключений к шардам и базе данных
+	if a.orderRepo != nil {
+		if err := a.orderRepo.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close shard databases: %w", err))
+		}
+	}
+	if a.db != nil {
+		if err := a.db.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close database: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,106 @@
+package app
+
+import (
+	"fmt"
+
+	"github.com/Egor-Pomidor-pdf/order-service/internal/config"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/db"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/fieldcrypt"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order/cache"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order/repository"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order/service"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/retention"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/stats"
+	"github.com/jmoiron/sqlx"
+)
+
+// NewOrderRepository creates the repository with column encryption if a key
+// is configured and connects to the shard databases. The caller closes the
+// repository.
+func NewOrderRepository(cfg *config.Config, db *sqlx.DB) (*repository.OrderRepository, error) {
+	cipher, err := fieldcrypt.FromBase64(cfg.Database.EncryptionKey)
+	if err != nil {
+		return nil, err
+	}
+	shards, err := openShards(cfg.Database)
+	if err != nil {
+		return nil, err
+	}
+	return repository.NewOrderRepository(db, repository.WithFieldCipher(cipher), repository.WithShards(shards)), nil
+}
+
+// openShards connects to every configured shard database.
+func openShards(cfg config.DatabaseConfig) ([]*repository.Shard, error) {
+	var shards []*repository.Shard
+	for _, shardCfg := range cfg.Shards {
+		shardDB, err := db.NewPostgresDB(cfg.Shard(shardCfg))
+		if err != nil {
+			for _, s := range shards {
+				s.DB.Close()
+			}
+			return nil, fmt.Errorf("shard %s: %w", shardCfg.ID, err)
+		}
+		shards = append(shards, &repository.Shard{ID: shardCfg.ID, DB: shardDB})
+	}
+	return shards, nil
+}
+
+// ChangeSources returns the connection strings of the main and every shard
+// database.
+func ChangeSources(cfg config.DatabaseConfig) []string {
+	conns := []string{db.ConnString(cfg)}
+	for _, shardCfg := range cfg.Shards {
+		if conn := db.ConnString(cfg.Shard(shardCfg)); conn != conns[0] {
+			conns = append(conns, conn)
+		}
+	}
+	return conns
+}
+
+// NewOrderService creates the service, reading archived orders when
+// retention archives them and keeping the stream history. The returned func closes the Redis connection if
+// there is one.
+func NewOrderService(cfg *config.Config, repo *repository.OrderRepository) (*service.OrderService, func() error) {
+	opts := []service.Option{service.WithWatchHistory(cfg.Server.Stream.History)}
+	if cfg.Retention.Enabled && cfg.Retention.Mode == retention.ModeArchive {
+		opts = append(opts, service.WithArchiveFallback())
+	}
+
+	if cfg.Cache.Snapshot.Path != "" {
+		opts = append(opts, service.WithSnapshot(cfg.Cache.Snapshot.Path, repo.FieldCipher()))
+	}
+
+	memory := cache.NewMemory(cfg.Cache.TTL)
+	if cfg.Cache.Redis.Addr == "" {
+		opts = append(opts, service.WithCache(memory))
+		return service.NewOrderService(repo, opts...), func() error { return nil }
+	}
+	redisCache := cache.NewRedis(cfg.Cache.Redis, cache.WithFieldCipher(repo.FieldCipher()))
+	opts = append(opts, service.WithCache(cache.NewTiered(memory, redisCache)))
+	return service.NewOrderService(repo, opts...), redisCache.Close
+}
+
+// warmUpConfig returns the cache warm-up settings. Without an explicit
+// strategy a Redis tier is not filled at start, it keeps the orders across
+// restarts.
+func warmUpConfig(cfg config.CacheConfig) config.WarmUpConfig {
+	warmUp := cfg.WarmUp
+	if warmUp.Strategy == "" && cfg.Redis.Addr != "" {
+		warmUp.Strategy = service.WarmUpNone
+	}
+	return warmUp
+}
+
+// NewStatsRepository creates the analytics repository over every shard
+// database.
+func NewStatsRepository(cfg *config.Config, repo *repository.OrderRepository) *stats.Repository {
+	var dbs []*sqlx.DB
+	for _, shard := range repo.Shards() {
+		dbs = append(dbs, shard.DB)
+	}
+	var opts []stats.Option
+	if cfg.Stats.UseViews {
+		opts = append(opts, stats.WithViews())
+	}
+	return stats.NewRepository(dbs, opts...)
+}
@@ -0,0 +1,327 @@
+// Package apptest boots the whole order service in-process for scenario
+// tests: a throwaway Postgres database (see pgtest), an in-memory orders
+// topic instead of Kafka and the HTTP and gRPC APIs on random local ports.
+package apptest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	orderv1 "github.com/Egor-Pomidor-pdf/order-service/api/proto/order/v1"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/app"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/config"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/db"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/kafka"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/pgtest"
+	"github.com/ilyakaznacheev/cleanenv"
+	"github.com/jmoiron/sqlx"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+const (
+	// Timeout bounds every Wait helper.
+	Timeout = 10 * time.Second
+
+	pollInterval = 10 * time.Millisecond
+)
+
+// Harness is a running service with handles to its inputs and outputs.
+type Harness struct {
+	// Source is the orders topic the consumer reads.
+	Source *kafka.MemorySource
+	// DLQ receives the messages skipped as invalid.
+	DLQ *DeadLetters
+	// DB is a separate connection to the service database.
+	DB *sqlx.DB
+
+	t       testing.TB
+	cfg     *config.Config
+	app     *app.App
+	baseURL string
+	client  *http.Client
+}
+
+// Start boots the service with the test configuration, adjusted by
+// configure, and waits until it reports ready. It is stopped when the test
+// ends. Tests are skipped when pgtest cannot start Postgres.
+func Start(t testing.TB, configure ...func(*config.Config)) *Harness {
+	t.Helper()
+
+	cfg, err := Config(pgtest.NewConfig(t))
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	for _, fn := range configure {
+		fn(cfg)
+	}
+
+	database, err := db.NewPostgresDB(cfg.Database)
+	if err != nil {
+		t.Fatalf("connect to database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	h := &Harness{
+		Source: kafka.NewMemorySource(cfg.Kafka.Topic),
+		DLQ:    &DeadLetters{},
+		DB:     database,
+		t:      t,
+		cfg:    cfg,
+		client: &http.Client{Timeout: Timeout},
+	}
+	h.boot()
+	t.Cleanup(h.Stop)
+	return h
+}
+
+// Config returns the configuration the harness runs with: defaults from
+// the env-default tags, no background jobs, no auth and no PII masking in
+// responses.
+func Config(database config.DatabaseConfig) (*config.Config, error) {
+	var cfg config.Config
+	if err := cleanenv.ReadEnv(&cfg); err != nil {
+		return nil, err
+	}
+	cfg.Env = "local"
+	cfg.Database = database
+	cfg.Server = config.ServerConfig{Address: "127.0.0.1:0", Stream: config.StreamConfig{History: 100}}
+	cfg.GRPC = config.GRPCConfig{Address: "127.0.0.1:0"}
+	cfg.Kafka = config.KafkaConfig{Topic: "orders", RetryBackoff: 20 * time.Millisecond, DLQRedact: true}
+	cfg.Partitions.Enabled = false
+	cfg.Retention.Enabled = false
+	cfg.Stats.UseViews = false
+	cfg.Cache = config.CacheConfig{
+		Invalidation: true,
+		WarmUp:       config.WarmUpConfig{Strategy: "all", Timeout: Timeout, RetryBackoff: 20 * time.Millisecond},
+	}
+	return &cfg, nil
+}
+
+func (h *Harness) boot() {
+	h.t.Helper()
+
+	a, err := app.New(h.cfg, app.WithOrderSource(h.Source), app.WithDeadLetterSink(h.DLQ))
+	if err != nil {
+		h.t.Fatalf("create app: %v", err)
+	}
+	if err := a.Start(); err != nil {
+		a.Shutdown(context.Background())
+		h.t.Fatalf("start app: %v", err)
+	}
+	h.app = a
+	h.baseURL = "http://" + a.Addr().String()
+	h.WaitReady()
+}
+
+// URL returns the address of the HTTP API joined with path.
+func (h *Harness) URL(path string) string {
+	return h.baseURL + path
+}
+
+// GRPC returns a client of the gRPC API of the running service. The
+// connection is closed when the test ends.
+func (h *Harness) GRPC() orderv1.OrderServiceClient {
+	h.t.Helper()
+	conn, err := grpc.NewClient(h.app.GRPCAddr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		h.t.Fatalf("dial gRPC: %v", err)
+	}
+	h.t.Cleanup(func() { conn.Close() })
+	return orderv1.NewOrderServiceClient(conn)
+}
+
+// Stop shuts the service down the way a SIGTERM does. It can be called more
+// than once.
+func (h *Harness) Stop() {
+	h.t.Helper()
+	if h.app == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+	defer cancel()
+	err := h.app.Shutdown(ctx)
+	h.app = nil
+	if err != nil {
+		h.t.Errorf("shutdown: %v", err)
+	}
+}
+
+// Restart stops the service and starts a new instance on the same database.
+// The consumer resumes from the last committed offset, as after a crash or
+// a deploy.
+func (h *Harness) Restart() {
+	h.t.Helper()
+	h.Stop()
+	h.Source.Rewind()
+	h.boot()
+}
+
+// Publish sends o to the orders topic keyed by its order_uid and returns
+// the offset.
+func (h *Harness) Publish(o order.Order) int64 {
+	h.t.Helper()
+	value, err := json.Marshal(o)
+	if err != nil {
+		h.t.Fatalf("marshal order: %v", err)
+	}
+	return h.Source.Publish([]byte(o.OrderUID), value)
+}
+
+// PublishRaw sends an arbitrary message to the orders topic.
+func (h *Harness) PublishRaw(key, value []byte) int64 {
+	return h.Source.Publish(key, value)
+}
+
+// WaitCommitted waits until the consumer committed offset, that is the
+// message was stored, skipped or dead-lettered.
+func (h *Harness) WaitCommitted(offset int64) {
+	h.t.Helper()
+	h.waitFor(fmt.Sprintf("offset %d committed", offset), func() bool {
+		return h.Source.Committed() > offset
+	})
+}
+
+// WaitReady waits until GET /readyz answers 200.
+func (h *Harness) WaitReady() {
+	h.t.Helper()
+	h.waitFor("service ready", func() bool {
+		resp, err := h.client.Get(h.URL("/readyz"))
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return resp.StatusCode == http.StatusOK
+	})
+}
+
+// WaitOrder waits until GET /order/{uid} returns the order.
+func (h *Harness) WaitOrder(uid string) *order.Order {
+	h.t.Helper()
+	var got *order.Order
+	h.waitFor("order "+uid, func() bool {
+		got, _ = h.GetOrder(uid)
+		return got != nil
+	})
+	return got
+}
+
+// GetOrder requests an order through the HTTP API, nil with the status
+// code when it is not returned.
+func (h *Harness) GetOrder(uid string) (*order.Order, int) {
+	h.t.Helper()
+	resp, err := h.client.Get(h.URL("/order/" + uid))
+	if err != nil {
+		h.t.Fatalf("GET /order/%s: %v", uid, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, resp.StatusCode
+	}
+
+	var o order.Order
+	if err := json.NewDecoder(resp.Body).Decode(&o); err != nil {
+		h.t.Fatalf("decode order %s: %v", uid, err)
+	}
+	return &o, resp.StatusCode
+}
+
+// FailWrites makes the next n order inserts fail with a database error, every
+// insert when n < 0, until the returned function is called. The consumer
+// retries such messages instead of skipping them.
+func (h *Harness) FailWrites(n int) (restore func()) {
+	h.t.Helper()
+	if n < 0 {
+		n = math.MaxInt32
+	}
+	// sequence не откатывается вместе с транзакцией, поэтому считает попытки
+	h.exec(`CREATE SEQUENCE IF NOT EXISTS apptest_write_attempts`)
+	h.exec(`ALTER SEQUENCE apptest_write_attempts RESTART`)
+	h.exec(fmt.Sprintf(`CREATE OR REPLACE FUNCTION apptest_fail_write() RETURNS trigger AS $$
+BEGIN
+	IF nextval('apptest_write_attempts') <= %d THEN
+		RAISE EXCEPTION 'apptest: injected write failure';
+	END IF;
+	RETURN NEW;
+END $$ LANGUAGE plpgsql`, n))
+	h.exec(`DROP TRIGGER IF EXISTS apptest_fail_write ON order_keys`)
+	h.exec(`CREATE TRIGGER apptest_fail_write BEFORE INSERT ON order_keys
+		FOR EACH ROW EXECUTE FUNCTION apptest_fail_write()`)
+
+	return func() {
+		h.exec(`DROP TRIGGER IF EXISTS apptest_fail_write ON order_keys`)
+	}
+}
+
+// WriteAttempts returns how many order inserts were attempted since
+// FailWrites, failed ones included.
+func (h *Harness) WriteAttempts() int {
+	h.t.Helper()
+	var n int
+	err := h.DB.Get(&n, `SELECT CASE WHEN is_called THEN last_value ELSE 0 END FROM apptest_write_attempts`)
+	if err != nil {
+		h.t.Fatalf("read write attempts: %v", err)
+	}
+	return n
+}
+
+// WaitWriteAttempts waits until at least n order inserts were attempted.
+func (h *Harness) WaitWriteAttempts(n int) {
+	h.t.Helper()
+	h.waitFor(fmt.Sprintf("%d write attempts", n), func() bool {
+		return h.WriteAttempts() >= n
+	})
+}
+
+func (h *Harness) exec(query string) {
+	h.t.Helper()
+	if _, err := h.DB.Exec(query); err != nil {
+		h.t.Fatalf("exec %q: %v", query, err)
+	}
+}
+
+func (h *Harness) waitFor(what string, cond func() bool) {
+	h.t.Helper()
+	deadline := time.Now().Add(Timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			h.t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+// DeadLetter is a message the consumer skipped.
+type DeadLetter struct {
+	Message kafka.Message
+	Reason  string
+}
+
+// DeadLetters is an in-memory dead letter topic.
+type DeadLetters struct {
+	mu      sync.Mutex
+	letters []DeadLetter
+}
+
+func (d *DeadLetters) Publish(_ context.Context, msg *kafka.Message, reason error) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.letters = append(d.letters, DeadLetter{Message: *msg, Reason: reason.Error()})
+	return nil
+}
+
+func (d *DeadLetters) Close() error { return nil }
+
+// All returns the dead letters received so far.
+func (d *DeadLetters) All() []DeadLetter {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]DeadLetter(nil), d.letters...)
+}
@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const apiKeyHeader = "X-API-Key"
+
+type apiKey struct {
+	name  string
+	key   []byte
+	roles []string
+}
+
+// APIKeyAuthenticator accepts static keys sent in the X-API-Key header.
+type APIKeyAuthenticator struct {
+	keys []apiKey
+}
+
+// NewAPIKeyAuthenticator parses entries of the form "name:key:role1|role2".
+func NewAPIKeyAuthenticator(entries []string) (*APIKeyAuthenticator, error) {
+	a := &APIKeyAuthenticator{}
+	for i, entry := range entries {
+		parts := strings.SplitN(strings.TrimSpace(entry), ":", 3)
+		if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+			// само значение не выводим: в кривой записи ключом может оказаться что угодно
+			return nil, fmt.Errorf("invalid api key entry #%d, expected name:key:roles", i+1)
+		}
+		a.keys = append(a.keys, apiKey{
+			name:  parts[0],
+			key:   []byte(parts[1]),
+			roles: strings.Split(parts[2], "|"),
+		})
+	}
+	return a, nil
+}
+
+func (a *APIKeyAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	got := r.Header.Get(apiKeyHeader)
+	if got == "" {
+		return nil, ErrNoCredentials
+	}
+
+	var match *apiKey
+	for i := range a.keys {
+		// compare against every key so timing does not reveal which one matched
+		if subtle.ConstantTimeCompare([]byte(got), a.keys[i].key) == 1 {
+			match = &a.keys[i]
+		}
+	}
+	if match == nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	return &Principal{Subject: match.name, Roles: match.roles, Method: "api_key"}, nil
+}
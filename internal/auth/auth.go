@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/Egor-Pomidor-pdf/order-service/internal/config"
+)
+
+const (
+	RoleReader = "reader"
+	RoleAdmin  = "admin"
+)
+
+var (
+	// ErrNoCredentials means the request carries nothing this authenticator
+	// understands, so the next one in the chain should be tried.
+	ErrNoCredentials      = errors.New("no credentials")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+type Principal struct {
+	Subject string   `json:"subject"`
+	Roles   []string `json:"roles"`
+	// Method is the authenticator that accepted the request: api_key, jwt or none.
+	Method string `json:"method"`
+}
+
+// HasRole reports whether p has role. Admins are allowed everything.
+func (p *Principal) HasRole(role string) bool {
+	return slices.Contains(p.Roles, role) || slices.Contains(p.Roles, RoleAdmin)
+}
+
+var anonymous = &Principal{Subject: "anonymous", Roles: []string{RoleReader}, Method: "none"}
+
+type Authenticator interface {
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+// Chain tries authenticators in order until one recognizes the credentials.
+type Chain []Authenticator
+
+func (c Chain) Authenticate(r *http.Request) (*Principal, error) {
+	for _, a := range c {
+		p, err := a.Authenticate(r)
+		if errors.Is(err, ErrNoCredentials) {
+			continue
+		}
+		return p, err
+	}
+	return nil, ErrNoCredentials
+}
+
+// New builds the authenticator chain from config. It returns nil when
+// authentication is disabled.
+func New(cfg config.AuthConfig) (Authenticator, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	var chain Chain
+	if len(cfg.APIKeys) > 0 {
+		keys, err := NewAPIKeyAuthenticator(cfg.APIKeys)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, keys)
+	}
+	if cfg.JWT.HMACSecret != "" || cfg.JWT.RSAPublicKeyFile != "" {
+		jwt, err := NewJWTAuthenticator(cfg.JWT)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, jwt)
+	}
+
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("auth is enabled but neither api keys nor jwt keys are configured")
+	}
+	return chain, nil
+}
+
+type ctxKey struct{}
+
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, ctxKey{}, p)
+}
+
+// FromContext returns the caller set by Middleware, or nil.
+func FromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(ctxKey{}).(*Principal)
+	return p
+}
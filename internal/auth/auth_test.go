@@ -0,0 +1,190 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Egor-Pomidor-pdf/order-service/internal/config"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const hmacSecret = "test-secret"
+
+func signHS256(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(hmacSecret))
+	require.NoError(t, err)
+	return token
+}
+
+func writePublicKey(t *testing.T, key *rsa.PrivateKey) string {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "jwt.pub")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600))
+	return path
+}
+
+func TestAuthenticate(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	authenticator, err := New(config.AuthConfig{
+		Enabled: true,
+		APIKeys: []string{"support:s3cret:reader", "ops:0ps:admin"},
+		JWT: config.JWTConfig{
+			HMACSecret:       hmacSecret,
+			RSAPublicKeyFile: writePublicKey(t, rsaKey),
+			Issuer:           "auth.example",
+			RolesClaim:       "roles",
+		},
+	})
+	require.NoError(t, err)
+
+	exp := time.Now().Add(time.Hour).Unix()
+	rs256, err := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"sub": "svc-reports", "iss": "auth.example", "exp": exp, "roles": "reader",
+	}).SignedString(rsaKey)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		header      string
+		value       string
+		wantErr     error
+		wantSubject string
+		wantAdmin   bool
+	}{
+		{name: "no credentials", wantErr: ErrNoCredentials},
+		{name: "api key", header: "X-API-Key", value: "s3cret", wantSubject: "support"},
+		{name: "admin api key", header: "X-API-Key", value: "0ps", wantSubject: "ops", wantAdmin: true},
+		{name: "unknown api key", header: "X-API-Key", value: "nope", wantErr: ErrInvalidCredentials},
+		{
+			name:   "hs256",
+			header: "Authorization",
+			value: "Bearer " + signHS256(t, jwt.MapClaims{
+				"sub": "alice", "iss": "auth.example", "exp": exp, "roles": []string{"admin"},
+			}),
+			wantSubject: "alice",
+			wantAdmin:   true,
+		},
+		{name: "rs256", header: "Authorization", value: "Bearer " + rs256, wantSubject: "svc-reports"},
+		{
+			name:   "expired",
+			header: "Authorization",
+			value: "Bearer " + signHS256(t, jwt.MapClaims{
+				"sub": "alice", "iss": "auth.example", "exp": time.Now().Add(-time.Minute).Unix(),
+			}),
+			wantErr: ErrInvalidCredentials,
+		},
+		{
+			name:    "wrong issuer",
+			header:  "Authorization",
+			value:   "Bearer " + signHS256(t, jwt.MapClaims{"sub": "alice", "iss": "evil", "exp": exp}),
+			wantErr: ErrInvalidCredentials,
+		},
+		{
+			name:    "no expiry",
+			header:  "Authorization",
+			value:   "Bearer " + signHS256(t, jwt.MapClaims{"sub": "alice", "iss": "auth.example"}),
+			wantErr: ErrInvalidCredentials,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/order/1", nil)
+			if test.header != "" {
+				r.Header.Set(test.header, test.value)
+			}
+
+			p, err := authenticator.Authenticate(r)
+			if test.wantErr != nil {
+				assert.ErrorIs(t, err, test.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.wantSubject, p.Subject)
+			assert.True(t, p.HasRole(RoleReader))
+			assert.Equal(t, test.wantAdmin, p.HasRole(RoleAdmin))
+		})
+	}
+}
+
+func TestNew_Disabled(t *testing.T) {
+	a, err := New(config.AuthConfig{APIKeys: []string{"support:s3cret:reader"}})
+	require.NoError(t, err)
+	assert.Nil(t, a)
+
+	_, err = New(config.AuthConfig{Enabled: true})
+	assert.Error(t, err)
+}
+
+func TestMiddleware_Roles(t *testing.T) {
+	keys, err := NewAPIKeyAuthenticator([]string{"support:s3cret:reader", "ops:0ps:admin"})
+	require.NoError(t, err)
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name          string
+		authenticator Authenticator
+		apiKey        string
+		role          string
+		expected      int
+	}{
+		{name: "missing key", authenticator: keys, role: RoleReader, expected: 401},
+		{name: "reader reads", authenticator: keys, apiKey: "s3cret", role: RoleReader, expected: 200},
+		{name: "reader writes", authenticator: keys, apiKey: "s3cret", role: RoleAdmin, expected: 403},
+		{name: "admin writes", authenticator: keys, apiKey: "0ps", role: RoleAdmin, expected: 200},
+		{name: "disabled reads", role: RoleReader, expected: 200},
+		{name: "disabled writes", role: RoleAdmin, expected: 403},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := Middleware(test.authenticator)(RequireRole(test.role)(Audit(ok)))
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", "/order/1", nil)
+			if test.apiKey != "" {
+				r.Header.Set(apiKeyHeader, test.apiKey)
+			}
+			h.ServeHTTP(w, r)
+
+			assert.Equal(t, test.expected, w.Code)
+		})
+	}
+}
+
+func TestMethodRoles(t *testing.T) {
+	roles := MethodRoles{"/order.v1.OrderService/GetOrder": RoleReader}
+
+	assert.Equal(t, RoleReader, roles.role("/order.v1.OrderService/GetOrder"))
+	assert.Equal(t, RoleAdmin, roles.role("/order.v1.OrderService/NewMethod"), "unlisted methods need admin")
+}
+
+func TestNewAPIKeyAuthenticator_Invalid(t *testing.T) {
+	for _, entries := range [][]string{
+		{"support:s3cret:reader", "s3cretkey"},
+		{"support:s3cret:reader", "ops:s3cretkey:"},
+	} {
+		_, err := NewAPIKeyAuthenticator(entries)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "#2")
+		assert.NotContains(t, err.Error(), "s3cret", "keys are never logged")
+	}
+}
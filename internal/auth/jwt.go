@@ -0,0 +1,101 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/Egor-Pomidor-pdf/order-service/internal/config"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// JWTAuthenticator accepts bearer tokens signed with HS256 or RS256 by the
+// locally configured keys. No remote key discovery is performed.
+type JWTAuthenticator struct {
+	hmacSecret []byte
+	rsaKey     *rsa.PublicKey
+	rolesClaim string
+	parser     *jwt.Parser
+}
+
+func NewJWTAuthenticator(cfg config.JWTConfig) (*JWTAuthenticator, error) {
+	a := &JWTAuthenticator{rolesClaim: cfg.RolesClaim}
+	if a.rolesClaim == "" {
+		a.rolesClaim = "roles"
+	}
+
+	var methods []string
+	if cfg.HMACSecret != "" {
+		a.hmacSecret = []byte(cfg.HMACSecret)
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	if cfg.RSAPublicKeyFile != "" {
+		pem, err := os.ReadFile(cfg.RSAPublicKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("read jwt public key: %w", err)
+		}
+		if a.rsaKey, err = jwt.ParseRSAPublicKeyFromPEM(pem); err != nil {
+			return nil, fmt.Errorf("parse jwt public key: %w", err)
+		}
+		methods = append(methods, jwt.SigningMethodRS256.Alg())
+	}
+
+	opts := []jwt.ParserOption{jwt.WithValidMethods(methods), jwt.WithExpirationRequired()}
+	if cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		opts = append(opts, jwt.WithAudience(cfg.Audience))
+	}
+	a.parser = jwt.NewParser(opts...)
+
+	return a, nil
+}
+
+func (a *JWTAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	raw, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || raw == "" {
+		return nil, ErrNoCredentials
+	}
+
+	claims := jwt.MapClaims{}
+	if _, err := a.parser.ParseWithClaims(raw, claims, a.key); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+
+	subject, _ := claims.GetSubject()
+	if subject == "" {
+		return nil, fmt.Errorf("%w: token has no subject", ErrInvalidCredentials)
+	}
+
+	return &Principal{Subject: subject, Roles: rolesFromClaim(claims[a.rolesClaim]), Method: "jwt"}, nil
+}
+
+func (a *JWTAuthenticator) key(token *jwt.Token) (any, error) {
+	switch token.Method.Alg() {
+	case jwt.SigningMethodHS256.Alg():
+		return a.hmacSecret, nil
+	case jwt.SigningMethodRS256.Alg():
+		return a.rsaKey, nil
+	}
+	return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+}
+
+// rolesFromClaim accepts both a JSON array and a space separated string.
+func rolesFromClaim(claim any) []string {
+	switch v := claim.(type) {
+	case string:
+		return strings.Fields(v)
+	case []any:
+		roles := make([]string, 0, len(v))
+		for _, r := range v {
+			if s, ok := r.(string); ok {
+				roles = append(roles, s)
+			}
+		}
+		return roles
+	}
+	return nil
+}
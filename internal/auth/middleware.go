@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// Middleware authenticates the request and stores the Principal in its
+// context. A nil authenticator means auth is disabled and every caller is an
+// anonymous reader.
+func Middleware(a Authenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal := anonymous
+			if a != nil {
+				p, err := a.Authenticate(r)
+				if err != nil {
+					if !errors.Is(err, ErrNoCredentials) {
+						slog.Warn("authentication failed", "error", err, "remote_addr", r.RemoteAddr)
+					}
+					writeError(w, http.StatusUnauthorized, "Unauthorized")
+					return
+				}
+				principal = p
+			}
+
+			next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), principal)))
+		})
+	}
+}
+
+// RequireRole rejects callers without role. It must run after Middleware.
+func RequireRole(role string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p := FromContext(r.Context())
+			if p == nil || !p.HasRole(role) {
+				writeError(w, http.StatusForbidden, "Forbidden")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Audit logs who accessed which order and with what result.
+func Audit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+
+		p := FromContext(r.Context())
+		if p == nil {
+			p = anonymous
+		}
+		slog.Info("AUDIT",
+			"subject", p.Subject,
+			"auth_method", p.Method,
+			"roles", p.Roles,
+			"method", r.Method,
+			"path", r.URL.Path,
+			"order_uid", chi.URLParam(r, "order_uid"),
+			"status", ww.Status(),
+			"request_id", middleware.GetReqID(r.Context()),
+		)
+	})
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
@@ -3,21 +3,100 @@ package config
 import (
 	"log"
 	"os"
+	"time"
 
 	"github.com/ilyakaznacheev/cleanenv"
 )
 
 type Config struct {
-	Env      string         `yaml:"env" env:"ENV"`
-	Server   ServerConfig   `yaml:"server"`
-	Database DatabaseConfig `yaml:"database"`
-	Kafka    KafkaConfig    `yaml:"kafka"`
+	Env        string          `yaml:"env" env:"ENV"`
+	Server     ServerConfig    `yaml:"server"`
+	GRPC       GRPCConfig      `yaml:"grpc"`
+	Database   DatabaseConfig  `yaml:"database"`
+	Kafka      KafkaConfig     `yaml:"kafka"`
+	Retention  RetentionConfig `yaml:"retention"`
+	Partitions PartitionConfig `yaml:"partitions"`
+	Stats      StatsConfig     `yaml:"stats"`
+	Cache      CacheConfig     `yaml:"cache"`
+}
+
+// CacheConfig controls the order cache: an in-memory map, entries expiring
+// after TTL (0 never), and an optional shared Redis tier. With Invalidation
+// every replica listens for changes of stored orders and drops stale copies.
+type CacheConfig struct {
+	Invalidation bool           `yaml:"invalidation" env:"CACHE_INVALIDATION" env-default:"true"`
+	TTL          time.Duration  `yaml:"ttl" env:"CACHE_TTL"`
+	Redis        RedisConfig    `yaml:"redis"`
+	Snapshot     SnapshotConfig `yaml:"snapshot"`
+	WarmUp       WarmUpConfig   `yaml:"warmup"`
+}
+
+// WarmUpConfig controls how the cache is filled in the background at start:
+// "none", the "recent" Recent orders or "all" of them. Empty means "all",
+// or "none" with a Redis tier. Failed attempts are retried with a growing
+// backoff until Timeout (0 waits forever).
+type WarmUpConfig struct {
+	Strategy     string        `yaml:"strategy" env:"CACHE_WARMUP"`
+	Recent       int           `yaml:"recent" env:"CACHE_WARMUP_RECENT" env-default:"10000"`
+	Timeout      time.Duration `yaml:"timeout" env:"CACHE_WARMUP_TIMEOUT" env-default:"5m"`
+	RetryBackoff time.Duration `yaml:"retry_backoff" env:"CACHE_WARMUP_RETRY_BACKOFF" env-default:"1s"`
+}
+
+// SnapshotConfig saves the in-memory cache to Path every Interval and on
+// shutdown, the next start loads it instead of reading every order.
+// Disabled when Path is empty.
+type SnapshotConfig struct {
+	Path     string        `yaml:"path" env:"CACHE_SNAPSHOT_PATH"`
+	Interval time.Duration `yaml:"interval" env:"CACHE_SNAPSHOT_INTERVAL" env-default:"5m"`
+}
+
+// RedisConfig is the shared L2 cache, disabled when Addr is empty.
+type RedisConfig struct {
+	Addr     string        `yaml:"addr" env:"REDIS_ADDR"`
+	Password string        `yaml:"password" env:"REDIS_PASSWORD"`
+	DB       int           `yaml:"db" env:"REDIS_DB"`
+	Prefix   string        `yaml:"prefix" env:"REDIS_PREFIX" env-default:"order:"`
+	TTL      time.Duration `yaml:"ttl" env:"REDIS_TTL" env-default:"24h"`
+	Timeout  time.Duration `yaml:"timeout" env:"REDIS_TIMEOUT" env-default:"200ms"`
+}
+
+// StatsConfig switches the /stats revenue and brand aggregates to the
+// materialized views, refreshed every RefreshInterval.
+type StatsConfig struct {
+	UseViews        bool          `yaml:"use_views" env:"STATS_USE_VIEWS"`
+	RefreshInterval time.Duration `yaml:"refresh_interval" env:"STATS_REFRESH_INTERVAL" env-default:"15m"`
+}
+
+// PartitionConfig controls the job creating monthly partitions of the
+// orders and items tables MonthsAhead months in advance.
+type PartitionConfig struct {
+	Enabled     bool          `yaml:"enabled" env:"PARTITIONS_ENABLED" env-default:"true"`
+	MonthsAhead int           `yaml:"months_ahead" env:"PARTITIONS_MONTHS_AHEAD" env-default:"3"`
+	Interval    time.Duration `yaml:"interval" env:"PARTITIONS_INTERVAL" env-default:"24h"`
+}
+
+// RetentionConfig moves orders older than MaxAgeDays (by date_created) out
+// of the hot tables, either into the archive tables or into gzipped JSONL
+// files under ExportDir.
+type RetentionConfig struct {
+	Enabled    bool          `yaml:"enabled" env:"RETENTION_ENABLED"`
+	MaxAgeDays int           `yaml:"max_age_days" env:"RETENTION_MAX_AGE_DAYS" env-default:"365"`
+	Mode       string        `yaml:"mode" env:"RETENTION_MODE" env-default:"archive"`
+	ExportDir  string        `yaml:"export_dir" env:"RETENTION_EXPORT_DIR" env-default:"./archive"`
+	BatchSize  int           `yaml:"batch_size" env:"RETENTION_BATCH_SIZE" env-default:"500"`
+	Interval   time.Duration `yaml:"interval" env:"RETENTION_INTERVAL" env-default:"1h"`
 }
 
 type KafkaConfig struct {
-	Brokers []string `yaml:"brokers" env:"KAFKA_BROKERS" env-separator:","`
-	Topic   string   `yaml:"topic"   env:"KAFKA_TOPIC"`
-	GroupID string   `yaml:"group_id" env:"KAFKA_GROUP"`
+	Brokers      []string      `yaml:"brokers" env:"KAFKA_BROKERS" env-separator:","`
+	Topic        string        `yaml:"topic"   env:"KAFKA_TOPIC"`
+	GroupID      string        `yaml:"group_id" env:"KAFKA_GROUP"`
+	Driver       string        `yaml:"driver" env:"KAFKA_DRIVER" env-default:"confluent"`
+	RetryBackoff time.Duration `yaml:"retry_backoff" env:"KAFKA_RETRY_BACKOFF" env-default:"1s"`
+	DLQTopic     string        `yaml:"dlq_topic" env:"KAFKA_DLQ_TOPIC"`
+	DLQRedact    bool          `yaml:"dlq_redact" env:"KAFKA_DLQ_REDACT" env-default:"true"`
+	// ErasureTopic carries customer data erasure requests, disabled when empty.
+	ErasureTopic string `yaml:"erasure_topic" env:"KAFKA_ERASURE_TOPIC"`
 }
 
 type DatabaseConfig struct {
@@ -26,12 +105,92 @@ type DatabaseConfig struct {
 	Name     string `yaml:"name"     env:"DB_NAME"`
 	User     string `yaml:"user"     env:"DB_USER"`
 	Password string `yaml:"password" env:"DB_PASSWORD"`
+	// EncryptionKey is a base64 encoded 32 byte key. When set, delivery PII
+	// columns are stored encrypted.
+	EncryptionKey string `yaml:"encryption_key" env:"DB_ENCRYPTION_KEY"`
+	// Shards spread orders over several databases by ShardKey. When empty
+	// all orders are stored in this database.
+	Shards []ShardConfig `yaml:"shards"`
+}
+
+// ShardConfig is a shard database. Empty connection settings are taken from
+// the main database.
+type ShardConfig struct {
+	ID       string `yaml:"id"`
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	Name     string `yaml:"name"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+}
+
+// Shard returns the connection settings of shard s.
+func (c DatabaseConfig) Shard(s ShardConfig) DatabaseConfig {
+	shard := DatabaseConfig{Host: s.Host, Port: s.Port, Name: s.Name, User: s.User, Password: s.Password}
+	if shard.Host == "" {
+		shard.Host = c.Host
+	}
+	if shard.Port == 0 {
+		shard.Port = c.Port
+	}
+	if shard.Name == "" {
+		shard.Name = c.Name
+	}
+	if shard.User == "" {
+		shard.User = c.User
+	}
+	if shard.Password == "" {
+		shard.Password = c.Password
+	}
+	return shard
 }
 
 type ServerConfig struct {
-	Address string `yaml:"address" env:"HTTP_ADDR"`
+	Address   string          `yaml:"address" env:"HTTP_ADDR"`
+	Auth      AuthConfig      `yaml:"auth"`
+	Redaction RedactionConfig `yaml:"redaction"`
+	Stream    StreamConfig    `yaml:"stream"`
+}
+
+// StreamConfig controls the live order feeds, GET /orders/stream and gRPC
+// WatchOrders. A client more than Buffer orders behind is disconnected, the
+// last History orders are kept for clients resuming with Last-Event-ID.
+// Idle SSE connections get a comment every Heartbeat.
+type StreamConfig struct {
+	Buffer    int           `yaml:"buffer" env:"STREAM_BUFFER" env-default:"256"`
+	History   int           `yaml:"history" env:"STREAM_HISTORY" env-default:"1024"`
+	Heartbeat time.Duration `yaml:"heartbeat" env:"STREAM_HEARTBEAT" env-default:"15s"`
 }
 
+// GRPCConfig is the gRPC API, disabled when Address is empty. It uses the
+// auth and redaction settings of ServerConfig.
+type GRPCConfig struct {
+	Address string `yaml:"address" env:"GRPC_ADDR"`
+}
+
+// RedactionConfig controls masking of delivery PII in API responses.
+// Admins and callers with one of UnmaskedRoles see clear text.
+type RedactionConfig struct {
+	Enabled       bool     `yaml:"enabled" env:"REDACTION_ENABLED" env-default:"true"`
+	UnmaskedRoles []string `yaml:"unmasked_roles" env:"REDACTION_UNMASKED_ROLES" env-separator:"," env-default:"pii"`
+}
+
+// AuthConfig configures authentication of the HTTP API. When disabled every
+// caller gets the reader role and admin routes are unavailable.
+type AuthConfig struct {
+	Enabled bool `yaml:"enabled" env:"AUTH_ENABLED"`
+	// APIKeys entries have the form "name:key:role1|role2".
+	APIKeys []string  `yaml:"api_keys" env:"AUTH_API_KEYS" env-separator:","`
+	JWT     JWTConfig `yaml:"jwt"`
+}
+
+type JWTConfig struct {
+	HMACSecret       string `yaml:"hmac_secret" env:"AUTH_JWT_HMAC_SECRET"`
+	RSAPublicKeyFile string `yaml:"rsa_public_key_file" env:"AUTH_JWT_RSA_PUBLIC_KEY_FILE"`
+	Issuer           string `yaml:"issuer" env:"AUTH_JWT_ISSUER"`
+	Audience         string `yaml:"audience" env:"AUTH_JWT_AUDIENCE"`
+	RolesClaim       string `yaml:"roles_claim" env:"AUTH_JWT_ROLES_CLAIM" env-default:"roles"`
+}
 
 func MustLoad() *Config {
 	configPath := os.Getenv("CONFIG_PATH")
@@ -43,7 +202,7 @@ func MustLoad() *Config {
 	} else {
 		err = cleanenv.ReadEnv(&cfg)
 	}
-	
+
 	if err != nil {
 		log.Fatalf("cannot read config: %s", err)
 	}
@@ -9,8 +9,9 @@ import (
 	 _ "github.com/lib/pq" 
 )
 
-func NewPostgresDB(cfg config.DatabaseConfig) (*sqlx.DB, error) {
-	connStr := fmt.Sprintf(
+// ConnString builds the lib/pq connection string for cfg.
+func ConnString(cfg config.DatabaseConfig) string {
+	return fmt.Sprintf(
 		"host=%s port=%d dbname=%s user=%s password=%s sslmode=disable",
 		cfg.Host,
 		cfg.Port,
@@ -18,8 +19,10 @@ func NewPostgresDB(cfg config.DatabaseConfig) (*sqlx.DB, error) {
 		cfg.User,
 		cfg.Password,
 	)
+}
 
-	db, err := sqlx.Open("postgres", connStr)
+func NewPostgresDB(cfg config.DatabaseConfig) (*sqlx.DB, error) {
+	db, err := sqlx.Open("postgres", ConnString(cfg))
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to postgres: %w", err)
 	}
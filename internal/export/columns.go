@@ -0,0 +1,179 @@
+package export
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/redact"
+)
+
+type kind int
+
+const (
+	kindString kind = iota
+	kindInt
+	kindTime
+)
+
+// Column is one field of the flattened order. Item columns turn the export
+// into one row per item. Columns with personal data have a mask applied
+// unless the request includes PII.
+type Column struct {
+	Name  string
+	kind  kind
+	item  bool
+	mask  func(string) string
+	value func(o *order.Order, it *order.Item) any
+}
+
+func orderColumn(name string, k kind, value func(o *order.Order) any) Column {
+	return Column{Name: name, kind: k, value: func(o *order.Order, _ *order.Item) any { return value(o) }}
+}
+
+// piiColumn is a delivery field masked the same way as in API responses.
+func piiColumn(name string, mask func(string) string, value func(o *order.Order) string) Column {
+	c := orderColumn(name, kindString, func(o *order.Order) any { return value(o) })
+	c.mask = mask
+	return c
+}
+
+func itemColumn(name string, k kind, value func(it *order.Item) any) Column {
+	return Column{Name: name, kind: k, item: true, value: func(_ *order.Order, it *order.Item) any { return value(it) }}
+}
+
+var columns = []Column{
+	orderColumn("order_uid", kindString, func(o *order.Order) any { return o.OrderUID }),
+	orderColumn("track_number", kindString, func(o *order.Order) any { return o.TrackNumber }),
+	orderColumn("entry", kindString, func(o *order.Order) any { return o.Entry }),
+	orderColumn("locale", kindString, func(o *order.Order) any { return o.Locale }),
+	orderColumn("customer_id", kindString, func(o *order.Order) any { return o.CustomerID }),
+	orderColumn("delivery_service", kindString, func(o *order.Order) any { return o.DeliveryService }),
+	orderColumn("shardkey", kindString, func(o *order.Order) any { return o.ShardKey }),
+	orderColumn("sm_id", kindInt, func(o *order.Order) any { return int64(o.SMID) }),
+	orderColumn("date_created", kindTime, func(o *order.Order) any { return o.DateCreated }),
+	orderColumn("oof_shard", kindString, func(o *order.Order) any { return o.OOFShard }),
+
+	piiColumn("delivery_name", redact.MaskName, func(o *order.Order) string { return o.Delivery.Name }),
+	piiColumn("delivery_phone", redact.MaskPhone, func(o *order.Order) string { return o.Delivery.Phone }),
+	piiColumn("delivery_email", redact.MaskEmail, func(o *order.Order) string { return o.Delivery.Email }),
+	orderColumn("delivery_zip", kindString, func(o *order.Order) any { return o.Delivery.Zip }),
+	orderColumn("delivery_city", kindString, func(o *order.Order) any { return o.Delivery.City }),
+	piiColumn("delivery_address", redact.MaskAddress, func(o *order.Order) string { return o.Delivery.Address }),
+	orderColumn("delivery_region", kindString, func(o *order.Order) any { return o.Delivery.Region }),
+
+	orderColumn("payment_transaction", kindString, func(o *order.Order) any { return o.Payment.Transaction }),
+	orderColumn("payment_request_id", kindString, func(o *order.Order) any { return o.Payment.RequestID }),
+	orderColumn("payment_currency", kindString, func(o *order.Order) any { return o.Payment.Currency }),
+	orderColumn("payment_provider", kindString, func(o *order.Order) any { return o.Payment.Provider }),
+	orderColumn("payment_amount", kindInt, func(o *order.Order) any { return int64(o.Payment.Amount) }),
+	orderColumn("payment_dt", kindInt, func(o *order.Order) any { return o.Payment.PaymentDT }),
+	orderColumn("payment_bank", kindString, func(o *order.Order) any { return o.Payment.Bank }),
+	orderColumn("payment_delivery_cost", kindInt, func(o *order.Order) any { return int64(o.Payment.DeliveryCost) }),
+	orderColumn("payment_goods_total", kindInt, func(o *order.Order) any { return int64(o.Payment.GoodsTotal) }),
+	orderColumn("payment_custom_fee", kindInt, func(o *order.Order) any { return int64(o.Payment.CustomFee) }),
+
+	itemColumn("item_chrt_id", kindInt, func(it *order.Item) any { return int64(it.ChrtID) }),
+	itemColumn("item_track_number", kindString, func(it *order.Item) any { return it.TrackNumber }),
+	itemColumn("item_price", kindInt, func(it *order.Item) any { return int64(it.Price) }),
+	itemColumn("item_rid", kindString, func(it *order.Item) any { return it.RID }),
+	itemColumn("item_name", kindString, func(it *order.Item) any { return it.Name }),
+	itemColumn("item_sale", kindInt, func(it *order.Item) any { return int64(it.Sale) }),
+	itemColumn("item_size", kindString, func(it *order.Item) any { return it.Size }),
+	itemColumn("item_total_price", kindInt, func(it *order.Item) any { return int64(it.TotalPrice) }),
+	itemColumn("item_nm_id", kindInt, func(it *order.Item) any { return int64(it.NmID) }),
+	itemColumn("item_brand", kindString, func(it *order.Item) any { return it.Brand }),
+	itemColumn("item_status", kindInt, func(it *order.Item) any { return int64(it.Status) }),
+}
+
+// Sets are the predefined column sets. "orders" is the default.
+var Sets = map[string][]string{
+	"orders": {
+		"order_uid", "date_created", "customer_id", "track_number", "delivery_service",
+		"payment_transaction", "payment_currency", "payment_provider", "payment_amount",
+		"payment_delivery_cost", "payment_goods_total", "payment_custom_fee",
+	},
+	"payments": {
+		"order_uid", "date_created", "payment_transaction", "payment_request_id", "payment_currency",
+		"payment_provider", "payment_amount", "payment_dt", "payment_bank",
+		"payment_delivery_cost", "payment_goods_total", "payment_custom_fee",
+	},
+	"items": {
+		"order_uid", "date_created", "item_chrt_id", "item_nm_id", "item_name", "item_brand",
+		"item_size", "item_price", "item_sale", "item_total_price", "item_status",
+	},
+}
+
+const DefaultSet = "orders"
+
+// Columns resolves a comma separated list of column names, or the named set
+// when names is empty.
+func Columns(set, names string) ([]Column, error) {
+	var selected []string
+	if names != "" {
+		selected = strings.Split(names, ",")
+	} else {
+		if set == "" {
+			set = DefaultSet
+		}
+		var ok bool
+		if selected, ok = Sets[set]; !ok {
+			return nil, fmt.Errorf("unknown column set %q, expected one of %s", set, strings.Join(setNames(), ", "))
+		}
+	}
+
+	result := make([]Column, 0, len(selected))
+	seen := make(map[string]bool, len(selected))
+	for _, name := range selected {
+		name = strings.TrimSpace(name)
+		col, ok := lookup(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown column %q", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate column %q", name)
+		}
+		seen[name] = true
+		result = append(result, col)
+	}
+	return result, nil
+}
+
+func lookup(name string) (Column, bool) {
+	for _, c := range columns {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	return Column{}, false
+}
+
+func setNames() []string {
+	names := make([]string, 0, len(Sets))
+	for name := range Sets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func perItem(cols []Column) bool {
+	for _, c := range cols {
+		if c.item {
+			return true
+		}
+	}
+	return false
+}
+
+// formatValue renders a value for text formats.
+func formatValue(v any) string {
+	switch v := v.(type) {
+	case time.Time:
+		return v.UTC().Format(time.RFC3339)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,99 @@
+package export
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order/repository"
+)
+
+const defaultBatchSize = 500
+
+// Source streams stored orders. Implemented by repository.OrderRepository.
+type Source interface {
+	StreamOrders(ctx context.Context, f repository.OrderFilter, batchSize int, fn func(*order.Order) error) error
+}
+
+// Request describes one export. Delivery name, phone, email and address are
+// masked unless IncludePII is set.
+type Request struct {
+	Format     string
+	Columns    []Column
+	Filter     repository.OrderFilter
+	IncludePII bool
+}
+
+// NewRequest validates the format and resolves the columns, see Columns.
+func NewRequest(format, set, columns string, filter repository.OrderFilter) (Request, error) {
+	if format == "" {
+		format = FormatCSV
+	}
+	if format != FormatCSV && format != FormatJSONL && format != FormatParquet {
+		return Request{}, fmt.Errorf("unknown format %q, expected csv, jsonl or parquet", format)
+	}
+	cols, err := Columns(set, columns)
+	if err != nil {
+		return Request{}, err
+	}
+	return Request{Format: format, Columns: cols, Filter: filter}, nil
+}
+
+// Exporter writes orders from a Source in a streaming fashion.
+type Exporter struct {
+	source    Source
+	batchSize int
+}
+
+func NewExporter(source Source, batchSize int) *Exporter {
+	if batchSize < 1 {
+		batchSize = defaultBatchSize
+	}
+	return &Exporter{source: source, batchSize: batchSize}
+}
+
+// Export writes every matching order to w and returns the number of rows.
+// With item columns there is one row per item.
+func (e *Exporter) Export(ctx context.Context, w io.Writer, req Request) (int, error) {
+	const op = "export.Export"
+
+	rw, err := newRowWriter(req.Format, w, req.Columns)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows := 0
+	values := make([]any, len(req.Columns))
+	write := func(o *order.Order, it *order.Item) error {
+		for i, c := range req.Columns {
+			values[i] = c.value(o, it)
+			if c.mask != nil && !req.IncludePII {
+				values[i] = c.mask(values[i].(string))
+			}
+		}
+		rows++
+		return rw.WriteRow(values)
+	}
+
+	items := perItem(req.Columns)
+	err = e.source.StreamOrders(ctx, req.Filter, e.batchSize, func(o *order.Order) error {
+		if !items {
+			return write(o, nil)
+		}
+		for i := range o.Items {
+			if err := write(o, &o.Items[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return rows, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := rw.Close(); err != nil {
+		return rows, fmt.Errorf("%s: %w", op, err)
+	}
+	return rows, nil
+}
@@ -0,0 +1,174 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order/repository"
+	"github.com/parquet-go/parquet-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeSource struct {
+	orders    []order.Order
+	filter    repository.OrderFilter
+	batchSize int
+}
+
+func (s *fakeSource) StreamOrders(_ context.Context, f repository.OrderFilter, batchSize int, fn func(*order.Order) error) error {
+	s.filter, s.batchSize = f, batchSize
+	for i := range s.orders {
+		if err := fn(&s.orders[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func testOrders() []order.Order {
+	return []order.Order{
+		{
+			OrderUID:    "b563feb7b2b84b6test",
+			CustomerID:  "test",
+			DateCreated: time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+			Delivery:    order.Delivery{Name: "Test Testov", Phone: "+9720000000", Email: "test@gmail.com", City: "Kiryat Mozkin"},
+			Payment:     order.Payment{Currency: "USD", Amount: 1817},
+			Items: []order.Item{
+				{ChrtID: 9934930, Name: "Mascaras", Brand: "Vivienne Sabo", TotalPrice: 317},
+				{ChrtID: 9934931, Name: "Lipstick, red", Brand: "Vivienne Sabo", TotalPrice: 100},
+			},
+		},
+		{
+			OrderUID:    "a1",
+			CustomerID:  "c2",
+			DateCreated: time.Date(2021, 11, 27, 0, 0, 0, 0, time.UTC),
+			Payment:     order.Payment{Currency: "RUB", Amount: 500},
+		},
+	}
+}
+
+func export(t *testing.T, format, set, columns string) string {
+	t.Helper()
+	req, err := NewRequest(format, set, columns, repository.OrderFilter{})
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	_, err = NewExporter(&fakeSource{orders: testOrders()}, 0).Export(context.Background(), &buf, req)
+	require.NoError(t, err)
+	return buf.String()
+}
+
+func TestExport_CSV(t *testing.T) {
+	out := export(t, FormatCSV, "", "order_uid,date_created,payment_currency,payment_amount")
+
+	assert.Equal(t, "order_uid,date_created,payment_currency,payment_amount\n"+
+		"b563feb7b2b84b6test,2021-11-26T06:22:19Z,USD,1817\n"+
+		"a1,2021-11-27T00:00:00Z,RUB,500\n", out)
+}
+
+func TestExport_ItemRows(t *testing.T) {
+	out := export(t, FormatCSV, "", "order_uid,item_name,item_total_price")
+
+	// одна строка на товар, заказ без товаров не попадает в выгрузку
+	assert.Equal(t, "order_uid,item_name,item_total_price\n"+
+		"b563feb7b2b84b6test,Mascaras,317\n"+
+		"b563feb7b2b84b6test,\"Lipstick, red\",100\n", out)
+}
+
+func TestExport_JSONL(t *testing.T) {
+	out := export(t, FormatJSONL, "", "order_uid,payment_amount,date_created")
+
+	assert.Equal(t,
+		`{"order_uid":"b563feb7b2b84b6test","payment_amount":1817,"date_created":"2021-11-26T06:22:19Z"}`+"\n"+
+			`{"order_uid":"a1","payment_amount":500,"date_created":"2021-11-27T00:00:00Z"}`+"\n", out)
+}
+
+func TestExport_MasksPII(t *testing.T) {
+	cols := "order_uid,delivery_name,delivery_phone,delivery_email,delivery_city"
+	out := export(t, FormatCSV, "", cols)
+
+	assert.Equal(t, "order_uid,delivery_name,delivery_phone,delivery_email,delivery_city\n"+
+		"b563feb7b2b84b6test,T*** T***,+***00,t***@gmail.com,Kiryat Mozkin\n"+
+		"a1,,***,***,\n", out)
+
+	req, err := NewRequest(FormatCSV, "", cols, repository.OrderFilter{})
+	require.NoError(t, err)
+	req.IncludePII = true
+	var buf bytes.Buffer
+	_, err = NewExporter(&fakeSource{orders: testOrders()}, 0).Export(context.Background(), &buf, req)
+	require.NoError(t, err)
+	assert.Contains(t, buf.String(), "b563feb7b2b84b6test,Test Testov,+9720000000,test@gmail.com,Kiryat Mozkin\n")
+}
+
+func TestExport_Parquet(t *testing.T) {
+	out := export(t, FormatParquet, "items", "")
+
+	type itemRow struct {
+		OrderUID    string    `parquet:"order_uid"`
+		DateCreated time.Time `parquet:"date_created,timestamp(microsecond)"`
+		ItemName    string    `parquet:"item_name"`
+		ItemBrand   string    `parquet:"item_brand"`
+		TotalPrice  int64     `parquet:"item_total_price"`
+	}
+	rows, err := parquet.Read[itemRow](bytes.NewReader([]byte(out)), int64(len(out)))
+	require.NoError(t, err)
+
+	require.Len(t, rows, 2)
+	assert.Equal(t, "b563feb7b2b84b6test", rows[0].OrderUID)
+	assert.True(t, rows[0].DateCreated.Equal(time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC)))
+	assert.Equal(t, "Lipstick, red", rows[1].ItemName)
+	assert.Equal(t, "Vivienne Sabo", rows[1].ItemBrand)
+	assert.Equal(t, int64(100), rows[1].TotalPrice)
+}
+
+func TestNewRequest_Invalid(t *testing.T) {
+	tests := []struct {
+		name, format, set, columns string
+	}{
+		{name: "format", format: "xlsx"},
+		{name: "set", set: "customers"},
+		{name: "column", columns: "order_uid,password"},
+		{name: "duplicate", columns: "order_uid,order_uid"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := NewRequest(test.format, test.set, test.columns, repository.OrderFilter{})
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestHandler_Export(t *testing.T) {
+	source := &fakeSource{orders: testOrders()}
+	h := NewHandler(NewExporter(source, 100))
+
+	w := httptest.NewRecorder()
+	h.ExportHandler(w, httptest.NewRequest("GET", "/orders/export?format=jsonl&set=payments&from=2021-11-01&customer_id=test", nil))
+
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Header().Get("Content-Disposition"), ".jsonl")
+	assert.Equal(t, repository.OrderFilter{
+		From:       time.Date(2021, 11, 1, 0, 0, 0, 0, time.UTC),
+		CustomerID: "test",
+	}, source.filter)
+	assert.Equal(t, 100, source.batchSize)
+
+	w = httptest.NewRecorder()
+	h.ExportHandler(w, httptest.NewRequest("GET", "/orders/export?to=tomorrow", nil))
+	assert.Equal(t, 400, w.Code)
+
+	w = httptest.NewRecorder()
+	h.ExportHandler(w, httptest.NewRequest("GET", "/orders/export?columns=delivery_email&include_pii=true", nil))
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), "test@gmail.com")
+
+	w = httptest.NewRecorder()
+	h.ExportHandler(w, httptest.NewRequest("GET", "/orders/export?include_pii=maybe", nil))
+	assert.Equal(t, 400, w.Code)
+}
@@ -0,0 +1,99 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order/repository"
+)
+
+// writeTimeout is granted again on every write, so long exports are not cut
+// off by the server WriteTimeout while a slow client still is.
+const writeTimeout = 30 * time.Second
+
+type Handler struct {
+	exporter *Exporter
+}
+
+func NewHandler(exporter *Exporter) *Handler {
+	return &Handler{exporter: exporter}
+}
+
+// ExportHandler streams orders:
+//
+//	GET /orders/export?format=csv|jsonl|parquet&set=orders|payments|items&columns=...&from=...&to=...&customer_id=...&include_pii=true
+//
+// Delivery name, phone, email and address are masked unless include_pii is
+// set, the request is then logged with include_pii.
+// An error after the first byte can only be reported by closing the
+// connection, the client then gets a truncated file.
+func (h *Handler) ExportHandler(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
+
+	filter := repository.OrderFilter{CustomerID: values.Get("customer_id")}
+	var err error
+	if v := values.Get("from"); v != "" {
+		if filter.From, err = ParseTime(v); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid from: %v", err))
+			return
+		}
+	}
+	if v := values.Get("to"); v != "" {
+		if filter.To, err = ParseTime(v); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid to: %v", err))
+			return
+		}
+	}
+
+	req, err := NewRequest(values.Get("format"), values.Get("set"), values.Get("columns"), filter)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if v := values.Get("include_pii"); v != "" {
+		if req.IncludePII, err = strconv.ParseBool(v); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid include_pii: %v", err))
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", ContentType(req.Format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="orders-%s.%s"`,
+		time.Now().UTC().Format("20060102T150405Z"), req.Format))
+	w.WriteHeader(http.StatusOK)
+
+	rows, err := h.exporter.Export(r.Context(), &deadlineWriter{w: w, rc: http.NewResponseController(w)}, req)
+	if err != nil {
+		slog.Error("order export failed", "error", err, "rows", rows)
+		panic(http.ErrAbortHandler)
+	}
+	slog.Info("orders exported", "format", req.Format, "rows", rows, "include_pii", req.IncludePII)
+}
+
+type deadlineWriter struct {
+	w  http.ResponseWriter
+	rc *http.ResponseController
+}
+
+func (d *deadlineWriter) Write(p []byte) (int, error) {
+	d.rc.SetWriteDeadline(time.Now().Add(writeTimeout))
+	return d.w.Write(p)
+}
+
+// ParseTime accepts YYYY-MM-DD or RFC 3339.
+func ParseTime(v string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, v); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
@@ -0,0 +1,177 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+const (
+	FormatCSV     = "csv"
+	FormatJSONL   = "jsonl"
+	FormatParquet = "parquet"
+)
+
+// ContentType is the MIME type of an export format.
+func ContentType(format string) string {
+	switch format {
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatJSONL:
+		return "application/x-ndjson"
+	default:
+		return "application/vnd.apache.parquet"
+	}
+}
+
+// rowWriter encodes rows of column values in one format.
+type rowWriter interface {
+	WriteRow(values []any) error
+	// Close flushes buffered rows. It does not close the underlying writer.
+	Close() error
+}
+
+func newRowWriter(format string, w io.Writer, cols []Column) (rowWriter, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVWriter(w, cols)
+	case FormatJSONL:
+		return &jsonlWriter{enc: json.NewEncoder(w), cols: cols}, nil
+	case FormatParquet:
+		return newParquetWriter(w, cols), nil
+	default:
+		return nil, fmt.Errorf("unknown format %q, expected csv, jsonl or parquet", format)
+	}
+}
+
+type csvWriter struct {
+	w      *csv.Writer
+	record []string
+}
+
+func newCSVWriter(w io.Writer, cols []Column) (*csvWriter, error) {
+	cw := csv.NewWriter(w)
+	header := make([]string, len(cols))
+	for i, c := range cols {
+		header[i] = c.Name
+	}
+	if err := cw.Write(header); err != nil {
+		return nil, err
+	}
+	return &csvWriter{w: cw, record: make([]string, len(cols))}, nil
+}
+
+func (c *csvWriter) WriteRow(values []any) error {
+	for i, v := range values {
+		c.record[i] = formatValue(v)
+	}
+	return c.w.Write(c.record)
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// jsonlWriter writes one JSON object per line, keys in column order.
+type jsonlWriter struct {
+	enc  *json.Encoder
+	cols []Column
+}
+
+func (j *jsonlWriter) WriteRow(values []any) error {
+	row := make(orderedRow, len(values))
+	for i, v := range values {
+		row[i] = field{j.cols[i].Name, v}
+	}
+	return j.enc.Encode(row)
+}
+
+func (j *jsonlWriter) Close() error {
+	return nil
+}
+
+type field struct {
+	name  string
+	value any
+}
+
+type orderedRow []field
+
+func (r orderedRow) MarshalJSON() ([]byte, error) {
+	buf := []byte{'{'}
+	for i, f := range r {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		key, _ := json.Marshal(f.name)
+		value, err := json.Marshal(f.value)
+		if err != nil {
+			return nil, err
+		}
+		buf = append(append(append(buf, key...), ':'), value...)
+	}
+	return append(buf, '}'), nil
+}
+
+// parquetWriter writes a flat schema with one required leaf per column.
+// Parquet orders the leaves of a group by name, so each value is placed at
+// the index of its column in the schema.
+type parquetWriter struct {
+	w       *parquet.Writer
+	indexes []int
+	row     parquet.Row
+}
+
+func newParquetWriter(w io.Writer, cols []Column) *parquetWriter {
+	group := make(parquet.Group, len(cols))
+	for _, c := range cols {
+		switch c.kind {
+		case kindInt:
+			group[c.Name] = parquet.Int(64)
+		case kindTime:
+			group[c.Name] = parquet.Timestamp(parquet.Microsecond)
+		default:
+			group[c.Name] = parquet.String()
+		}
+	}
+	schema := parquet.NewSchema("order", group)
+
+	indexes := make([]int, len(cols))
+	for i, c := range cols {
+		leaf, _ := schema.Lookup(c.Name)
+		indexes[i] = leaf.ColumnIndex
+	}
+	return &parquetWriter{
+		w:       parquet.NewWriter(w, schema),
+		indexes: indexes,
+		row:     make(parquet.Row, len(cols)),
+	}
+}
+
+func (p *parquetWriter) WriteRow(values []any) error {
+	for i, v := range values {
+		var value parquet.Value
+		switch v := v.(type) {
+		case int64:
+			value = parquet.Int64Value(v)
+		case time.Time:
+			value = parquet.Int64Value(v.UnixMicro())
+		case string:
+			value = parquet.ByteArrayValue([]byte(v))
+		default:
+			return fmt.Errorf("unsupported parquet value %T", v)
+		}
+		p.row[p.indexes[i]] = value.Level(0, 0, p.indexes[i])
+	}
+	_, err := p.w.WriteRows([]parquet.Row{p.row})
+	return err
+}
+
+func (p *parquetWriter) Close() error {
+	return p.w.Close()
+}
@@ -0,0 +1,117 @@
+package fieldcrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order"
+)
+
+// prefix marks encrypted values, so rows written before encryption was
+// enabled can still be read as plain text.
+const prefix = "enc:v1:"
+
+var ErrInvalidKey = errors.New("encryption key must be 32 bytes (base64 encoded)")
+
+// Cipher encrypts single column values with AES-256-GCM.
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+func New(key []byte) (*Cipher, error) {
+	if len(key) != 32 {
+		return nil, ErrInvalidKey
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Cipher{aead: aead}, nil
+}
+
+// FromBase64 decodes a key from config. An empty key disables encryption
+// and returns a nil Cipher.
+func FromBase64(key string) (*Cipher, error) {
+	if key == "" {
+		return nil, nil
+	}
+	raw, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidKey, err)
+	}
+	return New(raw)
+}
+
+func (c *Cipher) Encrypt(plain string) (string, error) {
+	if plain == "" {
+		return "", nil
+	}
+
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := c.aead.Seal(nonce, nonce, []byte(plain), nil)
+	return prefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (c *Cipher) Decrypt(value string) (string, error) {
+	encoded, ok := strings.CutPrefix(value, prefix)
+	if !ok {
+		return value, nil
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", err
+	}
+	size := c.aead.NonceSize()
+	if len(sealed) < size {
+		return "", errors.New("encrypted value is too short")
+	}
+	plain, err := c.aead.Open(nil, sealed[:size], sealed[size:], nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+// SealDelivery returns d with its PII fields encrypted. A nil Cipher returns
+// d unchanged.
+func (c *Cipher) SealDelivery(d order.Delivery) (order.Delivery, error) {
+	if c == nil {
+		return d, nil
+	}
+	for _, field := range []*string{&d.Name, &d.Phone, &d.Email, &d.Address} {
+		enc, err := c.Encrypt(*field)
+		if err != nil {
+			return d, err
+		}
+		*field = enc
+	}
+	return d, nil
+}
+
+// OpenDelivery decrypts the PII fields of d in place.
+func (c *Cipher) OpenDelivery(d *order.Delivery) error {
+	if c == nil {
+		return nil
+	}
+	for _, field := range []*string{&d.Name, &d.Phone, &d.Email, &d.Address} {
+		plain, err := c.Decrypt(*field)
+		if err != nil {
+			return err
+		}
+		*field = plain
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package fieldcrypt
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCipher_RoundTrip(t *testing.T) {
+	c, err := FromBase64(base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, 32)))
+	require.NoError(t, err)
+
+	enc, err := c.Encrypt("test@gmail.com")
+	require.NoError(t, err)
+	assert.NotContains(t, enc, "test@gmail.com")
+
+	again, err := c.Encrypt("test@gmail.com")
+	require.NoError(t, err)
+	assert.NotEqual(t, enc, again, "nonce must be random")
+
+	plain, err := c.Decrypt(enc)
+	require.NoError(t, err)
+	assert.Equal(t, "test@gmail.com", plain)
+
+	legacy, err := c.Decrypt("Ploshad Mira 15")
+	require.NoError(t, err)
+	assert.Equal(t, "Ploshad Mira 15", legacy)
+}
+
+func TestCipher_WrongKey(t *testing.T) {
+	a, err := New(bytes.Repeat([]byte{1}, 32))
+	require.NoError(t, err)
+	b, err := New(bytes.Repeat([]byte{2}, 32))
+	require.NoError(t, err)
+
+	enc, err := a.Encrypt("+9720000000")
+	require.NoError(t, err)
+	_, err = b.Decrypt(enc)
+	assert.Error(t, err)
+
+	_, err = FromBase64("c2hvcnQ=")
+	assert.ErrorIs(t, err, ErrInvalidKey)
+
+	none, err := FromBase64("")
+	require.NoError(t, err)
+	assert.Nil(t, none)
+}
@@ -0,0 +1,82 @@
+package grpcapi
+
+import (
+	"time"
+
+	orderv1 "github.com/Egor-Pomidor-pdf/order-service/api/proto/order/v1"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func toProto(o *order.Order) *orderv1.Order {
+	items := make([]*orderv1.Item, len(o.Items))
+	for i, it := range o.Items {
+		items[i] = &orderv1.Item{
+			ChrtId:      int64(it.ChrtID),
+			TrackNumber: it.TrackNumber,
+			Price:       int64(it.Price),
+			Rid:         it.RID,
+			Name:        it.Name,
+			Sale:        int64(it.Sale),
+			Size:        it.Size,
+			TotalPrice:  int64(it.TotalPrice),
+			NmId:        int64(it.NmID),
+			Brand:       it.Brand,
+			Status:      int64(it.Status),
+		}
+	}
+
+	return &orderv1.Order{
+		OrderUid:    o.OrderUID,
+		TrackNumber: o.TrackNumber,
+		Entry:       o.Entry,
+		Delivery: &orderv1.Delivery{
+			Name:    o.Delivery.Name,
+			Phone:   o.Delivery.Phone,
+			Zip:     o.Delivery.Zip,
+			City:    o.Delivery.City,
+			Address: o.Delivery.Address,
+			Region:  o.Delivery.Region,
+			Email:   o.Delivery.Email,
+		},
+		Payment: &orderv1.Payment{
+			Transaction:  o.Payment.Transaction,
+			RequestId:    o.Payment.RequestID,
+			Currency:     o.Payment.Currency,
+			Provider:     o.Payment.Provider,
+			Amount:       int64(o.Payment.Amount),
+			PaymentDt:    o.Payment.PaymentDT,
+			Bank:         o.Payment.Bank,
+			DeliveryCost: int64(o.Payment.DeliveryCost),
+			GoodsTotal:   int64(o.Payment.GoodsTotal),
+			CustomFee:    int64(o.Payment.CustomFee),
+		},
+		Items:             items,
+		Locale:            o.Locale,
+		CustomerId:        o.CustomerID,
+		InternalSignature: o.InternalSignature,
+		DeliveryService:   o.DeliveryService,
+		Shardkey:          o.ShardKey,
+		SmId:              int64(o.SMID),
+		DateCreated:       timestamppb.New(o.DateCreated),
+		OofShard:          o.OOFShard,
+		Version:           int64(o.Version),
+		IngestedAt:        timestamp(o.IngestedAt),
+	}
+}
+
+func timestamp(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
+
+// fromTimestamp maps an unset filter bound to the zero time, which does not
+// filter.
+func fromTimestamp(ts *timestamppb.Timestamp) time.Time {
+	if ts == nil {
+		return time.Time{}
+	}
+	return ts.AsTime()
+}
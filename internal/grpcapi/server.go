@@ -0,0 +1,195 @@
+// Package grpcapi serves the order.v1.OrderService gRPC API next to the HTTP
+// one. It shares the order service and its cache, the authenticators and the
+// redaction policy with the HTTP API.
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	orderv1 "github.com/Egor-Pomidor-pdf/order-service/api/proto/order/v1"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/auth"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/config"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order/repository"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order/watch"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/redact"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/reflection"
+	reflectionv1 "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	reflectionv1alpha "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	defaultSearchLimit = 100
+	maxSearchLimit     = 1000
+)
+
+// OrderService is what the gRPC API needs from the order service,
+// implemented by service.OrderService.
+type OrderService interface {
+	GetOrder(ctx context.Context, uid string) (*order.Order, error)
+	ListOrders(ctx context.Context, f repository.OrderFilter, fn func(*order.Order) error) error
+	SearchOrders(ctx context.Context, f repository.OrderFilter, limit int) ([]order.Order, error)
+	Watch(buffer int, match func(*order.Order) bool) *watch.Subscription
+}
+
+type Server struct {
+	orderv1.UnimplementedOrderServiceServer
+	orders    OrderService
+	redaction *redact.Policy
+	buffer    int
+}
+
+// methodRoles are the roles of the API methods. ListOrders streams every
+// matching order without a limit, like GET /orders/export it is for admins.
+var methodRoles = auth.MethodRoles{
+	orderv1.OrderService_GetOrder_FullMethodName:                           auth.RoleReader,
+	orderv1.OrderService_SearchOrders_FullMethodName:                       auth.RoleReader,
+	orderv1.OrderService_WatchOrders_FullMethodName:                        auth.RoleReader,
+	orderv1.OrderService_ListOrders_FullMethodName:                         auth.RoleAdmin,
+	reflectionv1.ServerReflection_ServerReflectionInfo_FullMethodName:      auth.RoleReader,
+	reflectionv1alpha.ServerReflection_ServerReflectionInfo_FullMethodName: auth.RoleReader,
+}
+
+// NewServer builds the gRPC server with the roles of methodRoles, auth and
+// redaction are configured by the HTTP server config.
+func NewServer(cfg config.ServerConfig, orders OrderService, authenticator auth.Authenticator) *grpc.Server {
+	srv := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(auth.UnaryInterceptor(authenticator, methodRoles)),
+		grpc.ChainStreamInterceptor(auth.StreamInterceptor(authenticator, methodRoles)),
+	)
+	orderv1.RegisterOrderServiceServer(srv, &Server{orders: orders, redaction: redact.NewPolicy(cfg.Redaction), buffer: cfg.Stream.Buffer})
+	// для grpcurl и других клиентов без .proto
+	reflection.Register(srv)
+	return srv
+}
+
+func (s *Server) GetOrder(ctx context.Context, req *orderv1.GetOrderRequest) (*orderv1.GetOrderResponse, error) {
+	if req.GetOrderUid() == "" {
+		return nil, status.Error(codes.InvalidArgument, "order_uid is required")
+	}
+
+	o, err := s.orders.GetOrder(ctx, req.GetOrderUid())
+	if err != nil {
+		slog.Error("failed to get order", "error", err, "order_uid", req.GetOrderUid())
+		return nil, status.Error(codes.Internal, "Internal server error")
+	}
+	if o == nil {
+		return nil, status.Error(codes.NotFound, "Order not found")
+	}
+	return &orderv1.GetOrderResponse{Order: s.toProto(ctx, o)}, nil
+}
+
+func (s *Server) ListOrders(req *orderv1.ListOrdersRequest, stream grpc.ServerStreamingServer[orderv1.ListOrdersResponse]) error {
+	f, err := filter(req.GetFrom(), req.GetTo(), req.GetCustomerId(), "")
+	if err != nil {
+		return err
+	}
+
+	ctx := stream.Context()
+	var sendErr error
+	err = s.orders.ListOrders(ctx, f, func(o *order.Order) error {
+		sendErr = stream.Send(&orderv1.ListOrdersResponse{Order: s.toProto(ctx, o)})
+		return sendErr
+	})
+	// ошибка отправки означает, что клиент ушел, ее статус уже задан gRPC
+	if sendErr != nil {
+		return sendErr
+	}
+	if err != nil {
+		slog.Error("failed to list orders", "error", err)
+		return status.Error(codes.Internal, "Internal server error")
+	}
+	return nil
+}
+
+func (s *Server) SearchOrders(ctx context.Context, req *orderv1.SearchOrdersRequest) (*orderv1.SearchOrdersResponse, error) {
+	f, err := filter(req.GetFrom(), req.GetTo(), req.GetCustomerId(), req.GetTrackNumber())
+	if err != nil {
+		return nil, err
+	}
+	limit := int(req.GetLimit())
+	switch {
+	case limit < 0:
+		return nil, status.Error(codes.InvalidArgument, "limit must not be negative")
+	case limit == 0:
+		limit = defaultSearchLimit
+	case limit > maxSearchLimit:
+		limit = maxSearchLimit
+	}
+
+	orders, err := s.orders.SearchOrders(ctx, f, limit)
+	if err != nil {
+		slog.Error("failed to search orders", "error", err)
+		return nil, status.Error(codes.Internal, "Internal server error")
+	}
+	resp := &orderv1.SearchOrdersResponse{Orders: make([]*orderv1.Order, len(orders))}
+	for i := range orders {
+		resp.Orders[i] = s.toProto(ctx, &orders[i])
+	}
+	return resp, nil
+}
+
+func (s *Server) WatchOrders(req *orderv1.WatchOrdersRequest, stream grpc.ServerStreamingServer[orderv1.WatchOrdersResponse]) error {
+	var match func(*order.Order) bool
+	if customerID := req.GetCustomerId(); customerID != "" {
+		match = func(o *order.Order) bool { return o.CustomerID == customerID }
+	}
+	sub := s.orders.Watch(s.buffer, match)
+	defer sub.Close()
+	// заголовки уходят после подписки: клиент, дождавшийся Header(), не пропустит заказы
+	if err := stream.SendHeader(nil); err != nil {
+		return err
+	}
+
+	ctx := stream.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case ev, ok := <-sub.Events():
+			if !ok {
+				return watchError(sub.Err())
+			}
+			if err := stream.Send(&orderv1.WatchOrdersResponse{Order: s.toProto(ctx, &ev.Order)}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func watchError(err error) error {
+	switch {
+	case errors.Is(err, watch.ErrSlowSubscriber):
+		return status.Error(codes.ResourceExhausted, "client is too slow, reconnect")
+	case errors.Is(err, watch.ErrClosed):
+		return status.Error(codes.Unavailable, "server is shutting down")
+	}
+	return nil
+}
+
+// toProto masks PII for callers who may not see it.
+func (s *Server) toProto(ctx context.Context, o *order.Order) *orderv1.Order {
+	return toProto(s.redaction.Order(auth.FromContext(ctx), o))
+}
+
+func filter(from, to *timestamppb.Timestamp, customerID, trackNumber string) (repository.OrderFilter, error) {
+	for _, ts := range []*timestamppb.Timestamp{from, to} {
+		if ts != nil {
+			if err := ts.CheckValid(); err != nil {
+				return repository.OrderFilter{}, status.Error(codes.InvalidArgument, err.Error())
+			}
+		}
+	}
+	return repository.OrderFilter{
+		From:        fromTimestamp(from),
+		To:          fromTimestamp(to),
+		CustomerID:  customerID,
+		TrackNumber: trackNumber,
+	}, nil
+}
@@ -0,0 +1,248 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	orderv1 "github.com/Egor-Pomidor-pdf/order-service/api/proto/order/v1"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/auth"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/config"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order/repository"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order/watch"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type fakeOrders struct {
+	orders []order.Order
+	err    error
+	hub    *watch.Hub
+
+	filter repository.OrderFilter
+	limit  int
+}
+
+func (f *fakeOrders) GetOrder(_ context.Context, uid string) (*order.Order, error) {
+	for i := range f.orders {
+		if f.orders[i].OrderUID == uid {
+			return &f.orders[i], f.err
+		}
+	}
+	return nil, f.err
+}
+
+func (f *fakeOrders) ListOrders(_ context.Context, filter repository.OrderFilter, fn func(*order.Order) error) error {
+	f.filter = filter
+	for i := range f.orders {
+		if err := fn(&f.orders[i]); err != nil {
+			return err
+		}
+	}
+	return f.err
+}
+
+func (f *fakeOrders) SearchOrders(_ context.Context, filter repository.OrderFilter, limit int) ([]order.Order, error) {
+	f.filter, f.limit = filter, limit
+	return f.orders[:min(limit, len(f.orders))], f.err
+}
+
+func (f *fakeOrders) Watch(buffer int, match func(*order.Order) bool) *watch.Subscription {
+	return f.hub.Subscribe(buffer, match)
+}
+
+func testOrder(uid, customerID string) order.Order {
+	ingested := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	return order.Order{
+		OrderUID:    uid,
+		TrackNumber: "WBILMTESTTRACK",
+		CustomerID:  customerID,
+		Delivery:    order.Delivery{Name: "Test Testov", Phone: "+9720000000"},
+		Payment:     order.Payment{Transaction: uid, Amount: 1817},
+		Items:       []order.Item{{ChrtID: 9934930, Price: 453, Brand: "Vivienne Sabo"}},
+		DateCreated: time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC),
+		Version:     2,
+		IngestedAt:  &ingested,
+	}
+}
+
+// newClient serves the API on an in-memory listener. Auth is enabled with
+// a reader key, a pii key and an admin key.
+func newClient(t *testing.T, orders *fakeOrders) orderv1.OrderServiceClient {
+	t.Helper()
+	authenticator, err := auth.New(config.AuthConfig{
+		Enabled: true,
+		APIKeys: []string{"reader:reader-key:reader", "support:pii-key:reader|pii", "ops:admin-key:reader|admin"},
+	})
+	require.NoError(t, err)
+	cfg := config.ServerConfig{Redaction: config.RedactionConfig{Enabled: true, UnmaskedRoles: []string{"pii"}}}
+
+	listener := bufconn.Listen(1 << 20)
+	srv := NewServer(cfg, orders, authenticator)
+	go srv.Serve(listener)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return orderv1.NewOrderServiceClient(conn)
+}
+
+func withKey(key string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "x-api-key", key)
+}
+
+func TestGetOrder(t *testing.T) {
+	client := newClient(t, &fakeOrders{orders: []order.Order{testOrder("a1", "c1")}})
+
+	resp, err := client.GetOrder(withKey("pii-key"), &orderv1.GetOrderRequest{OrderUid: "a1"})
+	require.NoError(t, err)
+	got := resp.GetOrder()
+	assert.Equal(t, "a1", got.GetOrderUid())
+	assert.Equal(t, "+9720000000", got.GetDelivery().GetPhone())
+	assert.Equal(t, int64(2), got.GetVersion())
+	assert.Equal(t, int64(453), got.GetItems()[0].GetPrice())
+	assert.Equal(t, time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC), got.GetDateCreated().AsTime())
+	assert.NotNil(t, got.GetIngestedAt())
+
+	// без роли pii телефон маскируется, как в HTTP API
+	resp, err = client.GetOrder(withKey("reader-key"), &orderv1.GetOrderRequest{OrderUid: "a1"})
+	require.NoError(t, err)
+	assert.NotEqual(t, "+9720000000", resp.GetOrder().GetDelivery().GetPhone())
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		uid  string
+		code codes.Code
+	}{
+		{"not found", withKey("reader-key"), "missing", codes.NotFound},
+		{"empty uid", withKey("reader-key"), "", codes.InvalidArgument},
+		{"no credentials", context.Background(), "a1", codes.Unauthenticated},
+		{"wrong key", withKey("wrong"), "a1", codes.Unauthenticated},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := client.GetOrder(test.ctx, &orderv1.GetOrderRequest{OrderUid: test.uid})
+			assert.Equal(t, test.code, status.Code(err))
+		})
+	}
+}
+
+func TestGetOrder_ServiceError(t *testing.T) {
+	client := newClient(t, &fakeOrders{err: errors.New("connection refused")})
+
+	_, err := client.GetOrder(withKey("reader-key"), &orderv1.GetOrderRequest{OrderUid: "a1"})
+	assert.Equal(t, codes.Internal, status.Code(err))
+	assert.NotContains(t, err.Error(), "connection refused")
+}
+
+func TestListOrders(t *testing.T) {
+	orders := &fakeOrders{orders: []order.Order{testOrder("a1", "c1"), testOrder("a2", "c1")}}
+	client := newClient(t, orders)
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	stream, err := client.ListOrders(withKey("admin-key"), &orderv1.ListOrdersRequest{
+		From:       timestamppb.New(from),
+		CustomerId: "c1",
+	})
+	require.NoError(t, err)
+	var uids []string
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		uids = append(uids, resp.GetOrder().GetOrderUid())
+	}
+	assert.Equal(t, []string{"a1", "a2"}, uids)
+	assert.Equal(t, repository.OrderFilter{From: from, CustomerID: "c1"}, orders.filter)
+
+	stream, err = client.ListOrders(withKey("admin-key"), &orderv1.ListOrdersRequest{
+		To: &timestamppb.Timestamp{Nanos: -1},
+	})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// выгрузка всех заказов только для admin, как GET /orders/export
+	stream, err = client.ListOrders(withKey("pii-key"), &orderv1.ListOrdersRequest{})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+func TestSearchOrders(t *testing.T) {
+	orders := &fakeOrders{orders: []order.Order{testOrder("a1", "c1"), testOrder("a2", "c2")}}
+	client := newClient(t, orders)
+
+	resp, err := client.SearchOrders(withKey("reader-key"), &orderv1.SearchOrdersRequest{TrackNumber: "WBILMTESTTRACK"})
+	require.NoError(t, err)
+	assert.Len(t, resp.GetOrders(), 2)
+	assert.Equal(t, defaultSearchLimit, orders.limit)
+	assert.Equal(t, "WBILMTESTTRACK", orders.filter.TrackNumber)
+
+	resp, err = client.SearchOrders(withKey("reader-key"), &orderv1.SearchOrdersRequest{Limit: 1})
+	require.NoError(t, err)
+	assert.Len(t, resp.GetOrders(), 1)
+
+	_, err = client.SearchOrders(withKey("reader-key"), &orderv1.SearchOrdersRequest{Limit: 5000})
+	require.NoError(t, err)
+	assert.Equal(t, maxSearchLimit, orders.limit)
+
+	_, err = client.SearchOrders(withKey("reader-key"), &orderv1.SearchOrdersRequest{Limit: -1})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestWatchOrders(t *testing.T) {
+	hub := watch.NewHub(0)
+	client := newClient(t, &fakeOrders{hub: hub})
+
+	stream, err := client.WatchOrders(withKey("reader-key"), &orderv1.WatchOrdersRequest{CustomerId: "c1"})
+	require.NoError(t, err)
+	// заголовки приходят после подписки
+	_, err = stream.Header()
+	require.NoError(t, err)
+	require.Equal(t, 1, hub.Subscribers())
+
+	hub.Publish(testOrder("a1", "c2"))
+	hub.Publish(testOrder("a2", "c1"))
+	resp, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, "a2", resp.GetOrder().GetOrderUid())
+	assert.NotEqual(t, "+9720000000", resp.GetOrder().GetDelivery().GetPhone())
+
+	hub.Close()
+	_, err = stream.Recv()
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+}
+
+func TestWatchOrders_ClientCancel(t *testing.T) {
+	hub := watch.NewHub(0)
+	client := newClient(t, &fakeOrders{hub: hub})
+
+	ctx, cancel := context.WithCancel(withKey("reader-key"))
+	stream, err := client.WatchOrders(ctx, &orderv1.WatchOrdersRequest{})
+	require.NoError(t, err)
+	_, err = stream.Header()
+	require.NoError(t, err)
+
+	// подписка снимается, когда клиент уходит
+	cancel()
+	assert.Eventually(t, func() bool { return hub.Subscribers() == 0 }, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,61 @@
+package importer
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Checkpoint records how far an import got. Records up to and including
+// Position are written or rejected, Stats are the totals so far.
+type Checkpoint struct {
+	File      string    `json:"file"`
+	Size      int64     `json:"size"`
+	Position  int       `json:"position"`
+	Stats     Stats     `json:"stats"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// LoadCheckpoint reads a checkpoint, a missing file yields a zero Checkpoint.
+func LoadCheckpoint(path string) (Checkpoint, error) {
+	var cp Checkpoint
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cp, nil
+	}
+	if err != nil {
+		return cp, err
+	}
+	err = json.Unmarshal(data, &cp)
+	return cp, err
+}
+
+// SaveCheckpoint replaces the checkpoint file atomically, an interrupted
+// write leaves the previous checkpoint in place.
+func SaveCheckpoint(path string, cp Checkpoint) error {
+	data, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
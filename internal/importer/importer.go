@@ -0,0 +1,156 @@
+package importer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order/handler"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order/repository"
+)
+
+const defaultBatchSize = 500
+
+// Store writes a batch of new orders, see repository.OrderRepository.SaveOrders.
+type Store interface {
+	SaveOrders(ctx context.Context, orders []order.Order) ([]error, error)
+}
+
+type Stats struct {
+	Records    int `json:"records"`
+	Imported   int `json:"imported"`
+	Duplicates int `json:"duplicates"`
+	Rejected   int `json:"rejected"`
+}
+
+// Reject is one line of the reject file.
+type Reject struct {
+	Position int    `json:"position"`
+	OrderUID string `json:"order_uid,omitempty"`
+	Error    string `json:"error"`
+	Record   string `json:"record"`
+}
+
+type Importer struct {
+	store     Store
+	batchSize int
+}
+
+func NewImporter(store Store, batchSize int) *Importer {
+	if batchSize < 1 {
+		batchSize = defaultBatchSize
+	}
+	return &Importer{store: store, batchSize: batchSize}
+}
+
+// Import reads orders of format from r, validates them like the Kafka
+// consumer and writes them in batches. Records up to cp.Position are skipped.
+// After every batch its rejects go to rejects and save is called with the
+// advanced checkpoint, so a rerun continues after the last saved batch.
+// Orders that already exist are counted as duplicates, not rejected: a
+// resumed batch may have been written before the interruption.
+func (im *Importer) Import(ctx context.Context, r io.Reader, format string, rejects io.Writer, cp Checkpoint, save func(Checkpoint) error) (Stats, error) {
+	const op = "importer.Import"
+
+	reader, err := newReader(format, r)
+	if err != nil {
+		return cp.Stats, fmt.Errorf("%s: %w", op, err)
+	}
+
+	enc := json.NewEncoder(rejects)
+	var (
+		records []record
+		orders  []order.Order
+		failed  []Reject
+		last    int
+	)
+
+	flush := func() error {
+		var errs []error
+		if len(orders) > 0 {
+			if errs, err = im.store.SaveOrders(ctx, orders); err != nil {
+				return err
+			}
+		}
+		for i, err := range errs {
+			switch {
+			case err == nil:
+				cp.Stats.Imported++
+			case errors.Is(err, repository.ErrOrderExists):
+				cp.Stats.Duplicates++
+			default:
+				failed = append(failed, reject(records[i], orders[i].OrderUID, fmt.Errorf("DATABASE_ERROR: %w", err)))
+			}
+		}
+		for _, rej := range failed {
+			if err := enc.Encode(rej); err != nil {
+				return err
+			}
+		}
+		cp.Stats.Rejected += len(failed)
+		cp.Position = last
+		cp.UpdatedAt = time.Now().UTC()
+		if err := save(cp); err != nil {
+			return err
+		}
+		slog.Info("import batch written", "position", last, "imported", cp.Stats.Imported,
+			"duplicates", cp.Stats.Duplicates, "rejected", cp.Stats.Rejected)
+
+		records, orders, failed = records[:0], orders[:0], failed[:0]
+		return nil
+	}
+
+	pending := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return cp.Stats, fmt.Errorf("%s: %w", op, err)
+		}
+		rec, err := reader.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return cp.Stats, fmt.Errorf("%s: %w", op, err)
+		}
+		if rec.pos <= cp.Position {
+			continue
+		}
+		cp.Stats.Records++
+		last = rec.pos
+		pending++
+
+		if rec.err == nil {
+			var o order.Order
+			if o, rec.err = handler.DecodeOrder(rec.data); rec.err == nil {
+				records = append(records, rec)
+				orders = append(orders, o)
+			} else {
+				failed = append(failed, reject(rec, o.OrderUID, rec.err))
+			}
+		} else {
+			failed = append(failed, reject(rec, "", rec.err))
+		}
+
+		if pending == im.batchSize {
+			if err := flush(); err != nil {
+				return cp.Stats, fmt.Errorf("%s: %w", op, err)
+			}
+			pending = 0
+		}
+	}
+	if pending > 0 {
+		if err := flush(); err != nil {
+			return cp.Stats, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	return cp.Stats, nil
+}
+
+func reject(rec record, uid string, err error) Reject {
+	return Reject{Position: rec.pos, OrderUID: uid, Error: err.Error(), Record: rec.raw}
+}
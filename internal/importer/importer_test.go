@@ -0,0 +1,201 @@
+package importer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeStore struct {
+	saved   map[string]order.Order
+	batches int
+	fail    error
+}
+
+func (s *fakeStore) SaveOrders(_ context.Context, orders []order.Order) ([]error, error) {
+	if s.fail != nil {
+		return nil, s.fail
+	}
+	if s.saved == nil {
+		s.saved = make(map[string]order.Order)
+	}
+	s.batches++
+	errs := make([]error, len(orders))
+	for i, o := range orders {
+		if _, ok := s.saved[o.OrderUID]; ok {
+			errs[i] = repository.ErrOrderExists
+			continue
+		}
+		s.saved[o.OrderUID] = o
+	}
+	return errs, nil
+}
+
+func orderJSON(uid string) string {
+	return `{"order_uid":"` + uid + `","track_number":"WBILMTESTTRACK","entry":"WBIL",` +
+		`"delivery":{"name":"Test Testov","phone":"+9720000000","zip":"2639809","city":"Kiryat Mozkin",` +
+		`"address":"Ploshad Mira 15","region":"Kraiot","email":"test@gmail.com"},` +
+		`"payment":{"transaction":"` + uid + `","currency":"USD","provider":"wbpay","amount":1817,` +
+		`"payment_dt":1637907727,"bank":"alpha","delivery_cost":1500,"goods_total":317},` +
+		`"items":[{"chrt_id":9934930,"track_number":"WBILMTESTTRACK","price":453,"rid":"ab4219087a764ae0btest",` +
+		`"name":"Mascaras","sale":30,"size":"0","total_price":317,"nm_id":2389212,"brand":"Vivienne Sabo","status":202}],` +
+		`"locale":"en","customer_id":"test","delivery_service":"meest","shardkey":"9","sm_id":99,` +
+		`"date_created":"2021-11-26T06:22:19Z","oof_shard":"1"}`
+}
+
+func runImport(t *testing.T, store *fakeStore, format, input string, cp Checkpoint) (Stats, []Checkpoint, []Reject) {
+	t.Helper()
+	var rejects bytes.Buffer
+	var saved []Checkpoint
+	stats, err := NewImporter(store, 2).Import(context.Background(), strings.NewReader(input), format, &rejects, cp,
+		func(cp Checkpoint) error {
+			saved = append(saved, cp)
+			return nil
+		})
+	require.NoError(t, err)
+
+	var result []Reject
+	dec := json.NewDecoder(&rejects)
+	for dec.More() {
+		var r Reject
+		require.NoError(t, dec.Decode(&r))
+		result = append(result, r)
+	}
+	return stats, saved, result
+}
+
+func TestImport_JSONL(t *testing.T) {
+	input := orderJSON("a1") + "\n" +
+		"{broken\n" +
+		"\n" +
+		orderJSON("a2") + "\n" +
+		strings.Replace(orderJSON("a3"), `"locale":"en"`, `"locale":"de"`, 1) + "\n" +
+		orderJSON("a1") + "\n"
+
+	store := &fakeStore{}
+	stats, saved, rejects := runImport(t, store, FormatJSONL, input, Checkpoint{})
+
+	assert.Equal(t, Stats{Records: 5, Imported: 2, Duplicates: 1, Rejected: 2}, stats)
+	assert.Len(t, store.saved, 2)
+
+	require.Len(t, rejects, 2)
+	assert.Equal(t, 2, rejects[0].Position)
+	assert.Contains(t, rejects[0].Error, "INVALID_JSON")
+	assert.Equal(t, 5, rejects[1].Position)
+	assert.Equal(t, "a3", rejects[1].OrderUID)
+	assert.Contains(t, rejects[1].Error, "VALIDATION_ERROR")
+
+	// партии по две записи, пустая строка не считается
+	require.Len(t, saved, 3)
+	assert.Equal(t, []int{2, 5, 6}, []int{saved[0].Position, saved[1].Position, saved[2].Position})
+}
+
+func TestImport_JSONArray(t *testing.T) {
+	input := "[\n" + orderJSON("a1") + ",\n" + orderJSON("a2") + ",\n" + orderJSON("a3") + "\n]"
+
+	store := &fakeStore{}
+	stats, _, rejects := runImport(t, store, FormatJSON, input, Checkpoint{})
+
+	assert.Equal(t, Stats{Records: 3, Imported: 3}, stats)
+	assert.Empty(t, rejects)
+	assert.Equal(t, 2, store.batches)
+}
+
+func TestImport_Resume(t *testing.T) {
+	var lines []string
+	for i := 1; i <= 5; i++ {
+		lines = append(lines, orderJSON(fmt.Sprintf("a%d", i)))
+	}
+	input := strings.Join(lines, "\n")
+
+	// первая партия записана, вторая записана без сохранения checkpoint
+	store := &fakeStore{}
+	runImport(t, store, FormatJSONL, strings.Join(lines[:4], "\n"), Checkpoint{})
+	cp := Checkpoint{Position: 2, Stats: Stats{Records: 2, Imported: 2}}
+
+	stats, saved, rejects := runImport(t, store, FormatJSONL, input, cp)
+
+	assert.Equal(t, Stats{Records: 5, Imported: 3, Duplicates: 2}, stats)
+	assert.Empty(t, rejects)
+	assert.Len(t, store.saved, 5)
+	assert.Equal(t, 5, saved[len(saved)-1].Position)
+}
+
+func TestImport_StoreError(t *testing.T) {
+	var saved []Checkpoint
+	_, err := NewImporter(&fakeStore{fail: errors.New("connection refused")}, 1).Import(context.Background(),
+		strings.NewReader(orderJSON("a1")), FormatJSONL, &bytes.Buffer{}, Checkpoint{},
+		func(cp Checkpoint) error {
+			saved = append(saved, cp)
+			return nil
+		})
+
+	assert.Error(t, err)
+	assert.Empty(t, saved)
+}
+
+const csvHeader = "order_uid,track_number,entry,locale,customer_id,delivery_service,shardkey,sm_id,date_created,oof_shard," +
+	"delivery_name,delivery_phone,delivery_email,delivery_zip,delivery_city,delivery_address,delivery_region," +
+	"payment_transaction,payment_currency,payment_provider,payment_amount,payment_dt,payment_bank," +
+	"payment_delivery_cost,payment_goods_total," +
+	"item_chrt_id,item_track_number,item_price,item_rid,item_name,item_sale,item_size,item_total_price,item_nm_id,item_brand,item_status\n"
+
+func csvLine(uid, item string) string {
+	return uid + ",WBILMTESTTRACK,WBIL,en,test,meest,9,99,2021-11-26T06:22:19Z,1," +
+		"Test Testov,+9720000000,test@gmail.com,2639809,Kiryat Mozkin,Ploshad Mira 15,Kraiot," +
+		uid + ",USD,wbpay,1817,1637907727,alpha,1500,317," +
+		"9934930,WBILMTESTTRACK,453,ab4219087a764ae0btest," + item + ",30,0,317,2389212,Vivienne Sabo,202\n"
+}
+
+func TestImport_CSV(t *testing.T) {
+	input := csvHeader +
+		csvLine("a1", "Mascaras") +
+		csvLine("a1", `"Lipstick, red"`) +
+		"a2,too,few,columns\n" +
+		strings.Replace(csvLine("a3", "Mascaras"), ",99,", ",x,", 1) +
+		csvLine("a4", "Mascaras")
+
+	store := &fakeStore{}
+	stats, _, rejects := runImport(t, store, FormatCSV, input, Checkpoint{})
+
+	assert.Equal(t, Stats{Records: 4, Imported: 2, Rejected: 2}, stats)
+
+	a1 := store.saved["a1"]
+	require.Len(t, a1.Items, 2)
+	assert.Equal(t, "Lipstick, red", a1.Items[1].Name)
+	assert.Equal(t, "+9720000000", a1.Delivery.Phone)
+	assert.Equal(t, int64(1637907727), a1.Payment.PaymentDT)
+	assert.Equal(t, 2021, a1.DateCreated.Year())
+
+	require.Len(t, rejects, 2)
+	assert.Equal(t, 4, rejects[0].Position)
+	assert.Contains(t, rejects[0].Error, "INVALID_CSV")
+	assert.Equal(t, 5, rejects[1].Position)
+	assert.Contains(t, rejects[1].Error, "sm_id")
+}
+
+func TestNewReader_Invalid(t *testing.T) {
+	tests := []struct {
+		name, format, input string
+	}{
+		{name: "format", format: "xml", input: ""},
+		{name: "not an array", format: FormatJSON, input: `{"order_uid":"a1"}`},
+		{name: "unknown column", format: FormatCSV, input: "order_uid,password\n"},
+		{name: "no order_uid", format: FormatCSV, input: "customer_id\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := newReader(test.format, strings.NewReader(test.input))
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,364 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order"
+)
+
+const (
+	FormatJSON  = "json"
+	FormatJSONL = "jsonl"
+	FormatCSV   = "csv"
+)
+
+// FormatFromPath guesses the format from the file extension.
+func FormatFromPath(path string) (string, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return FormatJSON, nil
+	case ".jsonl", ".ndjson":
+		return FormatJSONL, nil
+	case ".csv":
+		return FormatCSV, nil
+	default:
+		return "", fmt.Errorf("cannot guess format of %q, expected .json, .jsonl or .csv", path)
+	}
+}
+
+// record is one order of the input. pos is the line it starts on, for JSON
+// arrays the number of the element. data is the order as JSON, raw is the
+// source text reported in the reject file.
+type record struct {
+	pos  int
+	data []byte
+	raw  string
+	err  error
+}
+
+// recordReader returns records in input order and io.EOF at the end. Other
+// errors mean the rest of the input cannot be read.
+type recordReader interface {
+	next() (record, error)
+}
+
+func newReader(format string, r io.Reader) (recordReader, error) {
+	switch format {
+	case FormatJSON:
+		return newJSONReader(r)
+	case FormatJSONL:
+		return &jsonlReader{r: bufio.NewReader(r)}, nil
+	case FormatCSV:
+		return newCSVReader(r)
+	default:
+		return nil, fmt.Errorf("unknown format %q, expected json, jsonl or csv", format)
+	}
+}
+
+// jsonReader streams the elements of a top level JSON array.
+type jsonReader struct {
+	dec *json.Decoder
+	pos int
+}
+
+func newJSONReader(r io.Reader) (*jsonReader, error) {
+	dec := json.NewDecoder(r)
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, fmt.Errorf("read json array: %w", err)
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+		return nil, errors.New("read json array: input is not an array")
+	}
+	return &jsonReader{dec: dec}, nil
+}
+
+func (j *jsonReader) next() (record, error) {
+	if !j.dec.More() {
+		return record{}, io.EOF
+	}
+	j.pos++
+	// после синтаксической ошибки декодер не может продолжить
+	var raw json.RawMessage
+	if err := j.dec.Decode(&raw); err != nil {
+		return record{}, fmt.Errorf("element %d: %w", j.pos, err)
+	}
+	return record{pos: j.pos, data: raw, raw: string(raw)}, nil
+}
+
+type jsonlReader struct {
+	r    *bufio.Reader
+	line int
+}
+
+func (j *jsonlReader) next() (record, error) {
+	for {
+		line, err := j.r.ReadBytes('\n')
+		if len(line) == 0 && err != nil {
+			return record{}, err
+		}
+		if err != nil && err != io.EOF {
+			return record{}, err
+		}
+		j.line++
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		return record{pos: j.line, data: line, raw: string(line)}, nil
+	}
+}
+
+// csvReader reads the flattened layout of the export: one row per item with
+// the order columns repeated. Consecutive rows with the same order_uid make
+// up one order.
+type csvReader struct {
+	r       *csv.Reader
+	names   []string
+	fields  []field
+	uid     int
+	items   bool
+	pending *csvRow
+}
+
+type csvRow struct {
+	values []string
+	line   int
+	err    error
+}
+
+func newCSVReader(r io.Reader) (*csvReader, error) {
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read csv header: %w", err)
+	}
+
+	c := &csvReader{r: cr, fields: make([]field, len(header)), uid: -1}
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		header[i] = name
+		f, ok := fields[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown csv column %q", name)
+		}
+		c.fields[i] = f
+		c.items = c.items || f.item
+		if name == "order_uid" {
+			c.uid = i
+		}
+	}
+	if c.uid < 0 {
+		return nil, errors.New("csv header has no order_uid column")
+	}
+	c.names = header
+	cr.FieldsPerRecord = len(header)
+	return c, nil
+}
+
+func (c *csvReader) read() (*csvRow, error) {
+	if row := c.pending; row != nil {
+		c.pending = nil
+		return row, nil
+	}
+	values, err := c.r.Read()
+	var parseErr *csv.ParseError
+	switch {
+	case err == nil:
+		line, _ := c.r.FieldPos(0)
+		return &csvRow{values: values, line: line}, nil
+	case errors.As(err, &parseErr):
+		return &csvRow{values: values, line: parseErr.StartLine, err: err}, nil
+	default:
+		return nil, err
+	}
+}
+
+func (c *csvReader) next() (record, error) {
+	first, err := c.read()
+	if err != nil {
+		return record{}, err
+	}
+	if first.err != nil {
+		return record{pos: first.line, raw: strings.Join(first.values, ","),
+			err: fmt.Errorf("INVALID_CSV: %w", first.err)}, nil
+	}
+
+	rows := [][]string{first.values}
+	for {
+		row, err := c.read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return record{}, err
+		}
+		if row.err != nil || row.values[c.uid] != first.values[c.uid] {
+			c.pending = row
+			break
+		}
+		rows = append(rows, row.values)
+	}
+
+	rec := record{pos: first.line, raw: joinRows(rows)}
+	o, err := c.build(rows)
+	if err != nil {
+		rec.err = fmt.Errorf("INVALID_CSV: %w", err)
+		return rec, nil
+	}
+	rec.data, rec.err = json.Marshal(o)
+	return rec, nil
+}
+
+// build takes the order columns from the first row and an item from every row.
+func (c *csvReader) build(rows [][]string) (order.Order, error) {
+	var o order.Order
+	for i, f := range c.fields {
+		if f.item {
+			continue
+		}
+		if err := f.set(&o, nil, rows[0][i]); err != nil {
+			return o, fmt.Errorf("%s: %w", c.names[i], err)
+		}
+	}
+	if !c.items {
+		return o, nil
+	}
+	o.Items = make([]order.Item, len(rows))
+	for r, row := range rows {
+		for i, f := range c.fields {
+			if !f.item {
+				continue
+			}
+			if err := f.set(&o, &o.Items[r], row[i]); err != nil {
+				return o, fmt.Errorf("item %d %s: %w", r+1, c.names[i], err)
+			}
+		}
+	}
+	return o, nil
+}
+
+func joinRows(rows [][]string) string {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.WriteAll(rows)
+	return strings.TrimRight(buf.String(), "\n")
+}
+
+// field sets one CSV column, named as in the export.
+type field struct {
+	item bool
+	set  func(o *order.Order, it *order.Item, v string) error
+}
+
+func orderString(f func(o *order.Order) *string) field {
+	return field{set: func(o *order.Order, _ *order.Item, v string) error {
+		*f(o) = v
+		return nil
+	}}
+}
+
+func orderInt(f func(o *order.Order) *int) field {
+	return field{set: func(o *order.Order, _ *order.Item, v string) error {
+		return parseInt(v, f(o))
+	}}
+}
+
+func itemString(f func(it *order.Item) *string) field {
+	return field{item: true, set: func(_ *order.Order, it *order.Item, v string) error {
+		*f(it) = v
+		return nil
+	}}
+}
+
+func itemInt(f func(it *order.Item) *int) field {
+	return field{item: true, set: func(_ *order.Order, it *order.Item, v string) error {
+		return parseInt(v, f(it))
+	}}
+}
+
+// parseInt leaves dst zero for an empty value, validation decides whether
+// the field is required.
+func parseInt(v string, dst *int) error {
+	if v == "" {
+		return nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return err
+	}
+	*dst = n
+	return nil
+}
+
+var fields = map[string]field{
+	"order_uid":          orderString(func(o *order.Order) *string { return &o.OrderUID }),
+	"track_number":       orderString(func(o *order.Order) *string { return &o.TrackNumber }),
+	"entry":              orderString(func(o *order.Order) *string { return &o.Entry }),
+	"locale":             orderString(func(o *order.Order) *string { return &o.Locale }),
+	"internal_signature": orderString(func(o *order.Order) *string { return &o.InternalSignature }),
+	"customer_id":        orderString(func(o *order.Order) *string { return &o.CustomerID }),
+	"delivery_service":   orderString(func(o *order.Order) *string { return &o.DeliveryService }),
+	"shardkey":           orderString(func(o *order.Order) *string { return &o.ShardKey }),
+	"sm_id":              orderInt(func(o *order.Order) *int { return &o.SMID }),
+	"date_created": {set: func(o *order.Order, _ *order.Item, v string) error {
+		if v == "" {
+			return nil
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return err
+		}
+		o.DateCreated = t
+		return nil
+	}},
+	"oof_shard": orderString(func(o *order.Order) *string { return &o.OOFShard }),
+
+	"delivery_name":    orderString(func(o *order.Order) *string { return &o.Delivery.Name }),
+	"delivery_phone":   orderString(func(o *order.Order) *string { return &o.Delivery.Phone }),
+	"delivery_email":   orderString(func(o *order.Order) *string { return &o.Delivery.Email }),
+	"delivery_zip":     orderString(func(o *order.Order) *string { return &o.Delivery.Zip }),
+	"delivery_city":    orderString(func(o *order.Order) *string { return &o.Delivery.City }),
+	"delivery_address": orderString(func(o *order.Order) *string { return &o.Delivery.Address }),
+	"delivery_region":  orderString(func(o *order.Order) *string { return &o.Delivery.Region }),
+
+	"payment_transaction": orderString(func(o *order.Order) *string { return &o.Payment.Transaction }),
+	"payment_request_id":  orderString(func(o *order.Order) *string { return &o.Payment.RequestID }),
+	"payment_currency":    orderString(func(o *order.Order) *string { return &o.Payment.Currency }),
+	"payment_provider":    orderString(func(o *order.Order) *string { return &o.Payment.Provider }),
+	"payment_amount":      orderInt(func(o *order.Order) *int { return &o.Payment.Amount }),
+	"payment_dt": {set: func(o *order.Order, _ *order.Item, v string) error {
+		if v == "" {
+			return nil
+		}
+		n, err := strconv.ParseInt(v, 10, 64)
+		o.Payment.PaymentDT = n
+		return err
+	}},
+	"payment_bank":          orderString(func(o *order.Order) *string { return &o.Payment.Bank }),
+	"payment_delivery_cost": orderInt(func(o *order.Order) *int { return &o.Payment.DeliveryCost }),
+	"payment_goods_total":   orderInt(func(o *order.Order) *int { return &o.Payment.GoodsTotal }),
+	"payment_custom_fee":    orderInt(func(o *order.Order) *int { return &o.Payment.CustomFee }),
+
+	"item_chrt_id":      itemInt(func(it *order.Item) *int { return &it.ChrtID }),
+	"item_track_number": itemString(func(it *order.Item) *string { return &it.TrackNumber }),
+	"item_price":        itemInt(func(it *order.Item) *int { return &it.Price }),
+	"item_rid":          itemString(func(it *order.Item) *string { return &it.RID }),
+	"item_name":         itemString(func(it *order.Item) *string { return &it.Name }),
+	"item_sale":         itemInt(func(it *order.Item) *int { return &it.Sale }),
+	"item_size":         itemString(func(it *order.Item) *string { return &it.Size }),
+	"item_total_price":  itemInt(func(it *order.Item) *int { return &it.TotalPrice }),
+	"item_nm_id":        itemInt(func(it *order.Item) *int { return &it.NmID }),
+	"item_brand":        itemString(func(it *order.Item) *string { return &it.Brand }),
+	"item_status":       itemInt(func(it *order.Item) *int { return &it.Status }),
+}
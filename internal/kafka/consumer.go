@@ -1,113 +1,198 @@
 package kafka
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/Egor-Pomidor-pdf/order-service/internal/config"
-	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/redact"
 )
 
 const (
 	sessionTimeout = 10000
-	timeout        = 5000
+	pollTimeout    = time.Second
+
+	defaultRetryBackoff = time.Second
 )
 
 type Handler interface {
-	HandleMessage(message []byte, offset kafka.Offset) error
+	HandleMessage(message []byte, offset int64) error
 }
 
 type Consumer struct {
-	consumer *kafka.Consumer
-	handler  Handler
-	stop     bool
+	source       MessageSource
+	handler      Handler
+	dlq          DeadLetterSink
+	retryBackoff time.Duration
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	started atomic.Bool
+	done    chan struct{}
 }
 
 func NewConsumer(handler Handler, cfg config.KafkaConfig) (*Consumer, error) {
-	kafkaConfig := &kafka.ConfigMap{
-		"bootstrap.servers":        strings.Join(cfg.Brokers, ","),
-		"group.id":                 cfg.GroupID,
-		"session.timeout.ms":       sessionTimeout,
-		"enable.auto.offset.store": false,
-		"enable.auto.commit":       false,
-		"auto.offset.reset":        "latest",
-	}
-
-	c, err := kafka.NewConsumer(kafkaConfig)
-
+	source, err := NewSource(cfg)
 	if err != nil {
 		return nil, err
 	}
 
-	if err := c.Subscribe(cfg.Topic, nil); err != nil {
-		return nil, err
+	c := NewConsumerWithSource(handler, source).WithRetryBackoff(cfg.RetryBackoff)
+	if sink := NewDeadLetterSink(cfg); sink != nil {
+		c.WithDeadLetterSink(sink)
 	}
+	return c, nil
+}
 
+// NewConsumerWithSource builds a Consumer on top of an already created source.
+func NewConsumerWithSource(handler Handler, source MessageSource) *Consumer {
+	ctx, cancel := context.WithCancel(context.Background())
 	return &Consumer{
-		consumer: c,
-		handler:  handler,
-	}, nil
+		source:       source,
+		handler:      handler,
+		retryBackoff: defaultRetryBackoff,
+		ctx:          ctx,
+		cancel:       cancel,
+		done:         make(chan struct{}),
+	}
+}
+
+// WithRetryBackoff sets the pause between attempts to process a message
+// that failed with a transient error, d <= 0 keeps the default.
+func (c *Consumer) WithRetryBackoff(d time.Duration) *Consumer {
+	if d > 0 {
+		c.retryBackoff = d
+	}
+	return c
+}
 
+// WithDeadLetterSink makes the consumer forward skipped messages to sink.
+func (c *Consumer) WithDeadLetterSink(sink DeadLetterSink) *Consumer {
+	c.dlq = sink
+	return c
 }
 
 func (c *Consumer) Start() {
-	for {
-		if c.stop {
-			break
-		}
-		kafkaMsg, err := c.consumer.ReadMessage(timeout)
+	if !c.started.CompareAndSwap(false, true) {
+		return
+	}
+	defer close(c.done)
 
+	for c.ctx.Err() == nil {
+		msg, err := c.source.Fetch(c.ctx)
 		if err != nil {
-			if kafkaError, ok := err.(kafka.Error); ok && kafkaError.Code() == kafka.ErrTimedOut {
-				time.Sleep(200 * time.Millisecond)
-				continue
+			if c.ctx.Err() != nil || errors.Is(err, ErrSourceClosed) {
+				return
 			}
 			slog.Error("Error reading message", "error", err)
-		}
-		if kafkaMsg == nil {
+			c.sleep(200 * time.Millisecond)
 			continue
 		}
 
-		if err := c.handler.HandleMessage(kafkaMsg.Value, kafkaMsg.TopicPartition.Offset); err != nil {
-			if strings.Contains(err.Error(), "VALIDATION_ERROR:") ||
-				strings.Contains(err.Error(), "INVALID_JSON:") {
-				slog.Error("SKIPPING_INVALID_MESSAGE",
-					"error", err,
-					"raw_message", string(kafkaMsg.Value),
-				)
-				c.consumer.StoreMessage(kafkaMsg)
-				c.consumer.Commit()
-				continue
-			} else {
-				slog.Error("DATABASE_ERROR - WILL RETRY", "error", err)
-				continue
-			}
+		outcome, ok := c.process(msg)
+		if !ok {
+			return
 		}
 
-		if _, err := c.consumer.StoreMessage(kafkaMsg); err != nil {
-			slog.Error("Error storing message offset", "error", err)
+		if err := c.source.Commit(c.ctx, msg); err != nil {
+			slog.Error("Error committing offset", "error", err)
 			continue
 		}
+		observeIngest(msg, outcome, time.Now())
 
-		if _, err := c.consumer.Commit(); err != nil {
-			slog.Error("Error committing offset", "error", err)
+		slog.Info("Message processed successfully", "offset", msg.Offset)
+	}
+}
+
+// process hands msg to the handler, retrying transient failures until
+// they succeed, and reports whether the message was stored, skipped or a
+// duplicate of a stored order (redelivery after a restart, producer retry). It
+// returns false if the consumer was stopped while retrying, in which case
+// the offset must not be committed.
+func (c *Consumer) process(msg *Message) (string, bool) {
+	for {
+		err := c.handler.HandleMessage(msg.Value, msg.Offset)
+		if err == nil {
+			return outcomeStored, true
+		}
+
+		if IsDuplicate(err) {
+			slog.Info("duplicate order skipped", "offset", msg.Offset)
+			return outcomeDuplicate, true
+		}
+
+		if IsPermanent(err) {
+			slog.Error("SKIPPING_INVALID_MESSAGE",
+				"error", err,
+				"raw_message", string(redact.Message(msg.Value)),
+			)
+			c.deadLetter(msg, err)
+			return outcomeSkipped, true
+		}
+
+		slog.Error("DATABASE_ERROR - WILL RETRY", "error", err, "offset", msg.Offset)
+		if !c.sleep(c.retryBackoff) {
+			return "", false
 		}
+	}
+}
 
-		slog.Info("Message processed successfully", "offset", kafkaMsg.TopicPartition.Offset)
+// deadLetter copies a skipped message to the DLQ. A failed copy is logged
+// and does not block the partition.
+func (c *Consumer) deadLetter(msg *Message, reason error) {
+	if c.dlq == nil {
+		return
+	}
+	if err := c.dlq.Publish(c.ctx, msg, reason); err != nil {
+		slog.Error("failed to publish message to DLQ", "offset", msg.Offset, "error", err)
+	}
+}
 
+// sleep waits for d and reports whether the consumer is still running.
+func (c *Consumer) sleep(d time.Duration) bool {
+	select {
+	case <-c.ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
 	}
 }
 
+// IsPermanent reports whether err means the message can never be processed
+// and should be skipped instead of retried.
+func IsPermanent(err error) bool {
+	return strings.Contains(err.Error(), "VALIDATION_ERROR:") ||
+		strings.Contains(err.Error(), "INVALID_JSON:")
+}
+
+// ErrDuplicate is wrapped by a Handler when the message was already
+// processed, e.g. a redelivery after a restart or a producer retry.
+var ErrDuplicate = errors.New("duplicate message")
+
+// IsDuplicate reports whether err wraps ErrDuplicate. Such a message is
+// committed like a processed one: retrying it would block the partition.
+func IsDuplicate(err error) bool {
+	return errors.Is(err, ErrDuplicate)
+}
+
 func (c *Consumer) Stop() error {
-	c.stop = true
+	c.cancel()
+	if c.started.Load() {
+		<-c.done
+	}
 
-	if _, err := c.consumer.Commit(); err != nil {
-		slog.Error("failed to commit offsets on stop", "error", err)
+	if c.dlq != nil {
+		if err := c.dlq.Close(); err != nil {
+			slog.Error("failed to close DLQ writer", "error", err)
+		}
 	}
 
-	if err := c.consumer.Close(); err != nil {
+	if err := c.source.Close(); err != nil {
 		return fmt.Errorf("failed to close consumer: %w", err)
 	}
 
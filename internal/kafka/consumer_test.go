@@ -0,0 +1,233 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Egor-Pomidor-pdf/order-service/internal/config"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type handlerFunc func(message []byte, offset int64) error
+
+func (f handlerFunc) HandleMessage(message []byte, offset int64) error {
+	return f(message, offset)
+}
+
+// recorder counts handler calls per offset and lets a test script the result.
+type recorder struct {
+	mu     sync.Mutex
+	calls  map[int64]int
+	result func(offset int64, attempt int) error
+}
+
+func newRecorder(result func(offset int64, attempt int) error) *recorder {
+	return &recorder{calls: make(map[int64]int), result: result}
+}
+
+func (r *recorder) HandleMessage(_ []byte, offset int64) error {
+	r.mu.Lock()
+	r.calls[offset]++
+	attempt := r.calls[offset]
+	r.mu.Unlock()
+	return r.result(offset, attempt)
+}
+
+func (r *recorder) attempts(offset int64) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.calls[offset]
+}
+
+func startConsumer(t *testing.T, h Handler, source *MemorySource) *Consumer {
+	t.Helper()
+	c := NewConsumerWithSource(h, source)
+	c.retryBackoff = time.Millisecond
+	go c.Start()
+	return c
+}
+
+func TestConsumer_CommitsProcessedMessages(t *testing.T) {
+	source := NewMemorySource("orders")
+	rec := newRecorder(func(int64, int) error { return nil })
+	c := startConsumer(t, rec, source)
+
+	for i := 0; i < 3; i++ {
+		source.Publish(nil, []byte(`{}`))
+	}
+
+	require.Eventually(t, func() bool { return source.Committed() == 3 }, time.Second, time.Millisecond)
+	require.NoError(t, c.Stop())
+	assert.Equal(t, 1, rec.attempts(0))
+	assert.Equal(t, 1, rec.attempts(2))
+}
+
+func TestConsumer_SkipsInvalidMessages(t *testing.T) {
+	source := NewMemorySource("orders")
+	rec := newRecorder(func(offset int64, _ int) error {
+		if offset == 0 {
+			return errors.New("VALIDATION_ERROR: bad order")
+		}
+		return nil
+	})
+	c := startConsumer(t, rec, source)
+
+	source.Publish(nil, []byte(`not json`))
+	source.Publish(nil, []byte(`{}`))
+
+	require.Eventually(t, func() bool { return source.Committed() == 2 }, time.Second, time.Millisecond)
+	require.NoError(t, c.Stop())
+	assert.Equal(t, 1, rec.attempts(0))
+}
+
+func TestConsumer_RetriesTransientErrors(t *testing.T) {
+	source := NewMemorySource("orders")
+	rec := newRecorder(func(_ int64, attempt int) error {
+		if attempt < 3 {
+			return errors.New("DATABASE_ERROR: connection refused")
+		}
+		return nil
+	})
+	c := startConsumer(t, rec, source)
+
+	source.Publish(nil, []byte(`{}`))
+
+	require.Eventually(t, func() bool { return source.Committed() == 1 }, time.Second, time.Millisecond)
+	require.NoError(t, c.Stop())
+	assert.Equal(t, 3, rec.attempts(0))
+}
+
+func TestConsumer_SkipsDuplicates(t *testing.T) {
+	source := NewMemorySource("orders")
+	rec := newRecorder(func(offset int64, _ int) error {
+		if offset == 1 {
+			return fmt.Errorf("%w: order already exists", ErrDuplicate)
+		}
+		return nil
+	})
+	var dead atomic.Int32
+	c := NewConsumerWithSource(rec, source).
+		WithRetryBackoff(time.Millisecond).
+		WithDeadLetterSink(sinkFunc(func(*Message, error) { dead.Add(1) }))
+	go c.Start()
+
+	source.Publish(nil, []byte(`{}`))
+	source.Publish(nil, []byte(`{}`))
+
+	require.Eventually(t, func() bool { return source.Committed() == 2 }, time.Second, time.Millisecond)
+	require.NoError(t, c.Stop())
+	assert.Equal(t, 1, rec.attempts(1), "not retried")
+	assert.Zero(t, dead.Load(), "not dead-lettered")
+}
+
+func TestConsumer_StopDuringRetryDoesNotCommit(t *testing.T) {
+	source := NewMemorySource("orders")
+	rec := newRecorder(func(int64, int) error {
+		return errors.New("DATABASE_ERROR: connection refused")
+	})
+	c := startConsumer(t, rec, source)
+
+	source.Publish(nil, []byte(`{}`))
+
+	require.Eventually(t, func() bool { return rec.attempts(0) >= 2 }, time.Second, time.Millisecond)
+	require.NoError(t, c.Stop())
+	assert.Equal(t, int64(0), source.Committed())
+
+	// a restarted consumer must see the same message again
+	source.Rewind()
+	redelivered := make(chan int64, 1)
+	c = startConsumer(t, handlerFunc(func(_ []byte, offset int64) error {
+		redelivered <- offset
+		return nil
+	}), source)
+	defer c.Stop()
+
+	select {
+	case offset := <-redelivered:
+		assert.Equal(t, int64(0), offset)
+	case <-time.After(time.Second):
+		t.Fatal("message was not redelivered")
+	}
+}
+
+func TestConsumer_StopWithoutStart(t *testing.T) {
+	source := NewMemorySource("orders")
+	c := NewConsumerWithSource(handlerFunc(func([]byte, int64) error { return nil }), source)
+
+	require.NoError(t, c.Stop())
+	c.Start()
+}
+
+func testKafkaConfig() config.KafkaConfig {
+	return config.KafkaConfig{Brokers: []string{"localhost:9092"}, Topic: "orders", GroupID: "order-service"}
+}
+
+type sinkFunc func(msg *Message, reason error)
+
+func (f sinkFunc) Publish(_ context.Context, msg *Message, reason error) error {
+	f(msg, reason)
+	return nil
+}
+
+func (f sinkFunc) Close() error { return nil }
+
+func TestConsumer_DeadLettersAreRedacted(t *testing.T) {
+	source := NewMemorySource("orders")
+	dead := make(chan *Message, 1)
+	c := NewConsumerWithSource(handlerFunc(func([]byte, int64) error {
+		return errors.New("VALIDATION_ERROR: bad phone")
+	}), source).WithDeadLetterSink(NewRedactingSink(sinkFunc(func(msg *Message, _ error) {
+		dead <- msg
+	})))
+	go c.Start()
+	defer c.Stop()
+
+	source.Publish([]byte("1"), []byte(`{"order_uid":"1","delivery":{"name":"Test Testov","email":"test@gmail.com"}}`))
+
+	select {
+	case msg := <-dead:
+		assert.Equal(t, []byte("1"), msg.Key)
+		assert.NotContains(t, string(msg.Value), "test@gmail.com")
+		assert.Contains(t, string(msg.Value), `"order_uid":"1"`)
+	case <-time.After(time.Second):
+		t.Fatal("message was not dead-lettered")
+	}
+	require.Eventually(t, func() bool { return source.Committed() == 1 }, time.Second, time.Millisecond)
+}
+
+func histogramCount(t *testing.T, outcome string) uint64 {
+	t.Helper()
+	var m dto.Metric
+	require.NoError(t, ingestLatency.WithLabelValues(outcome).(prometheus.Histogram).Write(&m))
+	return m.GetHistogram().GetSampleCount()
+}
+
+func TestConsumer_ObservesIngestLatency(t *testing.T) {
+	stored, skipped := histogramCount(t, outcomeStored), histogramCount(t, outcomeSkipped)
+
+	source := NewMemorySource("orders")
+	rec := newRecorder(func(offset int64, _ int) error {
+		if offset == 1 {
+			return errors.New("INVALID_JSON: bad order")
+		}
+		return nil
+	})
+	c := startConsumer(t, rec, source)
+
+	source.Publish(nil, []byte(`{}`))
+	source.Publish(nil, []byte(`not json`))
+	source.Publish(nil, []byte(`{}`))
+
+	require.Eventually(t, func() bool { return source.Committed() == 3 }, time.Second, time.Millisecond)
+	require.NoError(t, c.Stop())
+	assert.Equal(t, stored+2, histogramCount(t, outcomeStored))
+	assert.Equal(t, skipped+1, histogramCount(t, outcomeSkipped))
+}
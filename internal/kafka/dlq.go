@@ -0,0 +1,76 @@
+package kafka
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/Egor-Pomidor-pdf/order-service/internal/config"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/redact"
+	kafkago "github.com/segmentio/kafka-go"
+)
+
+// DeadLetterSink receives copies of messages that were skipped as invalid.
+type DeadLetterSink interface {
+	Publish(ctx context.Context, msg *Message, reason error) error
+	Close() error
+}
+
+// RedactingSink masks customer PII in message values before handing them to
+// the next sink.
+type RedactingSink struct {
+	next DeadLetterSink
+}
+
+func NewRedactingSink(next DeadLetterSink) *RedactingSink {
+	return &RedactingSink{next: next}
+}
+
+func (s *RedactingSink) Publish(ctx context.Context, msg *Message, reason error) error {
+	scrubbed := *msg
+	scrubbed.Value = redact.Message(msg.Value)
+	return s.next.Publish(ctx, &scrubbed, reason)
+}
+
+func (s *RedactingSink) Close() error {
+	return s.next.Close()
+}
+
+type topicSink struct {
+	writer *kafkago.Writer
+}
+
+// NewDeadLetterSink writes to cfg.DLQTopic, or returns nil when no dead
+// letter topic is configured.
+func NewDeadLetterSink(cfg config.KafkaConfig) DeadLetterSink {
+	if cfg.DLQTopic == "" {
+		return nil
+	}
+
+	var sink DeadLetterSink = &topicSink{writer: &kafkago.Writer{
+		Addr:                   kafkago.TCP(cfg.Brokers...),
+		Topic:                  cfg.DLQTopic,
+		RequiredAcks:           kafkago.RequireAll,
+		AllowAutoTopicCreation: true,
+	}}
+	if cfg.DLQRedact {
+		sink = NewRedactingSink(sink)
+	}
+	return sink
+}
+
+func (s *topicSink) Publish(ctx context.Context, msg *Message, reason error) error {
+	return s.writer.WriteMessages(ctx, kafkago.Message{
+		Key:   msg.Key,
+		Value: msg.Value,
+		Headers: []kafkago.Header{
+			{Key: "dlq.reason", Value: []byte(reason.Error())},
+			{Key: "dlq.topic", Value: []byte(msg.Topic)},
+			{Key: "dlq.partition", Value: []byte(strconv.Itoa(int(msg.Partition)))},
+			{Key: "dlq.offset", Value: []byte(strconv.FormatInt(msg.Offset, 10))},
+		},
+	})
+}
+
+func (s *topicSink) Close() error {
+	return s.writer.Close()
+}
@@ -0,0 +1,32 @@
+package kafka
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	outcomeStored    = "stored"
+	outcomeSkipped   = "skipped"
+	outcomeDuplicate = "duplicate"
+)
+
+// ingestLatency is the time from the Kafka message timestamp (set by the
+// producer or the broker) to the commit of its offset.
+var ingestLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: "order_service",
+	Subsystem: "consumer",
+	Name:      "ingest_latency_seconds",
+	Help:      "Time from the Kafka message timestamp to the offset commit.",
+	Buckets:   prometheus.ExponentialBuckets(0.005, 2, 14),
+}, []string{"outcome"})
+
+func observeIngest(msg *Message, outcome string, committed time.Time) {
+	// у сообщений без timestamp (старые брокеры) задержку не посчитать
+	if msg.Timestamp.IsZero() {
+		return
+	}
+	ingestLatency.WithLabelValues(outcome).Observe(committed.Sub(msg.Timestamp).Seconds())
+}
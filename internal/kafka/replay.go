@@ -0,0 +1,246 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/Egor-Pomidor-pdf/order-service/internal/config"
+	kafkago "github.com/segmentio/kafka-go"
+)
+
+// replayIdleTimeout ends a partition early when no message arrives: the range
+// may end in transaction markers or compacted offsets that are never fetched.
+const replayIdleTimeout = 10 * time.Second
+
+var ErrEmptyReplayRange = errors.New("replay needs start offsets or a timestamp")
+
+// ReplayRequest describes which part of the topic should be reprocessed.
+type ReplayRequest struct {
+	// Offsets maps a partition to the offset replay starts from.
+	Offsets map[int32]int64 `json:"offsets,omitempty"`
+	// Since is used for partitions that are missing from Offsets.
+	Since  time.Time `json:"since,omitempty"`
+	DryRun bool      `json:"dry_run"`
+}
+
+type ReplayReport struct {
+	GroupID    string `json:"group_id"`
+	DryRun     bool   `json:"dry_run"`
+	Processed  int    `json:"processed"`
+	Inserted   int    `json:"inserted"`
+	Duplicated int    `json:"duplicated"`
+	Rejected   int    `json:"rejected"`
+	Failed     int    `json:"failed"`
+}
+
+func (r *ReplayReport) record(err error) {
+	r.Processed++
+	switch {
+	case err == nil:
+		r.Inserted++
+	case IsDuplicate(err):
+		r.Duplicated++
+	case IsPermanent(err):
+		r.Rejected++
+	default:
+		r.Failed++
+	}
+}
+
+// ReplayHandler is the message pipeline used for replay. CheckMessage runs
+// the same validation as HandleMessage without writing anything.
+type ReplayHandler interface {
+	Handler
+	CheckMessage(message []byte) error
+}
+
+// Replayer reprocesses a range of the orders topic under its own consumer
+// group, so the offsets of the main group are left untouched.
+type Replayer struct {
+	cfg     config.KafkaConfig
+	handler ReplayHandler
+}
+
+func NewReplayer(handler ReplayHandler, cfg config.KafkaConfig) *Replayer {
+	return &Replayer{cfg: cfg, handler: handler}
+}
+
+func (r *Replayer) Replay(ctx context.Context, req ReplayRequest) (*ReplayReport, error) {
+	const op = "kafka.Replay"
+
+	if len(req.Offsets) == 0 && req.Since.IsZero() {
+		return nil, fmt.Errorf("%s: %w", op, ErrEmptyReplayRange)
+	}
+	if len(r.cfg.Brokers) == 0 {
+		return nil, fmt.Errorf("%s: no kafka brokers configured", op)
+	}
+
+	conn, err := kafkago.DialContext(ctx, "tcp", r.cfg.Brokers[0])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	partitions, err := conn.ReadPartitions(r.cfg.Topic)
+	conn.Close()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	report := &ReplayReport{
+		GroupID: fmt.Sprintf("%s-replay-%d", r.cfg.GroupID, time.Now().Unix()),
+		DryRun:  req.DryRun,
+	}
+
+	var commits []kafkago.OffsetCommit
+	for _, p := range partitions {
+		start, end, err := r.bounds(ctx, p.ID, req)
+		if err != nil {
+			return report, fmt.Errorf("%s: partition %d: %w", op, p.ID, err)
+		}
+		if start >= end {
+			continue
+		}
+
+		slog.Info("replaying partition", "partition", p.ID, "from", start, "to", end, "dry_run", req.DryRun)
+		source, err := newPartitionSource(r.cfg, p.ID, start)
+		if err != nil {
+			return report, fmt.Errorf("%s: partition %d: %w", op, p.ID, err)
+		}
+		next, err := replayRange(ctx, source, start, end, replayIdleTimeout, r.handler, req.DryRun, report)
+		source.Close()
+		commits = append(commits, kafkago.OffsetCommit{Partition: p.ID, Offset: next})
+		if err != nil {
+			return report, fmt.Errorf("%s: partition %d: %w", op, p.ID, err)
+		}
+	}
+
+	if !req.DryRun && len(commits) > 0 {
+		r.commit(ctx, report.GroupID, commits)
+	}
+
+	return report, nil
+}
+
+// bounds resolves the [start, end) offset range to replay in a partition.
+// end is the high watermark at the moment the replay starts.
+func (r *Replayer) bounds(ctx context.Context, partition int, req ReplayRequest) (int64, int64, error) {
+	leader, err := kafkago.DialLeader(ctx, "tcp", r.cfg.Brokers[0], r.cfg.Topic, partition)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer leader.Close()
+
+	first, last, err := leader.ReadOffsets()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	start, ok := req.Offsets[int32(partition)]
+	if !ok {
+		if req.Since.IsZero() {
+			return 0, 0, nil
+		}
+		if start, err = leader.ReadOffset(req.Since); err != nil {
+			return 0, 0, err
+		}
+	}
+	if start < first {
+		start = first
+	}
+
+	return start, last, nil
+}
+
+// commit records how far the replay got under its own consumer group.
+func (r *Replayer) commit(ctx context.Context, groupID string, commits []kafkago.OffsetCommit) {
+	client := &kafkago.Client{Addr: kafkago.TCP(r.cfg.Brokers...)}
+	_, err := client.OffsetCommit(ctx, &kafkago.OffsetCommitRequest{
+		GroupID:      groupID,
+		GenerationID: -1,
+		Topics:       map[string][]kafkago.OffsetCommit{r.cfg.Topic: commits},
+	})
+	if err != nil {
+		slog.Error("failed to commit replay offsets", "group_id", groupID, "error", err)
+	}
+}
+
+// replayRange runs the messages in [start, end) through the handler and
+// returns the offset following the last message it processed. Every message
+// of the range was in the topic when the replay started, so a fetch that
+// waits longer than idle or returns an offset past the range means the
+// remaining offsets hold no messages and the range is done.
+func replayRange(ctx context.Context, source MessageSource, start, end int64, idle time.Duration, handler ReplayHandler, dryRun bool, report *ReplayReport) (int64, error) {
+	next := start
+	for next < end {
+		fetchCtx, cancel := context.WithTimeout(ctx, idle)
+		msg, err := source.Fetch(fetchCtx)
+		cancel()
+		if err != nil {
+			if ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded) {
+				slog.Info("no more messages in replay range", "from", next, "to", end)
+				return end, nil
+			}
+			return next, err
+		}
+		if msg.Offset >= end {
+			return end, nil
+		}
+
+		if dryRun {
+			err = handler.CheckMessage(msg.Value)
+		} else {
+			err = handler.HandleMessage(msg.Value, msg.Offset)
+		}
+		if err != nil && !IsPermanent(err) && !IsDuplicate(err) {
+			slog.Error("replay failed to process message", "offset", msg.Offset, "error", err)
+		}
+		report.record(err)
+
+		next = msg.Offset + 1
+	}
+	return next, nil
+}
+
+// partitionSource reads a single partition from a fixed offset without
+// joining a consumer group.
+type partitionSource struct {
+	reader *kafkago.Reader
+}
+
+func newPartitionSource(cfg config.KafkaConfig, partition int, offset int64) (*partitionSource, error) {
+	reader := kafkago.NewReader(kafkago.ReaderConfig{
+		Brokers:   cfg.Brokers,
+		Topic:     cfg.Topic,
+		Partition: partition,
+	})
+	if err := reader.SetOffset(offset); err != nil {
+		reader.Close()
+		return nil, err
+	}
+	return &partitionSource{reader: reader}, nil
+}
+
+func (s *partitionSource) Fetch(ctx context.Context) (*Message, error) {
+	m, err := s.reader.FetchMessage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &Message{
+		Topic:     m.Topic,
+		Partition: int32(m.Partition),
+		Offset:    m.Offset,
+		Key:       m.Key,
+		Value:     m.Value,
+		Timestamp: m.Time,
+	}, nil
+}
+
+func (s *partitionSource) Commit(context.Context, *Message) error {
+	return nil
+}
+
+func (s *partitionSource) Close() error {
+	return s.reader.Close()
+}
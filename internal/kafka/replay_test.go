@@ -0,0 +1,107 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scriptedHandler returns the error stored for the message value.
+type scriptedHandler struct {
+	results map[string]error
+	handled []int64
+	checked int
+}
+
+func (h *scriptedHandler) HandleMessage(message []byte, offset int64) error {
+	h.handled = append(h.handled, offset)
+	return h.results[string(message)]
+}
+
+func (h *scriptedHandler) CheckMessage(message []byte) error {
+	h.checked++
+	return h.results[string(message)]
+}
+
+func TestReplayRange(t *testing.T) {
+	source := NewMemorySource("orders")
+	for _, v := range []string{"skipped", "ok", "dup", "invalid", "db", "ok", "after-end"} {
+		source.Publish(nil, []byte(v))
+	}
+	source.Commit(context.Background(), &Message{Offset: 0})
+	source.Rewind()
+
+	h := &scriptedHandler{results: map[string]error{
+		"dup":     fmt.Errorf("%w: order already exists", ErrDuplicate),
+		"invalid": errors.New("VALIDATION_ERROR: bad"),
+		"db":      errors.New("DATABASE_ERROR: connection refused"),
+	}}
+
+	report := &ReplayReport{}
+	next, err := replayRange(context.Background(), source, 1, 6, time.Second, h, false, report)
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(6), next)
+	assert.Equal(t, []int64{1, 2, 3, 4, 5}, h.handled)
+	assert.Equal(t, ReplayReport{Processed: 5, Inserted: 2, Duplicated: 1, Rejected: 1, Failed: 1}, *report)
+}
+
+func TestReplayRange_DryRun(t *testing.T) {
+	source := NewMemorySource("orders")
+	source.Publish(nil, []byte("ok"))
+	source.Publish(nil, []byte("dup"))
+
+	h := &scriptedHandler{results: map[string]error{
+		"dup": fmt.Errorf("%w: order already exists", ErrDuplicate),
+	}}
+
+	report := &ReplayReport{DryRun: true}
+	_, err := replayRange(context.Background(), source, 0, 2, time.Second, h, true, report)
+	require.NoError(t, err)
+
+	assert.Empty(t, h.handled)
+	assert.Equal(t, 2, h.checked)
+	assert.Equal(t, 1, report.Inserted)
+	assert.Equal(t, 1, report.Duplicated)
+}
+
+// The last offsets of the range hold no messages, e.g. transaction markers.
+func TestReplayRange_EndsWithoutMessages(t *testing.T) {
+	source := NewMemorySource("orders")
+	source.Publish(nil, []byte("ok"))
+	source.Publish(nil, []byte("ok"))
+
+	h := &scriptedHandler{}
+	report := &ReplayReport{}
+	next, err := replayRange(context.Background(), source, 0, 4, 20*time.Millisecond, h, false, report)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), next)
+	assert.Equal(t, 2, report.Processed)
+
+	// offset 1 удален компакцией, следующее сообщение уже за концом диапазона
+	source.Publish(nil, []byte("after-end"))
+	h = &scriptedHandler{}
+	next, err = replayRange(context.Background(), source, 1, 2, time.Second, h, false, &ReplayReport{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), next)
+	assert.Empty(t, h.handled)
+}
+
+func TestReplayRange_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	next, err := replayRange(ctx, NewMemorySource("orders"), 3, 5, time.Second, &scriptedHandler{}, false, &ReplayReport{})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, int64(3), next)
+}
+
+func TestReplayer_RequiresRange(t *testing.T) {
+	r := NewReplayer(&scriptedHandler{}, testKafkaConfig())
+	_, err := r.Replay(context.Background(), ReplayRequest{DryRun: true})
+	assert.ErrorIs(t, err, ErrEmptyReplayRange)
+}
@@ -0,0 +1,45 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Egor-Pomidor-pdf/order-service/internal/config"
+)
+
+const (
+	DriverConfluent = "confluent"
+	DriverSegmentio = "segmentio"
+)
+
+// Message is a broker-agnostic record read from a MessageSource.
+type Message struct {
+	Topic     string
+	Partition int32
+	Offset    int64
+	Key       []byte
+	Value     []byte
+	Timestamp time.Time
+}
+
+// MessageSource is the broker client the Consumer reads from.
+type MessageSource interface {
+	// Fetch blocks until the next message is available or ctx is done.
+	Fetch(ctx context.Context) (*Message, error)
+	// Commit marks msg and everything before it in its partition as processed.
+	Commit(ctx context.Context, msg *Message) error
+	Close() error
+}
+
+// NewSource creates a MessageSource for the driver selected in cfg.
+func NewSource(cfg config.KafkaConfig) (MessageSource, error) {
+	switch cfg.Driver {
+	case "", DriverConfluent:
+		return newConfluentSource(cfg)
+	case DriverSegmentio:
+		return newSegmentioSource(cfg), nil
+	default:
+		return nil, fmt.Errorf("unknown kafka driver %q", cfg.Driver)
+	}
+}
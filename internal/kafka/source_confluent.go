@@ -0,0 +1,77 @@
+//go:build cgo
+
+package kafka
+
+import (
+	"context"
+	"strings"
+
+	"github.com/Egor-Pomidor-pdf/order-service/internal/config"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+type confluentSource struct {
+	consumer *kafka.Consumer
+}
+
+func newConfluentSource(cfg config.KafkaConfig) (MessageSource, error) {
+	kafkaConfig := &kafka.ConfigMap{
+		"bootstrap.servers":        strings.Join(cfg.Brokers, ","),
+		"group.id":                 cfg.GroupID,
+		"session.timeout.ms":       sessionTimeout,
+		"enable.auto.offset.store": false,
+		"enable.auto.commit":       false,
+		"auto.offset.reset":        "latest",
+	}
+
+	c, err := kafka.NewConsumer(kafkaConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.Subscribe(cfg.Topic, nil); err != nil {
+		c.Close()
+		return nil, err
+	}
+
+	return &confluentSource{consumer: c}, nil
+}
+
+func (s *confluentSource) Fetch(ctx context.Context) (*Message, error) {
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		kafkaMsg, err := s.consumer.ReadMessage(pollTimeout)
+		if err != nil {
+			if kafkaError, ok := err.(kafka.Error); ok && kafkaError.Code() == kafka.ErrTimedOut {
+				continue
+			}
+			return nil, err
+		}
+
+		return &Message{
+			Topic:     *kafkaMsg.TopicPartition.Topic,
+			Partition: kafkaMsg.TopicPartition.Partition,
+			Offset:    int64(kafkaMsg.TopicPartition.Offset),
+			Key:       kafkaMsg.Key,
+			Value:     kafkaMsg.Value,
+			Timestamp: kafkaMsg.Timestamp,
+		}, nil
+	}
+}
+
+func (s *confluentSource) Commit(_ context.Context, msg *Message) error {
+	topic := msg.Topic
+	_, err := s.consumer.CommitOffsets([]kafka.TopicPartition{{
+		Topic:     &topic,
+		Partition: msg.Partition,
+		Offset:    kafka.Offset(msg.Offset + 1),
+	}})
+	return err
+}
+
+func (s *confluentSource) Close() error {
+	return s.consumer.Close()
+}
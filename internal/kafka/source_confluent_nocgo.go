@@ -0,0 +1,13 @@
+//go:build !cgo
+
+package kafka
+
+import (
+	"errors"
+
+	"github.com/Egor-Pomidor-pdf/order-service/internal/config"
+)
+
+func newConfluentSource(cfg config.KafkaConfig) (MessageSource, error) {
+	return nil, errors.New("confluent kafka driver requires cgo, use the segmentio driver instead")
+}
@@ -0,0 +1,113 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrSourceClosed = errors.New("message source closed")
+
+// MemorySource is an in-process single-partition MessageSource for tests.
+// It keeps the whole log, so Rewind can simulate a consumer restart that
+// resumes from the last committed offset.
+type MemorySource struct {
+	mu        sync.Mutex
+	topic     string
+	log       []Message
+	position  int64
+	committed int64
+	closed    bool
+	notify    chan struct{}
+}
+
+func NewMemorySource(topic string) *MemorySource {
+	return &MemorySource{
+		topic:  topic,
+		notify: make(chan struct{}),
+	}
+}
+
+// Publish appends a message to the log and returns its offset.
+func (s *MemorySource) Publish(key, value []byte) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	offset := int64(len(s.log))
+	s.log = append(s.log, Message{
+		Topic:     s.topic,
+		Offset:    offset,
+		Key:       key,
+		Value:     value,
+		Timestamp: time.Now(),
+	})
+	s.wakeLocked()
+	return offset
+}
+
+func (s *MemorySource) Fetch(ctx context.Context) (*Message, error) {
+	for {
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			return nil, ErrSourceClosed
+		}
+		if s.position < int64(len(s.log)) {
+			msg := s.log[s.position]
+			s.position++
+			s.mu.Unlock()
+			return &msg, nil
+		}
+		notify := s.notify
+		s.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-notify:
+		}
+	}
+}
+
+func (s *MemorySource) Commit(_ context.Context, msg *Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrSourceClosed
+	}
+	if msg.Offset+1 > s.committed {
+		s.committed = msg.Offset + 1
+	}
+	return nil
+}
+
+// Committed returns the next offset a restarted consumer would read,
+// following Kafka semantics (last committed offset + 1).
+func (s *MemorySource) Committed() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.committed
+}
+
+// Rewind moves the read position back to the committed offset.
+func (s *MemorySource) Rewind() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.position = s.committed
+	s.closed = false
+}
+
+func (s *MemorySource) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	s.wakeLocked()
+	return nil
+}
+
+func (s *MemorySource) wakeLocked() {
+	close(s.notify)
+	s.notify = make(chan struct{})
+}
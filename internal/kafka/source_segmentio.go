@@ -0,0 +1,53 @@
+package kafka
+
+import (
+	"context"
+	"time"
+
+	"github.com/Egor-Pomidor-pdf/order-service/internal/config"
+	kafkago "github.com/segmentio/kafka-go"
+)
+
+// segmentioSource is a pure-Go MessageSource that works without cgo/librdkafka.
+type segmentioSource struct {
+	reader *kafkago.Reader
+}
+
+func newSegmentioSource(cfg config.KafkaConfig) MessageSource {
+	reader := kafkago.NewReader(kafkago.ReaderConfig{
+		Brokers:        cfg.Brokers,
+		GroupID:        cfg.GroupID,
+		Topic:          cfg.Topic,
+		SessionTimeout: sessionTimeout * time.Millisecond,
+		StartOffset:    kafkago.LastOffset,
+	})
+	return &segmentioSource{reader: reader}
+}
+
+func (s *segmentioSource) Fetch(ctx context.Context) (*Message, error) {
+	m, err := s.reader.FetchMessage(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Message{
+		Topic:     m.Topic,
+		Partition: int32(m.Partition),
+		Offset:    m.Offset,
+		Key:       m.Key,
+		Value:     m.Value,
+		Timestamp: m.Time,
+	}, nil
+}
+
+func (s *segmentioSource) Commit(ctx context.Context, msg *Message) error {
+	return s.reader.CommitMessages(ctx, kafkago.Message{
+		Topic:     msg.Topic,
+		Partition: int(msg.Partition),
+		Offset:    msg.Offset,
+	})
+}
+
+func (s *segmentioSource) Close() error {
+	return s.reader.Close()
+}
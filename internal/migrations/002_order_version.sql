@@ -0,0 +1,2 @@
+-- Версия заказа для оптимистической блокировки (ETag / If-Match)
+ALTER TABLE orders ADD COLUMN version INT NOT NULL DEFAULT 1;
@@ -0,0 +1,11 @@
+-- Журнал удаления персональных данных клиентов
+CREATE TABLE erasure_audit (
+    id SERIAL PRIMARY KEY,
+    customer_hash TEXT NOT NULL,
+    orders INT NOT NULL,
+    requested_by TEXT NOT NULL,
+    source TEXT NOT NULL,
+    erased_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_orders_customer_id ON orders (customer_id);
@@ -0,0 +1,11 @@
+-- Архив заказов старше срока хранения, структура совпадает с основными таблицами
+CREATE TABLE orders_archive (LIKE orders INCLUDING DEFAULTS INCLUDING INDEXES);
+CREATE TABLE deliveries_archive (LIKE deliveries INCLUDING DEFAULTS INCLUDING INDEXES);
+CREATE TABLE payments_archive (LIKE payments INCLUDING DEFAULTS INCLUDING INDEXES);
+CREATE TABLE items_archive (LIKE items INCLUDING DEFAULTS INCLUDING INDEXES);
+
+CREATE INDEX idx_deliveries_archive_order_uid ON deliveries_archive (order_uid);
+CREATE INDEX idx_payments_archive_order_uid ON payments_archive (order_uid);
+CREATE INDEX idx_items_archive_order_uid ON items_archive (order_uid);
+
+CREATE INDEX idx_orders_date_created ON orders (date_created);
@@ -0,0 +1,129 @@
+-- Помесячное секционирование orders и items по date_created.
+--
+-- Уникальность order_uid в секционированной таблице обеспечить нельзя (ключ
+-- должен включать date_created), поэтому она переезжает в order_keys. Эта
+-- таблица также хранит date_created заказа, чтобы поиск по order_uid
+-- затрагивал одну секцию, и является корнем для ON DELETE CASCADE.
+BEGIN;
+
+CREATE OR REPLACE FUNCTION create_monthly_partition(parent TEXT, month DATE) RETURNS TEXT AS $$
+DECLARE
+    start_at DATE := date_trunc('month', month)::DATE;
+    partition_name TEXT := format('%s_y%sm%s', parent, to_char(start_at, 'YYYY'), to_char(start_at, 'MM'));
+BEGIN
+    EXECUTE format('CREATE TABLE IF NOT EXISTS %I PARTITION OF %I FOR VALUES FROM (%L) TO (%L)',
+                   partition_name, parent, start_at, (start_at + INTERVAL '1 month')::DATE);
+    RETURN partition_name;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TABLE order_keys (
+    order_uid TEXT PRIMARY KEY,
+    date_created TIMESTAMP NOT NULL
+);
+
+UPDATE orders SET date_created = 'epoch' WHERE date_created IS NULL;
+INSERT INTO order_keys (order_uid, date_created) SELECT order_uid, date_created FROM orders;
+
+ALTER TABLE deliveries DROP CONSTRAINT fk_deliveries_orders;
+ALTER TABLE payments DROP CONSTRAINT fk_payments_orders;
+ALTER TABLE deliveries ADD CONSTRAINT fk_deliveries_order_keys
+    FOREIGN KEY (order_uid) REFERENCES order_keys(order_uid) ON DELETE CASCADE;
+ALTER TABLE payments ADD CONSTRAINT fk_payments_order_keys
+    FOREIGN KEY (order_uid) REFERENCES order_keys(order_uid) ON DELETE CASCADE;
+
+ALTER TABLE orders RENAME TO orders_unpartitioned;
+ALTER TABLE items RENAME TO items_unpartitioned;
+-- последовательность id переходит к новой items, на нее же ссылается items_archive
+ALTER SEQUENCE items_id_seq OWNED BY NONE;
+
+-- порядок колонок совпадает со старыми таблицами и *_archive
+CREATE TABLE orders (
+    order_uid TEXT NOT NULL,
+    track_number TEXT,
+    entry TEXT,
+    locale TEXT,
+    customer_id TEXT,
+    internal_signature TEXT,
+    delivery_service TEXT,
+    shardkey TEXT,
+    sm_id INT,
+    date_created TIMESTAMP NOT NULL,
+    oof_shard TEXT,
+    version INT NOT NULL DEFAULT 1,
+    PRIMARY KEY (order_uid, date_created),
+    CONSTRAINT fk_orders_order_keys
+        FOREIGN KEY (order_uid) REFERENCES order_keys(order_uid)
+        ON DELETE CASCADE
+) PARTITION BY RANGE (date_created);
+
+CREATE TABLE items (
+    id INT NOT NULL DEFAULT nextval('items_id_seq'),
+    order_uid TEXT NOT NULL,
+    chrt_id BIGINT,
+    track_number TEXT,
+    price INT,
+    rid TEXT,
+    name TEXT,
+    sale INT,
+    size TEXT,
+    total_price INT,
+    nm_id BIGINT,
+    brand TEXT,
+    status INT,
+    date_created TIMESTAMP NOT NULL,
+    PRIMARY KEY (id, date_created),
+    CONSTRAINT fk_items_order_keys
+        FOREIGN KEY (order_uid) REFERENCES order_keys(order_uid)
+        ON DELETE CASCADE
+) PARTITION BY RANGE (date_created);
+
+-- заказы с датой вне созданных секций
+CREATE TABLE orders_default PARTITION OF orders DEFAULT;
+CREATE TABLE items_default PARTITION OF items DEFAULT;
+
+-- секции для существующих данных и трех месяцев вперед,
+-- дальше их создает фоновая задача сервиса
+DO $$
+DECLARE
+    month DATE;
+BEGIN
+    FOR month IN
+        SELECT generate_series(
+            date_trunc('month', LEAST(COALESCE(first_created, now()), now())),
+            date_trunc('month', now() + INTERVAL '3 months'),
+            INTERVAL '1 month')::DATE
+        FROM (
+            -- заказы без даты получили 'epoch' и попадут в секцию по умолчанию
+            SELECT MIN(date_created) FILTER (WHERE date_created > 'epoch') AS first_created
+            FROM orders_unpartitioned
+        ) bounds
+    LOOP
+        PERFORM create_monthly_partition('orders', month);
+        PERFORM create_monthly_partition('items', month);
+    END LOOP;
+END $$;
+
+INSERT INTO orders SELECT * FROM orders_unpartitioned;
+INSERT INTO items (id, order_uid, chrt_id, track_number, price, rid, name, sale, size,
+                   total_price, nm_id, brand, status, date_created)
+SELECT i.id, i.order_uid, i.chrt_id, i.track_number, i.price, i.rid, i.name, i.sale, i.size,
+       i.total_price, i.nm_id, i.brand, i.status, o.date_created
+FROM items_unpartitioned i
+JOIN orders_unpartitioned o USING (order_uid);
+
+ALTER SEQUENCE items_id_seq OWNED BY items.id;
+
+DROP TABLE items_unpartitioned;
+DROP TABLE orders_unpartitioned;
+
+CREATE INDEX idx_orders_date_created ON orders (date_created);
+CREATE INDEX idx_orders_customer_id ON orders (customer_id);
+CREATE INDEX idx_items_order_uid ON items (order_uid, date_created);
+
+-- архив повторяет структуру items
+ALTER TABLE items_archive ADD COLUMN date_created TIMESTAMP;
+UPDATE items_archive i SET date_created = o.date_created
+FROM orders_archive o WHERE o.order_uid = i.order_uid;
+
+COMMIT;
@@ -0,0 +1,7 @@
+-- Справочник order_uid -> шард, используется в основной БД при включенном шардировании
+CREATE TABLE order_shards (
+    order_uid TEXT PRIMARY KEY,
+    shard TEXT NOT NULL
+);
+
+CREATE INDEX idx_order_shards_shard ON order_shards (shard);
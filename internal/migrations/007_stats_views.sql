@@ -0,0 +1,22 @@
+-- Дневные агрегаты для /stats при STATS_USE_VIEWS=true,
+-- обновляются REFRESH MATERIALIZED VIEW CONCURRENTLY (нужен уникальный индекс)
+CREATE MATERIALIZED VIEW stats_daily_revenue AS
+SELECT date_trunc('day', o.date_created) AS day,
+       COALESCE(p.currency, '') AS currency,
+       COALESCE(SUM(p.amount), 0)::BIGINT AS revenue,
+       COUNT(*) AS orders
+FROM orders o
+JOIN payments p ON p.order_uid = o.order_uid
+GROUP BY 1, 2;
+
+CREATE UNIQUE INDEX idx_stats_daily_revenue ON stats_daily_revenue (day, currency);
+
+CREATE MATERIALIZED VIEW stats_daily_brands AS
+SELECT date_trunc('day', date_created) AS day,
+       COALESCE(brand, '') AS brand,
+       COUNT(*) AS items,
+       COALESCE(SUM(total_price), 0)::BIGINT AS revenue
+FROM items
+GROUP BY 1, 2;
+
+CREATE UNIQUE INDEX idx_stats_daily_brands ON stats_daily_brands (day, brand);
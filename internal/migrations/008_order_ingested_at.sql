@@ -0,0 +1,3 @@
+-- Время сохранения заказа сервисом. У заказов, сохраненных раньше, остается NULL
+ALTER TABLE orders ADD COLUMN ingested_at TIMESTAMP;
+ALTER TABLE orders_archive ADD COLUMN ingested_at TIMESTAMP;
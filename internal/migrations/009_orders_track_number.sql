@@ -0,0 +1,2 @@
+-- Поиск заказов по трек-номеру (gRPC SearchOrders)
+CREATE INDEX idx_orders_track_number ON orders (track_number);
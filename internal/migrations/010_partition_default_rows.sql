@@ -0,0 +1,41 @@
+-- Заказ с датой вне созданных секций попадает в секцию по умолчанию. Создание
+-- секции его месяца после этого падало с "updated partition constraint for
+-- default partition would be violated", поэтому такие строки переносятся в
+-- новую секцию: она создается отдельной таблицей, заполняется строками месяца
+-- из *_default и только затем подключается.
+CREATE OR REPLACE FUNCTION create_monthly_partition(parent TEXT, month DATE) RETURNS TEXT AS $$
+DECLARE
+    start_at DATE := date_trunc('month', month)::DATE;
+    end_at DATE := (date_trunc('month', month) + INTERVAL '1 month')::DATE;
+    partition_name TEXT := format('%s_y%sm%s', parent, to_char(start_at, 'YYYY'), to_char(start_at, 'MM'));
+    default_name TEXT := parent || '_default';
+    has_rows BOOLEAN := FALSE;
+BEGIN
+    IF to_regclass(partition_name) IS NOT NULL THEN
+        RETURN partition_name;
+    END IF;
+
+    IF to_regclass(default_name) IS NOT NULL THEN
+        -- вставки в секцию по умолчанию ждут до конца транзакции, иначе строка,
+        -- пришедшая после проверки, не даст подключить секцию
+        EXECUTE format('LOCK TABLE %I IN SHARE ROW EXCLUSIVE MODE', default_name);
+        EXECUTE format('SELECT EXISTS (SELECT 1 FROM %I WHERE date_created >= %L AND date_created < %L)',
+                       default_name, start_at, end_at) INTO has_rows;
+    END IF;
+
+    IF NOT has_rows THEN
+        EXECUTE format('CREATE TABLE %I PARTITION OF %I FOR VALUES FROM (%L) TO (%L)',
+                       partition_name, parent, start_at, end_at);
+        RETURN partition_name;
+    END IF;
+
+    EXECUTE format('CREATE TABLE %I (LIKE %I INCLUDING DEFAULTS)', partition_name, parent);
+    EXECUTE format('WITH moved AS (DELETE FROM %I WHERE date_created >= %L AND date_created < %L RETURNING *)
+                    INSERT INTO %I SELECT * FROM moved',
+                   default_name, start_at, end_at, partition_name);
+    EXECUTE format('ALTER TABLE %I ATTACH PARTITION %I FOR VALUES FROM (%L) TO (%L)',
+                   parent, partition_name, start_at, end_at);
+    RAISE NOTICE 'moved rows of % from % to %', to_char(start_at, 'YYYY-MM'), default_name, partition_name;
+    RETURN partition_name;
+END;
+$$ LANGUAGE plpgsql;
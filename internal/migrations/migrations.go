@@ -0,0 +1,47 @@
+// Package migrations holds the SQL schema of the order database. In Docker
+// the files are applied by the Postgres entrypoint, Apply runs them from Go,
+// e.g. against a throwaway test database.
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+)
+
+//go:embed *.sql
+var files embed.FS
+
+// Apply runs every migration in file name order on an empty database.
+// Down migrations and empty files are skipped.
+func Apply(ctx context.Context, db *sql.DB) error {
+	const op = "migrations.Apply"
+
+	names, err := fs.Glob(files, "*.sql")
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if strings.HasSuffix(name, ".down.sql") {
+			continue
+		}
+		script, err := files.ReadFile(name)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if strings.TrimSpace(string(script)) == "" {
+			continue
+		}
+		// без параметров lib/pq выполняет весь файл одним простым запросом
+		if _, err := db.ExecContext(ctx, string(script)); err != nil {
+			return fmt.Errorf("%s: %s: %w", op, name, err)
+		}
+	}
+	return nil
+}
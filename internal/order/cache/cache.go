@@ -0,0 +1,69 @@
+// Package cache holds the order caches of OrderService: an in-process map
+// (L1) and an optional shared Redis tier (L2) behind one interface.
+package cache
+
+import (
+	"context"
+
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order"
+)
+
+// Cache stores orders by order_uid. Failures of a remote tier are not
+// returned: they are logged and behave like a miss, so callers fall back
+// to the repository.
+type Cache interface {
+	Get(ctx context.Context, uid string) (order.Order, bool)
+	Set(ctx context.Context, o order.Order)
+	Delete(ctx context.Context, uids ...string)
+	// Invalidate drops uid unless the cached copy already has version or a
+	// newer one. Version 0 always drops it. The shared tier remembers version
+	// for a while and refuses to store older copies of uid.
+	Invalidate(ctx context.Context, uid string, version int)
+	// Reset empties the cache of this replica.
+	Reset(ctx context.Context)
+}
+
+// stale reports whether a cached version is older than a notified one.
+func stale(cached, version int) bool {
+	return version == 0 || cached < version
+}
+
+// Tiered reads through L1 to L2 and writes to both.
+type Tiered struct {
+	l1, l2 Cache
+}
+
+func NewTiered(l1, l2 Cache) *Tiered {
+	return &Tiered{l1: l1, l2: l2}
+}
+
+func (t *Tiered) Get(ctx context.Context, uid string) (order.Order, bool) {
+	if o, ok := t.l1.Get(ctx, uid); ok {
+		return o, true
+	}
+	o, ok := t.l2.Get(ctx, uid)
+	if ok {
+		t.l1.Set(ctx, o)
+	}
+	return o, ok
+}
+
+func (t *Tiered) Set(ctx context.Context, o order.Order) {
+	t.l1.Set(ctx, o)
+	t.l2.Set(ctx, o)
+}
+
+func (t *Tiered) Delete(ctx context.Context, uids ...string) {
+	t.l1.Delete(ctx, uids...)
+	t.l2.Delete(ctx, uids...)
+}
+
+func (t *Tiered) Invalidate(ctx context.Context, uid string, version int) {
+	t.l1.Invalidate(ctx, uid, version)
+	t.l2.Invalidate(ctx, uid, version)
+}
+
+// Reset only empties L1: L2 is shared and kept up to date by the writers.
+func (t *Tiered) Reset(ctx context.Context) {
+	t.l1.Reset(ctx)
+}
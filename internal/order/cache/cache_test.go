@@ -0,0 +1,233 @@
+package cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Egor-Pomidor-pdf/order-service/internal/config"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/fieldcrypt"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var ctx = context.Background()
+
+func testOrder(uid string, version int) order.Order {
+	return order.Order{
+		OrderUID:    uid,
+		CustomerID:  "test",
+		DateCreated: time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+		Payment:     order.Payment{Currency: "USD", Amount: 1817},
+		Items:       []order.Item{{ChrtID: 9934930, Name: "Mascaras", TotalPrice: 317}},
+		Version:     version,
+	}
+}
+
+func newRedis(t *testing.T, opts ...RedisOption) (*Redis, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	r := NewRedis(config.RedisConfig{Addr: mr.Addr(), Prefix: "order:", TTL: time.Hour, Timeout: time.Second}, opts...)
+	t.Cleanup(func() { r.Close() })
+	return r, mr
+}
+
+func TestMemory(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	m := NewMemory(time.Minute)
+	m.now = func() time.Time { return now }
+
+	m.Set(ctx, testOrder("a1", 2))
+	m.Set(ctx, testOrder("a1", 1))
+	m.Set(ctx, testOrder("a2", 1))
+
+	got, ok := m.Get(ctx, "a1")
+	require.True(t, ok)
+	assert.Equal(t, 2, got.Version, "older version does not overwrite")
+
+	m.Invalidate(ctx, "a1", 2)
+	_, ok = m.Get(ctx, "a1")
+	assert.True(t, ok, "own or repeated notification keeps the entry")
+
+	m.Invalidate(ctx, "a1", 3)
+	_, ok = m.Get(ctx, "a1")
+	assert.False(t, ok)
+
+	now = now.Add(2 * time.Minute)
+	_, ok = m.Get(ctx, "a2")
+	assert.False(t, ok, "expired")
+	assert.Zero(t, m.Len())
+}
+
+func TestMemory_Tombstone(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	m := NewMemory(0)
+	m.now = func() time.Time { return now }
+
+	// реплика прочитала версию 1, а уведомление о версии 2 пришло раньше Set
+	m.Invalidate(ctx, "a1", 2)
+	m.Set(ctx, testOrder("a1", 1))
+	_, ok := m.Get(ctx, "a1")
+	assert.False(t, ok, "older version is fenced off")
+	assert.Zero(t, m.Len())
+
+	m.Set(ctx, testOrder("a1", 2))
+	got, ok := m.Get(ctx, "a1")
+	require.True(t, ok)
+	assert.Equal(t, 2, got.Version)
+
+	// удаление: версия на единицу больше последней
+	m.Invalidate(ctx, "a1", 3)
+	m.Set(ctx, testOrder("a1", 2))
+	_, ok = m.Get(ctx, "a1")
+	assert.False(t, ok)
+
+	now = now.Add(tombstoneTTL + time.Second)
+	m.Set(ctx, testOrder("a1", 2))
+	_, ok = m.Get(ctx, "a1")
+	assert.True(t, ok, "tombstone expired")
+
+	m.Invalidate(ctx, "a2", 1)
+	assert.NotContains(t, m.tombstones, "a1", "expired tombstones are swept")
+}
+
+func TestRedis_RoundTrip(t *testing.T) {
+	r, mr := newRedis(t)
+
+	want := testOrder("a1", 3)
+	r.Set(ctx, want)
+
+	got, ok := r.Get(ctx, "a1")
+	require.True(t, ok)
+	assert.Equal(t, want, got)
+	assert.Equal(t, time.Hour, mr.TTL("order:a1"))
+
+	raw, err := mr.Get("order:a1")
+	require.NoError(t, err)
+	assert.Equal(t, "3:", raw[:2])
+
+	_, ok = r.Get(ctx, "missing")
+	assert.False(t, ok)
+}
+
+func TestRedis_Versions(t *testing.T) {
+	r, _ := newRedis(t)
+
+	r.Set(ctx, testOrder("a1", 3))
+	r.Set(ctx, testOrder("a1", 2))
+	got, _ := r.Get(ctx, "a1")
+	assert.Equal(t, 3, got.Version, "older version does not replace a newer one")
+
+	r.Invalidate(ctx, "a1", 3)
+	_, ok := r.Get(ctx, "a1")
+	assert.True(t, ok)
+
+	r.Invalidate(ctx, "a1", 4)
+	_, ok = r.Get(ctx, "a1")
+	assert.False(t, ok)
+
+	r.Set(ctx, testOrder("a2", 1))
+	r.Invalidate(ctx, "a2", 0)
+	_, ok = r.Get(ctx, "a2")
+	assert.False(t, ok)
+}
+
+func TestRedis_Tombstone(t *testing.T) {
+	r, mr := newRedis(t)
+
+	// реплика прочитала версию 3 до удаления и пишет ее в кеш после
+	r.Invalidate(ctx, "a1", 4)
+	raw, err := mr.Get("order:a1")
+	require.NoError(t, err)
+	assert.Equal(t, "4:", raw)
+	assert.Equal(t, tombstoneTTL, mr.TTL("order:a1"))
+
+	r.Set(ctx, testOrder("a1", 3))
+	_, ok := r.Get(ctx, "a1")
+	assert.False(t, ok, "stale copy is refused")
+
+	r.Set(ctx, testOrder("a1", 4))
+	got, ok := r.Get(ctx, "a1")
+	require.True(t, ok)
+	assert.Equal(t, 4, got.Version)
+	assert.Equal(t, time.Hour, mr.TTL("order:a1"))
+
+	mr.FastForward(tombstoneTTL)
+	r.Invalidate(ctx, "a2", 2)
+	mr.FastForward(tombstoneTTL)
+	assert.False(t, mr.Exists("order:a2"), "tombstones expire")
+}
+
+func TestRedis_SealedDelivery(t *testing.T) {
+	cipher, err := fieldcrypt.New(bytes.Repeat([]byte{1}, 32))
+	require.NoError(t, err)
+	r, mr := newRedis(t, WithFieldCipher(cipher))
+
+	want := testOrder("a1", 1)
+	want.Delivery = order.Delivery{Name: "Test Testov", Phone: "+9720000000", Email: "test@gmail.com", Address: "Ploshad Mira 15", City: "Kiryat Mozkin"}
+	r.Set(ctx, want)
+
+	raw, err := mr.Get("order:a1")
+	require.NoError(t, err)
+	zr, err := gzip.NewReader(strings.NewReader(raw[strings.IndexByte(raw, ':')+1:]))
+	require.NoError(t, err)
+	stored, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	for _, pii := range []string{"Testov", "+9720000000", "test@gmail.com", "Ploshad"} {
+		assert.NotContains(t, string(stored), pii)
+	}
+	assert.Contains(t, string(stored), "Kiryat Mozkin")
+
+	got, ok := r.Get(ctx, "a1")
+	require.True(t, ok)
+	assert.Equal(t, want, got)
+}
+
+func TestRedis_Unavailable(t *testing.T) {
+	r, mr := newRedis(t)
+	r.Set(ctx, testOrder("a1", 1))
+	mr.Close()
+
+	_, ok := r.Get(ctx, "a1")
+	assert.False(t, ok)
+	assert.True(t, r.down())
+
+	// пока L2 помечен недоступным, запросы к нему не отправляются
+	start := time.Now()
+	r.Set(ctx, testOrder("a2", 1))
+	_, ok = r.Get(ctx, "a2")
+	assert.False(t, ok)
+	assert.Less(t, time.Since(start), 10*time.Millisecond)
+}
+
+func TestTiered(t *testing.T) {
+	r, _ := newRedis(t)
+	l1 := NewMemory(0)
+	tiered := NewTiered(l1, r)
+
+	tiered.Set(ctx, testOrder("a1", 1))
+
+	// другая реплика: пустой L1, общий L2
+	other := NewTiered(NewMemory(0), r)
+	got, ok := other.Get(ctx, "a1")
+	require.True(t, ok)
+	assert.Equal(t, "a1", got.OrderUID)
+
+	tiered.Reset(ctx)
+	assert.Zero(t, l1.Len())
+	_, ok = tiered.Get(ctx, "a1")
+	assert.True(t, ok, "reset keeps the shared tier")
+	assert.Equal(t, 1, l1.Len(), "L2 hit fills L1")
+
+	tiered.Delete(ctx, "a1")
+	_, ok = other.Get(ctx, "a1")
+	assert.True(t, ok, "L1 of the other replica is dropped by notifications")
+	_, ok = NewTiered(NewMemory(0), r).Get(ctx, "a1")
+	assert.False(t, ok)
+}
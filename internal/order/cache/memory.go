@@ -0,0 +1,137 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order"
+)
+
+type memoryEntry struct {
+	order   order.Order
+	expires time.Time
+}
+
+// memoryTombstone keeps the version of a changed or removed order for
+// tombstoneTTL, like the tombstone in Redis.
+type memoryTombstone struct {
+	version int
+	expires time.Time
+}
+
+// Memory is the in-process L1 cache.
+type Memory struct {
+	mu         sync.RWMutex
+	entries    map[string]memoryEntry
+	tombstones map[string]memoryTombstone
+	swept      time.Time
+	ttl        time.Duration
+	now        func() time.Time
+}
+
+// NewMemory creates a cache whose entries expire after ttl, 0 keeps them
+// until they are deleted.
+func NewMemory(ttl time.Duration) *Memory {
+	return &Memory{
+		entries:    make(map[string]memoryEntry),
+		tombstones: make(map[string]memoryTombstone),
+		ttl:        ttl,
+		now:        time.Now,
+	}
+}
+
+func (m *Memory) Get(_ context.Context, uid string) (order.Order, bool) {
+	m.mu.RLock()
+	e, ok := m.entries[uid]
+	m.mu.RUnlock()
+	if !ok {
+		return order.Order{}, false
+	}
+	if !e.expires.IsZero() && m.now().After(e.expires) {
+		m.mu.Lock()
+		// запись могла обновиться, пока блокировка была отпущена
+		if cur, ok := m.entries[uid]; ok && cur.expires.Equal(e.expires) {
+			delete(m.entries, uid)
+		}
+		m.mu.Unlock()
+		return order.Order{}, false
+	}
+	return e.order, true
+}
+
+func (m *Memory) Set(_ context.Context, o order.Order) {
+	now := m.now()
+	e := memoryEntry{order: o}
+	if m.ttl > 0 {
+		e.expires = now.Add(m.ttl)
+	}
+	m.mu.Lock()
+	// копия, прочитанная до изменения, не должна вернуться после Invalidate
+	if t, ok := m.tombstones[o.OrderUID]; ok && now.Before(t.expires) && t.version > o.Version {
+		m.mu.Unlock()
+		return
+	}
+	// как и в Redis, более новая версия не перезаписывается, например, прогревом
+	cur, ok := m.entries[o.OrderUID]
+	if !ok || cur.order.Version <= o.Version || (!cur.expires.IsZero() && now.After(cur.expires)) {
+		m.entries[o.OrderUID] = e
+	}
+	m.mu.Unlock()
+}
+
+func (m *Memory) Delete(_ context.Context, uids ...string) {
+	m.mu.Lock()
+	for _, uid := range uids {
+		delete(m.entries, uid)
+	}
+	m.mu.Unlock()
+}
+
+// Invalidate drops an older copy and, for a versioned change, leaves a
+// tombstone that makes Set refuse versions below it for tombstoneTTL.
+func (m *Memory) Invalidate(_ context.Context, uid string, version int) {
+	now := m.now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.entries[uid]
+	if ok && !stale(e.order.Version, version) {
+		return
+	}
+	delete(m.entries, uid)
+	if version == 0 {
+		return
+	}
+	if t, ok := m.tombstones[uid]; !ok || t.version < version || now.After(t.expires) {
+		m.tombstones[uid] = memoryTombstone{version: version, expires: now.Add(tombstoneTTL)}
+	}
+	m.sweep(now)
+}
+
+// sweep removes expired tombstones, at most once per tombstoneTTL.
+func (m *Memory) sweep(now time.Time) {
+	if now.Sub(m.swept) < tombstoneTTL {
+		return
+	}
+	m.swept = now
+	for uid, t := range m.tombstones {
+		if now.After(t.expires) {
+			delete(m.tombstones, uid)
+		}
+	}
+}
+
+func (m *Memory) Reset(context.Context) {
+	m.mu.Lock()
+	m.entries = make(map[string]memoryEntry)
+	m.mu.Unlock()
+}
+
+// Len returns the number of entries, expired ones included and tombstones
+// excluded.
+func (m *Memory) Len() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.entries)
+}
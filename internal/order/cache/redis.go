@@ -0,0 +1,258 @@
+package cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/Egor-Pomidor-pdf/order-service/internal/config"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/fieldcrypt"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order"
+	"github.com/redis/go-redis/v9"
+)
+
+// downFor is how long the L2 is skipped after a failed call, so an outage
+// does not add a timeout to every request.
+const downFor = 5 * time.Second
+
+// tombstoneTTL is how long a removed or changed order keeps its version in
+// the L2, long enough for reads that started before the change to finish.
+const tombstoneTTL = time.Minute
+
+// Values are "<version>:<gzipped order JSON>", the version prefix lets the
+// scripts below compare versions without decoding the order. A tombstone is
+// just "<version>:", it is a miss for Get and fences off older versions.
+var (
+	// setScript does not replace a newer version, e.g. one written by
+	// UpdateOrder while this replica was reading the old one.
+	setScript = redis.NewScript(`
+local cur = redis.call('GET', KEYS[1])
+if cur then
+	local v = tonumber(string.match(cur, '^(%d+):'))
+	if v and v > tonumber(ARGV[1]) then
+		return 0
+	end
+end
+if tonumber(ARGV[3]) > 0 then
+	redis.call('SET', KEYS[1], ARGV[2], 'PX', ARGV[3])
+else
+	redis.call('SET', KEYS[1], ARGV[2])
+end
+return 1`)
+
+	// invalidateScript replaces an older copy with a tombstone, so a Set of
+	// the old version by a replica that read it before the change is refused.
+	invalidateScript = redis.NewScript(`
+local cur = redis.call('GET', KEYS[1])
+if cur then
+	local v = tonumber(string.match(cur, '^(%d+):'))
+	if v and v >= tonumber(ARGV[1]) then
+		return 0
+	end
+end
+redis.call('SET', KEYS[1], ARGV[1] .. ':', 'PX', ARGV[2])
+return 1`)
+)
+
+// Redis is the shared L2 cache.
+type Redis struct {
+	client    *redis.Client
+	prefix    string
+	ttl       time.Duration
+	timeout   time.Duration
+	cipher    *fieldcrypt.Cipher
+	downUntil atomic.Int64
+	now       func() time.Time
+}
+
+type RedisOption func(*Redis)
+
+// WithFieldCipher stores the delivery name, phone, email and address
+// encrypted, the same way the repository keeps them at rest.
+func WithFieldCipher(c *fieldcrypt.Cipher) RedisOption {
+	return func(r *Redis) {
+		r.cipher = c
+	}
+}
+
+func NewRedis(cfg config.RedisConfig, opts ...RedisOption) *Redis {
+	r := &Redis{
+		client: redis.NewClient(&redis.Options{
+			Addr:         cfg.Addr,
+			Password:     cfg.Password,
+			DB:           cfg.DB,
+			DialTimeout:  cfg.Timeout,
+			ReadTimeout:  cfg.Timeout,
+			WriteTimeout: cfg.Timeout,
+		}),
+		prefix:  cfg.Prefix,
+		ttl:     cfg.TTL,
+		timeout: cfg.Timeout,
+		now:     time.Now,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+func (r *Redis) Close() error {
+	return r.client.Close()
+}
+
+func (r *Redis) key(uid string) string {
+	return r.prefix + uid
+}
+
+func (r *Redis) Get(ctx context.Context, uid string) (order.Order, bool) {
+	if r.down() {
+		return order.Order{}, false
+	}
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	data, err := r.client.Get(ctx, r.key(uid)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return order.Order{}, false
+	}
+	if err != nil {
+		r.fail("get", err)
+		return order.Order{}, false
+	}
+
+	o, ok, err := r.decode(data)
+	if err != nil {
+		slog.Error("invalid cached order", "uid", uid, "error", err)
+		return order.Order{}, false
+	}
+	return o, ok
+}
+
+func (r *Redis) Set(ctx context.Context, o order.Order) {
+	if r.down() {
+		return
+	}
+	data, err := r.encode(o)
+	if err != nil {
+		slog.Error("failed to encode order for cache", "uid", o.OrderUID, "error", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	err = setScript.Run(ctx, r.client, []string{r.key(o.OrderUID)}, o.Version, data, r.ttl.Milliseconds()).Err()
+	if err != nil {
+		r.fail("set", err)
+	}
+}
+
+func (r *Redis) Delete(ctx context.Context, uids ...string) {
+	if len(uids) == 0 || r.down() {
+		return
+	}
+	keys := make([]string, len(uids))
+	for i, uid := range uids {
+		keys[i] = r.key(uid)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	if err := r.client.Del(ctx, keys...).Err(); err != nil {
+		r.fail("delete", err)
+	}
+}
+
+func (r *Redis) Invalidate(ctx context.Context, uid string, version int) {
+	if version == 0 {
+		r.Delete(ctx, uid)
+		return
+	}
+	if r.down() {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	err := invalidateScript.Run(ctx, r.client, []string{r.key(uid)}, version, tombstoneTTL.Milliseconds()).Err()
+	if err != nil {
+		r.fail("invalidate", err)
+	}
+}
+
+// Reset does nothing, the L2 is shared by all replicas.
+func (r *Redis) Reset(context.Context) {}
+
+func (r *Redis) down() bool {
+	return r.now().UnixNano() < r.downUntil.Load()
+}
+
+func (r *Redis) fail(op string, err error) {
+	until := r.now().Add(downFor).UnixNano()
+	if prev := r.downUntil.Swap(until); r.now().UnixNano() >= prev {
+		slog.Warn("redis cache unavailable, using the database", "op", op, "error", err, "retry_in", downFor)
+	}
+}
+
+// entry keeps fields the order JSON does not carry.
+type entry struct {
+	Order   order.Order `json:"order"`
+	Version int         `json:"version"`
+}
+
+func (r *Redis) encode(o order.Order) ([]byte, error) {
+	delivery, err := r.cipher.SealDelivery(o.Delivery)
+	if err != nil {
+		return nil, err
+	}
+	o.Delivery = delivery
+
+	var buf bytes.Buffer
+	buf.WriteString(strconv.Itoa(o.Version))
+	buf.WriteByte(':')
+
+	zw, _ := gzip.NewWriterLevel(&buf, gzip.BestSpeed)
+	if err := json.NewEncoder(zw).Encode(entry{Order: o, Version: o.Version}); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decode returns false for a tombstone.
+func (r *Redis) decode(data []byte) (order.Order, bool, error) {
+	i := bytes.IndexByte(data, ':')
+	if i < 0 {
+		return order.Order{}, false, fmt.Errorf("missing version prefix")
+	}
+	if i == len(data)-1 {
+		return order.Order{}, false, nil
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(data[i+1:]))
+	if err != nil {
+		return order.Order{}, false, err
+	}
+	raw, err := io.ReadAll(zr)
+	if err != nil {
+		return order.Order{}, false, err
+	}
+
+	var e entry
+	if err := json.Unmarshal(raw, &e); err != nil {
+		return order.Order{}, false, err
+	}
+	if err := r.cipher.OpenDelivery(&e.Order.Delivery); err != nil {
+		return order.Order{}, false, err
+	}
+	e.Order.Version = e.Version
+	return e.Order, true, nil
+}
@@ -0,0 +1,186 @@
+package cache
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Egor-Pomidor-pdf/order-service/internal/fieldcrypt"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order"
+)
+
+const snapshotFormat = 1
+
+// Lister is implemented by caches whose entries can be enumerated.
+type Lister interface {
+	Orders(ctx context.Context) []order.Order
+}
+
+// Orders returns the entries that have not expired.
+func (m *Memory) Orders(context.Context) []order.Order {
+	now := m.now()
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	orders := make([]order.Order, 0, len(m.entries))
+	for _, e := range m.entries {
+		if e.expires.IsZero() || now.Before(e.expires) {
+			orders = append(orders, e.order)
+		}
+	}
+	return orders
+}
+
+// Orders returns the entries of L1, the shared L2 is not enumerated.
+func (t *Tiered) Orders(ctx context.Context) []order.Order {
+	if l, ok := t.l1.(Lister); ok {
+		return l.Orders(ctx)
+	}
+	return nil
+}
+
+// Snapshot is a copy of the cache saved to a local file. Watermark is the
+// latest ingested_at of the saved orders, orders stored after it have to be
+// read from the database.
+type Snapshot struct {
+	CreatedAt time.Time
+	Watermark time.Time
+	Orders    []order.Order
+}
+
+// snapshotHeader is the first line of the file, followed by the gzipped
+// JSON lines of the orders. Checksum is the SHA-256 of the gzipped part.
+type snapshotHeader struct {
+	Format    int       `json:"format"`
+	CreatedAt time.Time `json:"created_at"`
+	Watermark time.Time `json:"watermark"`
+	Orders    int       `json:"orders"`
+	Checksum  string    `json:"sha256"`
+}
+
+// WriteSnapshot saves orders to path atomically. Delivery PII is sealed with
+// cipher as in the database, a nil cipher leaves it in clear text; either
+// way the file is only readable by the owner.
+func WriteSnapshot(path string, orders []order.Order, now time.Time, cipher *fieldcrypt.Cipher) error {
+	h := snapshotHeader{Format: snapshotFormat, CreatedAt: now.UTC(), Orders: len(orders)}
+
+	var body bytes.Buffer
+	zw, _ := gzip.NewWriterLevel(&body, gzip.BestSpeed)
+	enc := json.NewEncoder(zw)
+	for _, o := range orders {
+		if o.IngestedAt != nil && o.IngestedAt.After(h.Watermark) {
+			h.Watermark = o.IngestedAt.UTC()
+		}
+		delivery, err := cipher.SealDelivery(o.Delivery)
+		if err != nil {
+			return fmt.Errorf("seal delivery of %s: %w", o.OrderUID, err)
+		}
+		o.Delivery = delivery
+		if err := enc.Encode(entry{Order: o, Version: o.Version}); err != nil {
+			return err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	sum := sha256.Sum256(body.Bytes())
+	h.Checksum = hex.EncodeToString(sum[:])
+
+	header, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(append(header, '\n'))
+	if err == nil {
+		_, err = tmp.Write(body.Bytes())
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// ReadSnapshot loads a snapshot written by WriteSnapshot and opens the
+// sealed fields with cipher. A missing file returns os.ErrNotExist, a
+// damaged one or one sealed with another key an error and no orders.
+func ReadSnapshot(path string, cipher *fieldcrypt.Cipher) (*Snapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	line, err := r.ReadBytes('\n')
+	if err != nil {
+		return nil, fmt.Errorf("read snapshot header: %w", err)
+	}
+	var h snapshotHeader
+	if err := json.Unmarshal(line, &h); err != nil {
+		return nil, fmt.Errorf("read snapshot header: %w", err)
+	}
+	if h.Format != snapshotFormat {
+		return nil, fmt.Errorf("unsupported snapshot format %d", h.Format)
+	}
+
+	hash := sha256.New()
+	body := io.TeeReader(r, hash)
+	zr, err := gzip.NewReader(body)
+	if err != nil {
+		return nil, fmt.Errorf("read snapshot: %w", err)
+	}
+	orders := make([]order.Order, 0, h.Orders)
+	dec := json.NewDecoder(zr)
+	for {
+		var e entry
+		err := dec.Decode(&e)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read snapshot: %w", err)
+		}
+		if err := cipher.OpenDelivery(&e.Order.Delivery); err != nil {
+			return nil, fmt.Errorf("read snapshot: open delivery of %s: %w", e.Order.OrderUID, err)
+		}
+		e.Order.Version = e.Version
+		orders = append(orders, e.Order)
+	}
+	// дочитываем хвост gzip, чтобы контрольная сумма покрыла весь файл
+	if _, err := io.Copy(io.Discard, body); err != nil {
+		return nil, fmt.Errorf("read snapshot: %w", err)
+	}
+
+	if sum := hex.EncodeToString(hash.Sum(nil)); sum != h.Checksum {
+		return nil, errors.New("snapshot checksum mismatch")
+	}
+	if len(orders) != h.Orders {
+		return nil, fmt.Errorf("snapshot has %d orders, header says %d", len(orders), h.Orders)
+	}
+	return &Snapshot{CreatedAt: h.CreatedAt, Watermark: h.Watermark, Orders: orders}, nil
+}
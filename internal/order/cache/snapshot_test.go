@@ -0,0 +1,100 @@
+package cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Egor-Pomidor-pdf/order-service/internal/fieldcrypt"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshot_RoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache", "orders.snapshot")
+
+	ingested := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	later := ingested.Add(time.Hour)
+	a1, a2, a3 := testOrder("a1", 2), testOrder("a2", 1), testOrder("a3", 1)
+	a1.IngestedAt, a2.IngestedAt = &ingested, &later
+
+	now := time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, WriteSnapshot(path, []order.Order{a1, a2, a3}, now, nil))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	snap, err := ReadSnapshot(path, nil)
+	require.NoError(t, err)
+	assert.Equal(t, now, snap.CreatedAt)
+	assert.True(t, later.Equal(snap.Watermark))
+	require.Len(t, snap.Orders, 3)
+	assert.Equal(t, 2, snap.Orders[0].Version)
+	assert.Equal(t, a3, snap.Orders[2])
+}
+
+func TestSnapshot_SealedDelivery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "orders.snapshot")
+	cipher, err := fieldcrypt.New(bytes.Repeat([]byte{1}, 32))
+	require.NoError(t, err)
+
+	want := testOrder("a1", 1)
+	want.Delivery = order.Delivery{Name: "Test Testov", Phone: "+9720000000", Email: "test@gmail.com", Address: "Ploshad Mira 15", City: "Kiryat Mozkin"}
+	require.NoError(t, WriteSnapshot(path, []order.Order{want}, time.Now(), cipher))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	zr, err := gzip.NewReader(bytes.NewReader(data[bytes.IndexByte(data, '\n')+1:]))
+	require.NoError(t, err)
+	stored, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	for _, pii := range []string{"Testov", "+9720000000", "test@gmail.com", "Ploshad"} {
+		assert.NotContains(t, string(stored), pii)
+	}
+	assert.Contains(t, string(stored), "Kiryat Mozkin")
+
+	snap, err := ReadSnapshot(path, cipher)
+	require.NoError(t, err)
+	require.Len(t, snap.Orders, 1)
+	assert.Equal(t, want, snap.Orders[0])
+
+	other, err := fieldcrypt.New(bytes.Repeat([]byte{2}, 32))
+	require.NoError(t, err)
+	_, err = ReadSnapshot(path, other)
+	assert.Error(t, err, "sealed with another key")
+}
+
+func TestSnapshot_Damaged(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "orders.snapshot")
+	require.NoError(t, WriteSnapshot(path, []order.Order{testOrder("a1", 1)}, time.Now(), nil))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	data[len(data)-5] ^= 0xff
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+
+	_, err = ReadSnapshot(path, nil)
+	assert.Error(t, err)
+
+	_, err = ReadSnapshot(filepath.Join(t.TempDir(), "missing"), nil)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestMemory_Orders(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	m := NewMemory(time.Minute)
+	m.now = func() time.Time { return now }
+	m.Set(ctx, testOrder("a1", 1))
+	now = now.Add(2 * time.Minute)
+	m.Set(ctx, testOrder("a2", 1))
+
+	orders := NewTiered(m, NewMemory(0)).Orders(ctx)
+	require.Len(t, orders, 1)
+	assert.Equal(t, "a2", orders[0].OrderUID)
+}
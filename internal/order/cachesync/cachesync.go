@@ -0,0 +1,97 @@
+// Package cachesync keeps the order caches of several replicas coherent. The
+// repository announces every change of a stored order with Postgres NOTIFY,
+// each replica LISTENs on all databases and drops stale cached copies.
+package cachesync
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order/repository"
+	"github.com/lib/pq"
+)
+
+const (
+	minReconnect = time.Second
+	maxReconnect = time.Minute
+	// pingInterval detects a silently dropped connection, see pq.Listener.
+	pingInterval = 90 * time.Second
+)
+
+// Cache is the local order cache, implemented by service.OrderService.
+type Cache interface {
+	Invalidate(uid string, version int)
+	Reset()
+}
+
+type Listener struct {
+	conns []string
+	cache Cache
+}
+
+// NewListener listens on every database in conns, the main one and each
+// shard, given as lib/pq connection strings.
+func NewListener(conns []string, cache Cache) *Listener {
+	return &Listener{conns: conns, cache: cache}
+}
+
+// Run applies notifications to the cache until ctx is done.
+func (l *Listener) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i, conn := range l.conns {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l.listen(ctx, i, conn)
+		}()
+	}
+	wg.Wait()
+}
+
+func (l *Listener) listen(ctx context.Context, db int, conn string) {
+	pl := pq.NewListener(conn, minReconnect, maxReconnect, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			slog.Warn("order changes listener", "db", db, "event", ev, "error", err)
+		}
+	})
+	defer pl.Close()
+
+	if err := pl.Listen(repository.ChangesChannel); err != nil {
+		slog.Error("failed to listen for order changes", "db", db, "error", err)
+		return
+	}
+	slog.Info("listening for order changes", "db", db)
+
+	ping := time.NewTicker(pingInterval)
+	defer ping.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case n := <-pl.Notify:
+			l.handle(n)
+		case <-ping.C:
+			go pl.Ping()
+		}
+	}
+}
+
+// handle applies one notification. pq sends nil after a reconnect, the
+// changes made while disconnected are unknown then and the cache is reset.
+func (l *Listener) handle(n *pq.Notification) {
+	if n == nil {
+		slog.Warn("order changes listener reconnected, resetting cache")
+		l.cache.Reset()
+		return
+	}
+
+	var change repository.Change
+	if err := json.Unmarshal([]byte(n.Extra), &change); err != nil || change.OrderUID == "" {
+		slog.Error("invalid order change notification", "payload", n.Extra, "error", err)
+		return
+	}
+	l.cache.Invalidate(change.OrderUID, change.Version)
+}
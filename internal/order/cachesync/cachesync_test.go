@@ -0,0 +1,37 @@
+package cachesync
+
+import (
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeCache struct {
+	invalidated map[string]int
+	resets      int
+}
+
+func (c *fakeCache) Invalidate(uid string, version int) {
+	c.invalidated[uid] = version
+}
+
+func (c *fakeCache) Reset() {
+	c.resets++
+}
+
+func TestListener_Handle(t *testing.T) {
+	cache := &fakeCache{invalidated: make(map[string]int)}
+	l := NewListener(nil, cache)
+
+	l.handle(&pq.Notification{Extra: `{"order_uid":"a1","version":3}`})
+	l.handle(&pq.Notification{Extra: `{"order_uid":"a2"}`})
+	l.handle(&pq.Notification{Extra: `not json`})
+	l.handle(&pq.Notification{Extra: `{"version":2}`})
+
+	assert.Equal(t, map[string]int{"a1": 3, "a2": 0}, cache.invalidated)
+	assert.Zero(t, cache.resets)
+
+	l.handle(nil)
+	assert.Equal(t, 1, cache.resets)
+}
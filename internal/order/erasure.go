@@ -0,0 +1,28 @@
+package order
+
+import "time"
+
+const (
+	ErasureSourceHTTP  = "http"
+	ErasureSourceKafka = "kafka"
+)
+
+// Erasure is the audit record of a customer data erasure. The customer id
+// itself is not kept, only its SHA-256 hash.
+type Erasure struct {
+	ID           int       `json:"id" db:"id"`
+	CustomerHash string    `json:"customer_hash" db:"customer_hash"`
+	Orders       int       `json:"orders" db:"orders"`
+	RequestedBy  string    `json:"requested_by" db:"requested_by"`
+	Source       string    `json:"source" db:"source"`
+	ErasedAt     time.Time `json:"erased_at" db:"erased_at"`
+	OrderUIDs    []string  `json:"order_uids" db:"-"`
+	// Versions holds the version every erased order got, for cache fencing.
+	Versions map[string]int `json:"-" db:"-"`
+}
+
+// ErasureRequest is the payload of the customer erasure topic.
+type ErasureRequest struct {
+	CustomerID  string `json:"customer_id" validate:"required"`
+	RequestedBy string `json:"requested_by"`
+}
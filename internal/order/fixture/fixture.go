@@ -0,0 +1,242 @@
+// Package fixture generates random orders that pass the consumer validation,
+// and broken messages that do not. Used by the load generator and tests.
+package fixture
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"time"
+
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order"
+)
+
+type Options struct {
+	// MinItems and MaxItems bound the number of items per order.
+	MinItems, MaxItems int
+	// Locales must be accepted by the order validation (en, ru).
+	Locales    []string
+	Currencies []string
+	// Seed makes the sequence reproducible, 0 picks a random one.
+	Seed uint64
+	// MaxAge spreads date_created over the period before Now.
+	MaxAge time.Duration
+	Now    func() time.Time
+	// Arbitrary fills free text fields with random unicode, including quotes,
+	// escapes and emoji, sets the optional fields and spreads numbers over
+	// the whole range the database columns accept. For round-trip tests.
+	Arbitrary bool
+}
+
+type Generator struct {
+	opts Options
+	rnd  *rand.Rand
+	seq  int
+}
+
+func New(opts Options) *Generator {
+	if opts.MinItems < 1 {
+		opts.MinItems = 1
+	}
+	if opts.MaxItems < opts.MinItems {
+		opts.MaxItems = opts.MinItems
+	}
+	if len(opts.Locales) == 0 {
+		opts.Locales = []string{"en", "ru"}
+	}
+	if len(opts.Currencies) == 0 {
+		opts.Currencies = []string{"USD", "RUB", "EUR"}
+	}
+	if opts.Seed == 0 {
+		opts.Seed = rand.Uint64()
+	}
+	if opts.MaxAge <= 0 {
+		opts.MaxAge = 30 * 24 * time.Hour
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	return &Generator{opts: opts, rnd: rand.New(rand.NewPCG(opts.Seed, opts.Seed))}
+}
+
+var (
+	names    = []string{"Test Testov", "Ivan Petrov", "Anna Smirnova", "John Smith", "Maria Garcia"}
+	cities   = []string{"Moscow", "Kazan", "Kiryat Mozkin", "Berlin", "Almaty"}
+	regions  = []string{"Central", "Volga", "Kraiot", "North", "South"}
+	services = []string{"meest", "cdek", "dhl", "boxberry"}
+	banks    = []string{"alpha", "sber", "tinkoff", "vtb"}
+	brands   = []string{"Vivienne Sabo", "Nike", "Adidas", "Xiaomi", "Apple", "Lego"}
+	products = []string{"Mascaras", "Sneakers", "T-shirt", "Phone case", "Headphones", "Constructor"}
+	sizes    = []string{"0", "S", "M", "L", "XL", "42"}
+)
+
+// Order returns a new valid order with a unique order_uid.
+func (g *Generator) Order() order.Order {
+	g.seq++
+	uid := fmt.Sprintf("%016x%04xtest", g.rnd.Uint64(), g.seq&0xffff)
+	track := g.word(fmt.Sprintf("WBIL%010d", g.rnd.IntN(1e10)))
+	created := g.opts.Now().Add(-time.Duration(g.rnd.Int64N(int64(g.opts.MaxAge)))).UTC().Truncate(time.Second)
+
+	o := order.Order{
+		OrderUID:        uid,
+		TrackNumber:     track,
+		Entry:           g.word("WBIL"),
+		Locale:          pick(g, g.opts.Locales),
+		CustomerID:      g.word(fmt.Sprintf("customer%d", g.rnd.IntN(1000))),
+		DeliveryService: g.word(pick(g, services)),
+		ShardKey:        fmt.Sprint(g.rnd.IntN(10)),
+		SMID:            1 + g.number(100),
+		DateCreated:     created,
+		OOFShard:        g.word(fmt.Sprint(1 + g.rnd.IntN(2))),
+		Delivery: order.Delivery{
+			Name:    g.word(pick(g, names)),
+			Phone:   fmt.Sprintf("+7%010d", g.rnd.IntN(1e10)),
+			Zip:     g.word(fmt.Sprintf("%06d", g.rnd.IntN(1e6))),
+			City:    g.word(pick(g, cities)),
+			Address: g.word(fmt.Sprintf("Ploshad Mira %d", 1+g.rnd.IntN(200))),
+			Region:  g.word(pick(g, regions)),
+			Email:   fmt.Sprintf("user%d@example.com", g.rnd.IntN(1e6)),
+		},
+	}
+	if g.opts.Arbitrary {
+		o.InternalSignature = g.optional()
+	}
+
+	n := g.opts.MinItems + g.rnd.IntN(g.opts.MaxItems-g.opts.MinItems+1)
+	goods := 0
+	for range n {
+		price := 100 + g.rnd.IntN(10000)
+		if g.opts.Arbitrary {
+			// сумма заказа должна поместиться в payments.amount INT
+			price = 1 + g.rnd.IntN(1e6)
+		}
+		sale := g.rnd.IntN(60)
+		if g.opts.Arbitrary {
+			sale = g.rnd.IntN(101)
+		}
+		total := price * (100 - sale) / 100
+		goods += total
+		o.Items = append(o.Items, order.Item{
+			ChrtID:      1 + g.id(),
+			TrackNumber: track,
+			Price:       price,
+			RID:         g.word(fmt.Sprintf("%016x", g.rnd.Uint64())),
+			Name:        g.word(pick(g, products)),
+			Sale:        sale,
+			Size:        g.word(pick(g, sizes)),
+			TotalPrice:  total,
+			NmID:        1 + g.id(),
+			Brand:       g.word(pick(g, brands)),
+			Status:      202,
+		})
+		if g.opts.Arbitrary {
+			o.Items[len(o.Items)-1].Status = 1 + g.rnd.IntN(math.MaxInt32-1)
+		}
+	}
+
+	deliveryCost := 100 * g.rnd.IntN(20)
+	if g.opts.Arbitrary {
+		// amount обязателен, а товары со скидкой 100% бесплатны
+		deliveryCost = 1 + g.rnd.IntN(1e4)
+	}
+	o.Payment = order.Payment{
+		Transaction:  uid,
+		Currency:     pick(g, g.opts.Currencies),
+		Provider:     g.word("wbpay"),
+		Amount:       goods + deliveryCost,
+		PaymentDT:    created.Unix(),
+		Bank:         g.word(pick(g, banks)),
+		DeliveryCost: deliveryCost,
+		GoodsTotal:   goods,
+	}
+	if g.opts.Arbitrary {
+		o.Payment.RequestID = g.optional()
+		o.Payment.CustomFee = g.rnd.IntN(1000)
+	}
+	return o
+}
+
+// Message returns a valid order encoded as a Kafka message value.
+func (g *Generator) Message() (order.Order, []byte) {
+	o := g.Order()
+	data, _ := json.Marshal(o)
+	return o, data
+}
+
+// invalid breaks a valid order in one of several ways the consumer rejects.
+var invalid = []struct {
+	reason string
+	apply  func(o *order.Order, data []byte) []byte
+}{
+	{"truncated json", func(_ *order.Order, data []byte) []byte { return data[:len(data)/2] }},
+	{"missing customer_id", func(o *order.Order, _ []byte) []byte { o.CustomerID = ""; return nil }},
+	{"unknown locale", func(o *order.Order, _ []byte) []byte { o.Locale = "de"; return nil }},
+	{"invalid phone", func(o *order.Order, _ []byte) []byte { o.Delivery.Phone = "not a phone"; return nil }},
+	{"sale over 100", func(o *order.Order, _ []byte) []byte { o.Items[0].Sale = 150; return nil }},
+	{"no items", func(o *order.Order, _ []byte) []byte { o.Items = nil; return nil }},
+}
+
+// InvalidMessage returns a message that fails decoding or validation, and
+// what is wrong with it.
+func (g *Generator) InvalidMessage() (order.Order, []byte, string) {
+	o, data := g.Message()
+	broken := invalid[g.rnd.IntN(len(invalid))]
+	if out := broken.apply(&o, data); out != nil {
+		return o, out, broken.reason
+	}
+	data, _ = json.Marshal(o)
+	return o, data, broken.reason
+}
+
+// Float returns a number in [0, 1) from the generator sequence.
+func (g *Generator) Float() float64 {
+	return g.rnd.Float64()
+}
+
+// textRunes are the characters of Arbitrary text: ASCII, JSON and HTML
+// special characters, cyrillic, CJK, an emoji and a combining accent.
+// NUL is left out, Postgres does not store it in TEXT.
+var textRunes = []rune("abcXYZ019 _-.,:;!?\"'\\/<>&%\t\nжЖёЁ中文🦆\u0301")
+
+// word returns typical unless the generator is Arbitrary, then a random
+// non-empty text.
+func (g *Generator) word(typical string) string {
+	if !g.opts.Arbitrary {
+		return typical
+	}
+	text := make([]rune, 1+g.rnd.IntN(32))
+	for i := range text {
+		text[i] = textRunes[g.rnd.IntN(len(textRunes))]
+	}
+	return string(text)
+}
+
+// optional returns an empty or an Arbitrary text.
+func (g *Generator) optional() string {
+	if g.rnd.IntN(2) == 0 {
+		return ""
+	}
+	return g.word("")
+}
+
+// number returns a value in [0, typical), or up to the INT column limit
+// when Arbitrary.
+func (g *Generator) number(typical int) int {
+	if g.opts.Arbitrary {
+		return g.rnd.IntN(math.MaxInt32)
+	}
+	return g.rnd.IntN(typical)
+}
+
+// id returns a value for a BIGINT id column.
+func (g *Generator) id() int {
+	if g.opts.Arbitrary {
+		return int(g.rnd.Int64N(1 << 53))
+	}
+	return g.rnd.IntN(1e7)
+}
+
+func pick(g *Generator, values []string) string {
+	return values[g.rnd.IntN(len(values))]
+}
@@ -0,0 +1,67 @@
+package fixture
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order/handler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerator_Message(t *testing.T) {
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	g := New(Options{MinItems: 2, MaxItems: 4, Currencies: []string{"KZT"}, Seed: 1, Now: func() time.Time { return now }})
+
+	seen := make(map[string]bool)
+	for range 200 {
+		_, data := g.Message()
+		o, err := handler.DecodeOrder(data)
+		require.NoError(t, err, string(data))
+
+		assert.False(t, seen[o.OrderUID], "duplicate order_uid %s", o.OrderUID)
+		seen[o.OrderUID] = true
+		assert.True(t, len(o.Items) >= 2 && len(o.Items) <= 4)
+		assert.Equal(t, "KZT", o.Payment.Currency)
+		assert.True(t, o.DateCreated.Before(now) && o.DateCreated.After(now.Add(-31*24*time.Hour)))
+
+		goods := 0
+		for _, it := range o.Items {
+			goods += it.TotalPrice
+		}
+		assert.Equal(t, goods, o.Payment.GoodsTotal)
+		assert.Equal(t, goods+o.Payment.DeliveryCost, o.Payment.Amount)
+	}
+}
+
+func TestGenerator_InvalidMessage(t *testing.T) {
+	g := New(Options{Seed: 2})
+	for range 100 {
+		_, data, reason := g.InvalidMessage()
+		_, err := handler.DecodeOrder(data)
+		assert.Error(t, err, reason)
+	}
+}
+
+func TestGenerator_Seed(t *testing.T) {
+	now := func() time.Time { return time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC) }
+	a, b := New(Options{Seed: 42, Now: now}), New(Options{Seed: 42, Now: now})
+	for range 10 {
+		assert.Equal(t, a.Order(), b.Order())
+	}
+}
+
+func TestGenerator_Arbitrary(t *testing.T) {
+	g := New(Options{MaxItems: 5, Seed: 3, Arbitrary: true})
+	for range 200 {
+		o, data := g.Message()
+		decoded, err := handler.DecodeOrder(data)
+		require.NoError(t, err, string(data))
+		assert.Equal(t, o, decoded)
+
+		assert.LessOrEqual(t, o.Payment.Amount, math.MaxInt32)
+		assert.LessOrEqual(t, o.SMID, math.MaxInt32)
+		assert.NotContains(t, string(data), `\u0000`)
+	}
+}
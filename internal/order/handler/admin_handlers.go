@@ -0,0 +1,166 @@
+package handler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Egor-Pomidor-pdf/order-service/internal/kafka"
+	"github.com/go-chi/chi/v5"
+)
+
+type Replayer interface {
+	Replay(ctx context.Context, req kafka.ReplayRequest) (*kafka.ReplayReport, error)
+}
+
+const (
+	replayRunning = "running"
+	replayDone    = "done"
+	replayFailed  = "failed"
+
+	// сколько завершенных заданий помнить для GET /admin/replay/{job_id}
+	maxFinishedReplayJobs = 100
+)
+
+type ReplayJob struct {
+	ID         string              `json:"id"`
+	Status     string              `json:"status"`
+	Request    kafka.ReplayRequest `json:"request"`
+	StartedAt  time.Time           `json:"started_at"`
+	FinishedAt *time.Time          `json:"finished_at,omitempty"`
+	Report     *kafka.ReplayReport `json:"report,omitempty"`
+	Error      string              `json:"error,omitempty"`
+}
+
+// AdminHandler serves operator endpoints. Replays can run for minutes, so
+// they are started in the background and polled by job id. Only the last
+// maxFinishedReplayJobs finished jobs are kept.
+type AdminHandler struct {
+	replayer Replayer
+
+	// ctx отменяется в Close, с ним завершаются запущенные replay
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu   sync.Mutex
+	jobs map[string]*ReplayJob
+}
+
+func NewAdminHandler(replayer Replayer) *AdminHandler {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &AdminHandler{
+		replayer: replayer,
+		ctx:      ctx,
+		cancel:   cancel,
+		jobs:     make(map[string]*ReplayJob),
+	}
+}
+
+// Close cancels the running replays, e.g. on server shutdown.
+func (h *AdminHandler) Close() {
+	h.cancel()
+}
+
+func (h *AdminHandler) StartReplayHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req kafka.ReplayRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request body"})
+		return
+	}
+	if len(req.Offsets) == 0 && req.Since.IsZero() {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": kafka.ErrEmptyReplayRange.Error()})
+		return
+	}
+
+	job := &ReplayJob{
+		ID:        newJobID(),
+		Status:    replayRunning,
+		Request:   req,
+		StartedAt: time.Now(),
+	}
+	h.mu.Lock()
+	h.pruneJobs()
+	h.jobs[job.ID] = job
+	snapshot := *job
+	h.mu.Unlock()
+
+	go h.runReplay(job.ID, req)
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(snapshot)
+}
+
+func (h *AdminHandler) GetReplayHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	h.mu.Lock()
+	job, ok := h.jobs[chi.URLParam(r, "job_id")]
+	var snapshot ReplayJob
+	if ok {
+		snapshot = *job
+	}
+	h.mu.Unlock()
+
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Replay job not found"})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(snapshot)
+}
+
+func (h *AdminHandler) runReplay(id string, req kafka.ReplayRequest) {
+	report, err := h.replayer.Replay(h.ctx, req)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	job := h.jobs[id]
+	finished := time.Now()
+	job.FinishedAt = &finished
+	job.Report = report
+	job.Status = replayDone
+	if err != nil {
+		slog.Error("replay failed", "job_id", id, "error", err)
+		job.Status = replayFailed
+		job.Error = err.Error()
+	}
+}
+
+// pruneJobs forgets the oldest finished jobs beyond maxFinishedReplayJobs.
+// Running jobs are always kept. It must be called with h.mu held.
+func (h *AdminHandler) pruneJobs() {
+	var finished []*ReplayJob
+	for _, job := range h.jobs {
+		if job.FinishedAt != nil {
+			finished = append(finished, job)
+		}
+	}
+	if len(finished) <= maxFinishedReplayJobs {
+		return
+	}
+	sort.Slice(finished, func(i, j int) bool {
+		return finished[i].FinishedAt.Before(*finished[j].FinishedAt)
+	})
+	for _, job := range finished[:len(finished)-maxFinishedReplayJobs] {
+		delete(h.jobs, job.ID)
+	}
+}
+
+func newJobID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Egor-Pomidor-pdf/order-service/internal/kafka"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingReplayer runs until ctx is done.
+type blockingReplayer struct{}
+
+func (blockingReplayer) Replay(ctx context.Context, _ kafka.ReplayRequest) (*kafka.ReplayReport, error) {
+	<-ctx.Done()
+	return &kafka.ReplayReport{}, ctx.Err()
+}
+
+func startReplay(t *testing.T, h *AdminHandler) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	h.StartReplayHandler(w, httptest.NewRequest(http.MethodPost, "/admin/replay", strings.NewReader(`{"offsets":{"0":1}}`)))
+	require.Equal(t, http.StatusAccepted, w.Code)
+}
+
+func (h *AdminHandler) jobStatuses() map[string]int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	statuses := make(map[string]int)
+	for _, job := range h.jobs {
+		statuses[job.Status]++
+	}
+	return statuses
+}
+
+func TestAdminHandler_CloseCancelsReplays(t *testing.T) {
+	h := NewAdminHandler(blockingReplayer{})
+	startReplay(t, h)
+
+	h.Close()
+	require.Eventually(t, func() bool { return h.jobStatuses()[replayFailed] == 1 }, time.Second, time.Millisecond)
+}
+
+func TestAdminHandler_PrunesFinishedJobs(t *testing.T) {
+	h := NewAdminHandler(blockingReplayer{})
+	h.Close()
+	for range maxFinishedReplayJobs + 5 {
+		startReplay(t, h)
+		require.Eventually(t, func() bool { return h.jobStatuses()[replayRunning] == 0 }, time.Second, time.Millisecond)
+	}
+
+	// новое задание вытесняет самые старые завершенные
+	startReplay(t, h)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	assert.Len(t, h.jobs, maxFinishedReplayJobs+1)
+}
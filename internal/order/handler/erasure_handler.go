@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/Egor-Pomidor-pdf/order-service/internal/auth"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order/service"
+)
+
+// ErasureHandler consumes customer erasure requests from Kafka.
+type ErasureHandler struct {
+	service service.OrderServiceInterface
+}
+
+func NewErasureHandler(service service.OrderServiceInterface) *ErasureHandler {
+	return &ErasureHandler{service: service}
+}
+
+func (h *ErasureHandler) HandleMessage(message []byte, offset int64) error {
+	var req order.ErasureRequest
+
+	if err := json.Unmarshal(message, &req); err != nil {
+		return fmt.Errorf("INVALID_JSON: %w", err)
+	}
+	if err := validate.Struct(req); err != nil {
+		return fmt.Errorf("VALIDATION_ERROR: %w", err)
+	}
+	if req.RequestedBy == "" {
+		req.RequestedBy = order.ErasureSourceKafka
+	}
+
+	slog.Info("processing erasure request from Kafka", "offset", offset)
+	if _, err := h.service.EraseCustomer(context.Background(), req.CustomerID, req.RequestedBy, order.ErasureSourceKafka); err != nil {
+		return fmt.Errorf("DATABASE_ERROR: %w", err)
+	}
+	return nil
+}
+
+// EraseCustomerHandler takes the customer id in the body: a path would put it
+// in the request and audit logs.
+func (h *OrderHandler) EraseCustomerHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		CustomerID string `json:"customer_id" validate:"required"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return
+	}
+	if err := validate.Struct(req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "customer_id is required"})
+		return
+	}
+
+	requestedBy := "unknown"
+	if p := auth.FromContext(r.Context()); p != nil {
+		requestedBy = p.Subject
+	}
+
+	erasure, err := h.service.EraseCustomer(r.Context(), req.CustomerID, requestedBy, order.ErasureSourceHTTP)
+	if err != nil {
+		slog.Error("failed to erase customer data", "error", err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, erasure)
+}
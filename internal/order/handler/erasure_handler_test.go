@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Egor-Pomidor-pdf/order-service/internal/auth"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order"
+	mock_service "github.com/Egor-Pomidor-pdf/order-service/internal/order/service/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErasureHandler_HandleMessage(t *testing.T) {
+	type mockBehavior func(s *mock_service.MockOrderServiceInterface)
+
+	tests := []struct {
+		name         string
+		message      string
+		mockBehavior mockBehavior
+		expectedErr  string
+	}{
+		{
+			name:    "OK",
+			message: `{"customer_id": "test", "requested_by": "dpo@example.com"}`,
+			mockBehavior: func(s *mock_service.MockOrderServiceInterface) {
+				s.EXPECT().EraseCustomer(gomock.Any(), "test", "dpo@example.com", order.ErasureSourceKafka).
+					Return(&order.Erasure{Orders: 2}, nil)
+			},
+		},
+		{
+			name:    "Default requester",
+			message: `{"customer_id": "test"}`,
+			mockBehavior: func(s *mock_service.MockOrderServiceInterface) {
+				s.EXPECT().EraseCustomer(gomock.Any(), "test", order.ErasureSourceKafka, order.ErasureSourceKafka).
+					Return(&order.Erasure{}, nil)
+			},
+		},
+		{
+			name:         "Missing customer",
+			message:      `{"requested_by": "dpo@example.com"}`,
+			mockBehavior: func(s *mock_service.MockOrderServiceInterface) {},
+			expectedErr:  "VALIDATION_ERROR:",
+		},
+		{
+			name:    "Database error",
+			message: `{"customer_id": "test"}`,
+			mockBehavior: func(s *mock_service.MockOrderServiceInterface) {
+				s.EXPECT().EraseCustomer(gomock.Any(), "test", gomock.Any(), gomock.Any()).
+					Return(nil, errors.New("connection refused"))
+			},
+			expectedErr: "DATABASE_ERROR:",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			mockOrderService := mock_service.NewMockOrderServiceInterface(c)
+			test.mockBehavior(mockOrderService)
+			handler := NewErasureHandler(mockOrderService)
+
+			err := handler.HandleMessage([]byte(test.message), 1)
+			if test.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, test.expectedErr)
+			}
+		})
+	}
+}
+
+func TestHandler_EraseCustomerHandler(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	mockOrderService := mock_service.NewMockOrderServiceInterface(c)
+	mockOrderService.EXPECT().EraseCustomer(gomock.Any(), "test", "ops", order.ErasureSourceHTTP).
+		Return(&order.Erasure{CustomerHash: "abc", Orders: 1, RequestedBy: "ops", Source: order.ErasureSourceHTTP}, nil)
+	handler := &OrderHandler{service: mockOrderService}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/admin/customers/erase", strings.NewReader(`{"customer_id": "test"}`))
+	ctx := auth.WithPrincipal(r.Context(), &auth.Principal{Subject: "ops", Roles: []string{auth.RoleAdmin}})
+	handler.EraseCustomerHandler(w, r.WithContext(ctx))
+
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), `"orders":1`)
+
+	// без customer_id сервис не вызывается
+	for _, body := range []string{`{}`, `{"customer_id": ""}`, `not json`} {
+		w = httptest.NewRecorder()
+		handler.EraseCustomerHandler(w, httptest.NewRequest("POST", "/admin/customers/erase", strings.NewReader(body)))
+		assert.Equal(t, 400, w.Code, body)
+	}
+}
@@ -1,6 +1,9 @@
 package handler
 
-import "github.com/Egor-Pomidor-pdf/order-service/internal/order/service"
+import (
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order/service"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/redact"
+)
 
 // type OrderHTTPHandler struct {
 //     service *service.OrderService
@@ -11,9 +14,16 @@ import "github.com/Egor-Pomidor-pdf/order-service/internal/order/service"
 // }
 
 type OrderHandler struct {
-	service service.OrderServiceInterface
+	service   service.OrderServiceInterface
+	redaction *redact.Policy
 }
 
 func NewOrderHandler(service service.OrderServiceInterface) *OrderHandler {
 	return &OrderHandler{service: service}
+}
+
+// WithRedaction masks PII in HTTP responses according to policy.
+func (h *OrderHandler) WithRedaction(policy *redact.Policy) *OrderHandler {
+	h.redaction = policy
+	return h
 }
\ No newline at end of file
@@ -2,9 +2,16 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
+	"strconv"
+	"strings"
 
+	"github.com/Egor-Pomidor-pdf/order-service/internal/auth"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order/repository"
 	"github.com/go-chi/chi/v5"
 )
 
@@ -27,7 +34,138 @@ func (h * OrderHandler) GetOrderHandler(w http.ResponseWriter, r *http.Request)
 		return	
 	}
 	
+	w.Header().Set("ETag", etag(order.Version))
 	w.WriteHeader(http.StatusOK)
-    json.NewEncoder(w).Encode(order) 
+    json.NewEncoder(w).Encode(h.redaction.Order(auth.FromContext(r.Context()), order)) 
 
 }
+
+func (h *OrderHandler) CreateOrderHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return
+	}
+	order, err := DecodeOrder(body)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	created, err := h.service.ProcessOrder(r.Context(), order)
+	if err != nil {
+		if errors.Is(err, repository.ErrOrderExists) {
+			writeJSON(w, http.StatusConflict, map[string]string{"error": "Order already exists"})
+			return
+		}
+		slog.Error("failed to create order", "error", err, "order_uid", order.OrderUID)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	w.Header().Set("ETag", etag(created.Version))
+	writeJSON(w, http.StatusCreated, h.redaction.Order(auth.FromContext(r.Context()), created))
+}
+
+func (h *OrderHandler) UpdateOrderHandler(w http.ResponseWriter, r *http.Request) {
+	order_uid := chi.URLParam(r, "order_uid")
+
+	version, err := ifMatchVersion(r)
+	if err != nil {
+		writeVersionError(w, err)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return
+	}
+	order, err := DecodeOrder(body)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if order.OrderUID != order_uid {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "order_uid does not match URL"})
+		return
+	}
+
+	updated, err := h.service.UpdateOrder(r.Context(), order, version)
+	if err != nil {
+		if !writeVersionError(w, err) {
+			slog.Error("failed to update order", "error", err, "order_uid", order_uid)
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		}
+		return
+	}
+
+	w.Header().Set("ETag", etag(updated.Version))
+	writeJSON(w, http.StatusOK, h.redaction.Order(auth.FromContext(r.Context()), updated))
+}
+
+func (h *OrderHandler) DeleteOrderHandler(w http.ResponseWriter, r *http.Request) {
+	order_uid := chi.URLParam(r, "order_uid")
+
+	version, err := ifMatchVersion(r)
+	if err != nil {
+		writeVersionError(w, err)
+		return
+	}
+
+	if err := h.service.DeleteOrder(r.Context(), order_uid, version); err != nil {
+		if !writeVersionError(w, err) {
+			slog.Error("failed to delete order", "error", err, "order_uid", order_uid)
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+var errIfMatchRequired = errors.New("If-Match header is required")
+
+// ifMatchVersion extracts the expected order version from If-Match.
+// "*" matches any version and is returned as 0.
+func ifMatchVersion(r *http.Request) (int, error) {
+	value := strings.TrimSpace(r.Header.Get("If-Match"))
+	if value == "" {
+		return 0, errIfMatchRequired
+	}
+	if value == "*" {
+		return 0, nil
+	}
+
+	version, err := strconv.Atoi(strings.Trim(strings.TrimPrefix(value, "W/"), `"`))
+	if err != nil || version < 1 {
+		return 0, repository.ErrVersionConflict
+	}
+	return version, nil
+}
+
+// writeVersionError answers errors of versioned writes and reports whether
+// err was one of them.
+func writeVersionError(w http.ResponseWriter, err error) bool {
+	switch {
+	case errors.Is(err, errIfMatchRequired):
+		writeJSON(w, http.StatusPreconditionRequired, map[string]string{"error": err.Error()})
+	case errors.Is(err, repository.ErrVersionConflict):
+		writeJSON(w, http.StatusPreconditionFailed, map[string]string{"error": "Order was modified, fetch it again"})
+	case errors.Is(err, repository.ErrOrderNotFound):
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "Order not found"})
+	default:
+		return false
+	}
+	return true
+}
+
+func etag(version int) string {
+	return fmt.Sprintf("%q", strconv.Itoa(version))
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -3,12 +3,15 @@ package handler
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log/slog"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/Egor-Pomidor-pdf/order-service/internal/order"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order/repository"
 	mock_service "github.com/Egor-Pomidor-pdf/order-service/internal/order/service/mocks"
 	"github.com/go-chi/chi/v5"
 	"github.com/golang/mock/gomock"
@@ -186,4 +189,211 @@ type mockBehavior func(s *mock_service.MockOrderServiceInterface, order_uid stri
 
 		})
 	}
-}
\ No newline at end of file
+}
+const validOrderJSON = `{
+   "order_uid": "b563feb7b2b84b6test10",
+   "track_number": "WBILMTESTTRACK",
+   "entry": "WBIL",
+   "delivery": {"name": "Test Testov", "phone": "+9720000000", "zip": "2639809", "city": "Kiryat Mozkin",
+      "address": "Ploshad Mira 15", "region": "Kraiot", "email": "test@gmail.com"},
+   "payment": {"transaction": "b563feb7b2b84b6test", "request_id": "", "currency": "USD", "provider": "wbpay",
+      "amount": 1817, "payment_dt": 1637907727, "bank": "alpha", "delivery_cost": 1500, "goods_total": 317, "custom_fee": 0},
+   "items": [{"chrt_id": 9934930, "track_number": "WBILMTESTTRACK", "price": 453, "rid": "ab4219087a764ae0btest",
+      "name": "Mascaras", "sale": 30, "size": "0", "total_price": 317, "nm_id": 2389212, "brand": "Vivienne Sabo", "status": 202}],
+   "locale": "en",
+   "internal_signature": "",
+   "customer_id": "test",
+   "delivery_service": "meest",
+   "shardkey": "9",
+   "sm_id": 99,
+   "date_created": "2021-11-26T06:22:19Z",
+   "oof_shard": "1"
+}`
+
+func TestHandler_CreateOrderHandler(t *testing.T) {
+	type mockBehavior func(s *mock_service.MockOrderServiceInterface)
+
+	tests := []struct {
+		name               string
+		body               string
+		mockBehavior       mockBehavior
+		expectedStatusCode int
+		expectedETag       string
+	}{
+		{
+			name: "Created",
+			body: validOrderJSON,
+			mockBehavior: func(s *mock_service.MockOrderServiceInterface) {
+				s.EXPECT().ProcessOrder(gomock.Any(), gomock.Any()).
+					Return(&order.Order{OrderUID: "b563feb7b2b84b6test10", Version: 1}, nil)
+			},
+			expectedStatusCode: 201,
+			expectedETag:       `"1"`,
+		},
+		{
+			name:               "Invalid",
+			body:               `{"order_uid": "b563feb7b2b84b6test10"}`,
+			mockBehavior:       func(s *mock_service.MockOrderServiceInterface) {},
+			expectedStatusCode: 400,
+		},
+		{
+			name: "Duplicate",
+			body: validOrderJSON,
+			mockBehavior: func(s *mock_service.MockOrderServiceInterface) {
+				s.EXPECT().ProcessOrder(gomock.Any(), gomock.Any()).
+					Return(nil, fmt.Errorf("repository.order.SaveOrder: %w", repository.ErrOrderExists))
+			},
+			expectedStatusCode: 409,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			mockOrderService := mock_service.NewMockOrderServiceInterface(c)
+			test.mockBehavior(mockOrderService)
+			handler := &OrderHandler{service: mockOrderService}
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("POST", "/orders", strings.NewReader(test.body))
+			handler.CreateOrderHandler(w, r)
+
+			assert.Equal(t, test.expectedStatusCode, w.Code)
+			assert.Equal(t, test.expectedETag, w.Header().Get("ETag"))
+		})
+	}
+}
+
+func TestHandler_UpdateOrderHandler(t *testing.T) {
+	type mockBehavior func(s *mock_service.MockOrderServiceInterface)
+
+	tests := []struct {
+		name               string
+		order_uid          string
+		ifMatch            string
+		mockBehavior       mockBehavior
+		expectedStatusCode int
+		expectedETag       string
+	}{
+		{
+			name:      "OK",
+			order_uid: "b563feb7b2b84b6test10",
+			ifMatch:   `"2"`,
+			mockBehavior: func(s *mock_service.MockOrderServiceInterface) {
+				s.EXPECT().UpdateOrder(gomock.Any(), gomock.Any(), 2).
+					Return(&order.Order{OrderUID: "b563feb7b2b84b6test10", Version: 3}, nil)
+			},
+			expectedStatusCode: 200,
+			expectedETag:       `"3"`,
+		},
+		{
+			name:               "Missing If-Match",
+			order_uid:          "b563feb7b2b84b6test10",
+			mockBehavior:       func(s *mock_service.MockOrderServiceInterface) {},
+			expectedStatusCode: 428,
+		},
+		{
+			name:      "Stale version",
+			order_uid: "b563feb7b2b84b6test10",
+			ifMatch:   `"1"`,
+			mockBehavior: func(s *mock_service.MockOrderServiceInterface) {
+				s.EXPECT().UpdateOrder(gomock.Any(), gomock.Any(), 1).
+					Return(nil, fmt.Errorf("repository.order.UpdateOrder: %w", repository.ErrVersionConflict))
+			},
+			expectedStatusCode: 412,
+		},
+		{
+			name:               "UID mismatch",
+			order_uid:          "another_order",
+			ifMatch:            "*",
+			mockBehavior:       func(s *mock_service.MockOrderServiceInterface) {},
+			expectedStatusCode: 400,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			mockOrderService := mock_service.NewMockOrderServiceInterface(c)
+			test.mockBehavior(mockOrderService)
+			handler := &OrderHandler{service: mockOrderService}
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("PUT", "/orders/"+test.order_uid, strings.NewReader(validOrderJSON))
+			if test.ifMatch != "" {
+				r.Header.Set("If-Match", test.ifMatch)
+			}
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("order_uid", test.order_uid)
+			r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+			handler.UpdateOrderHandler(w, r)
+
+			assert.Equal(t, test.expectedStatusCode, w.Code)
+			assert.Equal(t, test.expectedETag, w.Header().Get("ETag"))
+		})
+	}
+}
+
+func TestHandler_DeleteOrderHandler(t *testing.T) {
+	type mockBehavior func(s *mock_service.MockOrderServiceInterface, order_uid string)
+
+	tests := []struct {
+		name               string
+		order_uid          string
+		ifMatch            string
+		mockBehavior       mockBehavior
+		expectedStatusCode int
+	}{
+		{
+			name:      "OK",
+			order_uid: "b563feb7b2b84b6test10",
+			ifMatch:   `"1"`,
+			mockBehavior: func(s *mock_service.MockOrderServiceInterface, order_uid string) {
+				s.EXPECT().DeleteOrder(gomock.Any(), order_uid, 1).Return(nil)
+			},
+			expectedStatusCode: 204,
+		},
+		{
+			name:      "NotFound",
+			order_uid: "nonexistent_order",
+			ifMatch:   "*",
+			mockBehavior: func(s *mock_service.MockOrderServiceInterface, order_uid string) {
+				s.EXPECT().DeleteOrder(gomock.Any(), order_uid, 0).
+					Return(fmt.Errorf("repository.order.DeleteOrder: %w", repository.ErrOrderNotFound))
+			},
+			expectedStatusCode: 404,
+		},
+		{
+			name:               "Malformed If-Match",
+			order_uid:          "b563feb7b2b84b6test10",
+			ifMatch:            `"abc"`,
+			mockBehavior:       func(s *mock_service.MockOrderServiceInterface, order_uid string) {},
+			expectedStatusCode: 412,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			mockOrderService := mock_service.NewMockOrderServiceInterface(c)
+			test.mockBehavior(mockOrderService, test.order_uid)
+			handler := &OrderHandler{service: mockOrderService}
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("DELETE", "/orders/"+test.order_uid, nil)
+			r.Header.Set("If-Match", test.ifMatch)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("order_uid", test.order_uid)
+			r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+			handler.DeleteOrderHandler(w, r)
+
+			assert.Equal(t, test.expectedStatusCode, w.Code)
+		})
+	}
+}
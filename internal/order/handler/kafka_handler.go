@@ -3,11 +3,13 @@ package handler
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 
+	"github.com/Egor-Pomidor-pdf/order-service/internal/kafka"
 	"github.com/Egor-Pomidor-pdf/order-service/internal/order"
-	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order/repository"
 	"github.com/go-playground/validator/v10"
 )
 
@@ -16,19 +18,57 @@ import (
 
 var validate = validator.New()
 
-func (h *OrderHandler) HandleMessage(message []byte, offset kafka.Offset) error {
+func (h *OrderHandler) HandleMessage(message []byte, offset int64) error {
+	order, err := DecodeOrder(message)
+	if err != nil {
+		return err
+	}
+
+	slog.Info("processing order from Kafka", "uid", order.OrderUID, "offset", offset)
+	if _, err := h.service.ProcessOrder(context.Background(), order); err != nil {
+		return storeError(err)
+	}
+	return nil
+}
+
+// storeError marks an already stored order with kafka.ErrDuplicate, so the
+// consumer commits it instead of retrying.
+func storeError(err error) error {
+	if errors.Is(err, repository.ErrOrderExists) {
+		return fmt.Errorf("%w: %w", kafka.ErrDuplicate, err)
+	}
+	return fmt.Errorf("DATABASE_ERROR: %w", err)
+}
+
+// CheckMessage runs the HandleMessage validation and reports an already
+// stored order as a duplicate, without writing anything, the cache included.
+// Used by dry-run replay.
+func (h *OrderHandler) CheckMessage(message []byte) error {
+	order, err := DecodeOrder(message)
+	if err != nil {
+		return err
+	}
+
+	exists, err := h.service.OrderExists(context.Background(), order.OrderUID)
+	if err != nil {
+		return fmt.Errorf("DATABASE_ERROR: %w", err)
+	}
+	if exists {
+		return storeError(repository.ErrOrderExists)
+	}
+	return nil
+}
+
+// DecodeOrder parses and validates an order the way HandleMessage does.
+// Errors are prefixed with INVALID_JSON or VALIDATION_ERROR.
+func DecodeOrder(message []byte) (order.Order, error) {
 	var order order.Order
 
 	if err := json.Unmarshal(message, &order); err != nil {
-        return fmt.Errorf("INVALID_JSON: %w", err)
-    }
+		return order, fmt.Errorf("INVALID_JSON: %w", err)
+	}
 	if err := validate.Struct(order); err != nil {
-		return fmt.Errorf("VALIDATION_ERROR: %w", err)
+		return order, fmt.Errorf("VALIDATION_ERROR: %w", err)
 	}
-
-	slog.Info("processing order from Kafka", "uid", order.OrderUID, "offset", offset)
-	if err := h.service.ProcessOrder(context.Background(), order); err != nil {
-        return fmt.Errorf("DATABASE_ERROR: %w", err) 
-    }
-	return nil
+	return order, nil
 }
@@ -0,0 +1,166 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/Egor-Pomidor-pdf/order-service/internal/kafka"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order/fixture"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order/service"
+	mock_service "github.com/Egor-Pomidor-pdf/order-service/internal/order/service/mocks"
+	"github.com/go-chi/chi/v5"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Без -fuzz цели прогоняются только на начальном корпусе:
+//
+//	go test ./internal/order/handler -run '^$' -fuzz FuzzHandleMessage -fuzztime 1m
+
+// addMessages seeds f with valid and broken fixture messages.
+func addMessages(f *testing.F) {
+	gen := fixture.New(fixture.Options{Seed: 1, MaxItems: 3})
+	for range 5 {
+		_, data := gen.Message()
+		f.Add(data)
+		_, data, _ = gen.InvalidMessage()
+		f.Add(data)
+	}
+	_, data := fixture.New(fixture.Options{Seed: 2, Arbitrary: true}).Message()
+	f.Add(data)
+	f.Add([]byte(`{}`))
+	f.Add([]byte(`null`))
+	f.Add([]byte(`{"items": [null]}`))
+	f.Add([]byte(`{"order_uid": 1}`))
+}
+
+func FuzzDecodeOrder(f *testing.F) {
+	addMessages(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		o, err := DecodeOrder(data)
+		if err != nil {
+			assert.True(t, kafka.IsPermanent(err), "a broken message must be skipped, not retried: %v", err)
+			return
+		}
+		require.NoError(t, validate.Struct(o))
+
+		// принятый заказ не меняется при повторном кодировании
+		again, err := json.Marshal(o)
+		require.NoError(t, err)
+		decoded, err := DecodeOrder(again)
+		require.NoError(t, err)
+		assert.Equal(t, o, decoded)
+	})
+}
+
+func FuzzHandleMessage(f *testing.F) {
+	addMessages(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		svc := mock_service.NewMockOrderServiceInterface(gomock.NewController(t))
+		var processed []order.Order
+		svc.EXPECT().ProcessOrder(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, o order.Order) (*order.Order, error) {
+			processed = append(processed, o)
+			return &o, nil
+		}).AnyTimes()
+
+		err := NewOrderHandler(svc).HandleMessage(data, 1)
+		want, decodeErr := DecodeOrder(data)
+		if decodeErr != nil {
+			assert.Equal(t, decodeErr, err)
+			assert.True(t, kafka.IsPermanent(err))
+			assert.Empty(t, processed, "invalid orders never reach the service")
+			return
+		}
+		require.NoError(t, err)
+		assert.Equal(t, []order.Order{want}, processed)
+	})
+}
+
+var e164 = regexp.MustCompile(`^\+[1-9]?[0-9]{7,14}$`)
+
+// FuzzValidation checks the validation rules against a reference
+// implementation on a valid order with some fields replaced.
+func FuzzValidation(f *testing.F) {
+	f.Add("en", "+79990000000", "customer1", 0, 1, uint8(1))
+	f.Add("ru", "+12345678", "c", 100, 99, uint8(4))
+	f.Add("de", "+79990000000", "customer1", 0, 1, uint8(1))
+	f.Add("en", "79990000000", "customer1", 0, 1, uint8(1))
+	f.Add("en", "+79990000000", "", 0, 1, uint8(1))
+	f.Add("en", "+79990000000", "customer1", 101, 1, uint8(1))
+	f.Add("en", "+79990000000", "customer1", -1, 0, uint8(1))
+	f.Add("en", "+79990000000", "customer1", 0, 1, uint8(0))
+
+	base := fixture.New(fixture.Options{Seed: 3, MinItems: 4, MaxItems: 4}).Order()
+	f.Fuzz(func(t *testing.T, locale, phone, customerID string, sale, smID int, items uint8) {
+		o := base
+		o.Locale, o.Delivery.Phone, o.CustomerID, o.SMID = locale, phone, customerID, smID
+		o.Items = append([]order.Item{}, base.Items[:items%5]...)
+		if len(o.Items) > 0 {
+			o.Items[0].Sale = sale
+		}
+		data, err := json.Marshal(o)
+		require.NoError(t, err)
+		// невалидный UTF-8 заменяется при кодировании, проверяем то, что придет из Kafka
+		var sent order.Order
+		require.NoError(t, json.Unmarshal(data, &sent))
+
+		valid := (sent.Locale == "en" || sent.Locale == "ru") &&
+			e164.MatchString(sent.Delivery.Phone) &&
+			sent.CustomerID != "" &&
+			sent.SMID >= 1 &&
+			// required у среза проверяет только наличие поля, "items": [] допустим
+			sent.Items != nil &&
+			(len(sent.Items) == 0 || sent.Items[0].Sale >= 0 && sent.Items[0].Sale <= 100)
+
+		_, err = DecodeOrder(data)
+		assert.Equal(t, valid, err == nil, "error: %v", err)
+	})
+}
+
+// FuzzOrderRoundTrip sends a random valid order through the whole read and
+// write path: Kafka message, service, repository, GET /order/{uid} on
+// another replica with a cold cache. The response must be the same order.
+func FuzzOrderRoundTrip(f *testing.F) {
+	for seed := range uint64(10) {
+		f.Add(seed, uint8(seed))
+	}
+	f.Fuzz(func(t *testing.T, seed uint64, items uint8) {
+		// Seed 0 выбирает случайную последовательность
+		gen := fixture.New(fixture.Options{Seed: max(seed, 1), MaxItems: 1 + int(items%20), Arbitrary: true})
+		want, data := gen.Message()
+
+		repo := mock_service.NewMockRepository(gomock.NewController(t))
+		stored := make(map[string][]byte)
+		repo.EXPECT().SaveOrder(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, o *order.Order) error {
+			o.Version = 1
+			row, err := json.Marshal(o)
+			stored[o.OrderUID] = row
+			return err
+		})
+		repo.EXPECT().GetOrderByUID(gomock.Any(), want.OrderUID).DoAndReturn(func(_ context.Context, uid string) (*order.Order, error) {
+			var o order.Order
+			if err := json.Unmarshal(stored[uid], &o); err != nil {
+				return nil, err
+			}
+			return &o, nil
+		})
+
+		require.NoError(t, NewOrderHandler(service.NewOrderService(repo)).HandleMessage(data, 1))
+
+		r := chi.NewRouter()
+		r.Get("/order/{order_uid}", NewOrderHandler(service.NewOrderService(repo)).GetOrderHandler)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/order/"+want.OrderUID, nil))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var got order.Order
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+		assert.Equal(t, want, got)
+	})
+}
@@ -2,11 +2,15 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"testing"
 
+	"github.com/Egor-Pomidor-pdf/order-service/internal/kafka"
 	"github.com/Egor-Pomidor-pdf/order-service/internal/order"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order/fixture"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order/repository"
 	mock_service "github.com/Egor-Pomidor-pdf/order-service/internal/order/service/mocks"
-	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
 )
@@ -73,7 +77,7 @@ type mockBehavior func(s *mock_service.MockOrderServiceInterface, order order.Or
 }`),
 			offset:  1,
 			mockBehavior: func(s *mock_service.MockOrderServiceInterface, order order.Order) {
-				s.EXPECT().ProcessOrder(gomock.Any(), order).Return(nil)
+				s.EXPECT().ProcessOrder(gomock.Any(), order).Return(&order, nil)
 			},
 			expectedErr: nil,
 
@@ -102,7 +106,7 @@ type mockBehavior func(s *mock_service.MockOrderServiceInterface, order order.Or
 				service: mockOrderService,
 			}
 
-			errKafka := handler.HandleMessage(test.message, kafka.Offset(test.offset))
+			errKafka := handler.HandleMessage(test.message, test.offset)
 			assert.Equal(t, test.expectedErr, errKafka)
 
 			
@@ -110,3 +114,41 @@ type mockBehavior func(s *mock_service.MockOrderServiceInterface, order order.Or
 	}
 }
 
+func TestHandler_CheckMessage(t *testing.T) {
+	message := []byte(`{"order_uid":"b563feb7b2b84b6test","track_number":"WBILMTESTTRACK","entry":"WBIL",
+		"delivery":{"name":"Test Testov","phone":"+9720000000","zip":"2639809","city":"Kiryat Mozkin","address":"Ploshad Mira 15","region":"Kraiot","email":"test@gmail.com"},
+		"payment":{"transaction":"b563feb7b2b84b6test","currency":"USD","provider":"wbpay","amount":1817,"payment_dt":1637907727,"bank":"alpha","delivery_cost":1500,"goods_total":317},
+		"items":[{"chrt_id":9934930,"track_number":"WBILMTESTTRACK","price":453,"rid":"ab4219087a764ae0btest","name":"Mascaras","sale":30,"size":"0","total_price":317,"nm_id":2389212,"brand":"Vivienne Sabo","status":202}],
+		"locale":"en","customer_id":"test","delivery_service":"meest","shardkey":"9","sm_id":99,"date_created":"2021-11-26T06:22:19Z","oof_shard":"1"}`)
+
+	// GetOrder не вызывается: dry-run не должен наполнять кэш
+	c := gomock.NewController(t)
+	svc := mock_service.NewMockOrderServiceInterface(c)
+	svc.EXPECT().OrderExists(gomock.Any(), "b563feb7b2b84b6test").Return(false, nil)
+	svc.EXPECT().OrderExists(gomock.Any(), "b563feb7b2b84b6test").Return(true, nil)
+	h := NewOrderHandler(svc)
+
+	assert.NoError(t, h.CheckMessage(message))
+	err := h.CheckMessage(message)
+	assert.ErrorIs(t, err, repository.ErrOrderExists)
+	assert.True(t, kafka.IsDuplicate(err))
+}
+
+func TestHandler_HandleMessageDuplicate(t *testing.T) {
+	c := gomock.NewController(t)
+	svc := mock_service.NewMockOrderServiceInterface(c)
+	o := fixture.New(fixture.Options{Seed: 1}).Order()
+	message, _ := json.Marshal(o)
+	svc.EXPECT().ProcessOrder(gomock.Any(), gomock.Any()).
+		Return(nil, fmt.Errorf("repository.order.SaveOrder: %w", repository.ErrOrderExists))
+	svc.EXPECT().ProcessOrder(gomock.Any(), gomock.Any()).Return(nil, errors.New("connection refused"))
+	h := NewOrderHandler(svc)
+
+	err := h.HandleMessage(message, 1)
+	assert.True(t, kafka.IsDuplicate(err))
+	assert.False(t, kafka.IsPermanent(err))
+
+	err = h.HandleMessage(message, 2)
+	assert.False(t, kafka.IsDuplicate(err))
+	assert.False(t, kafka.IsPermanent(err))
+}
@@ -0,0 +1,164 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Egor-Pomidor-pdf/order-service/internal/config"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order/watch"
+)
+
+const (
+	defaultHeartbeat = 15 * time.Second
+	// streamWriteTimeout заменяет WriteTimeout сервера: событие, которое не
+	// удалось записать за это время, означает медленного клиента
+	streamWriteTimeout = 10 * time.Second
+)
+
+// Watcher subscribes to ingested orders, implemented by
+// service.OrderService.
+type Watcher interface {
+	Watch(buffer int, match func(*order.Order) bool) *watch.Subscription
+	WatchAfter(seq uint64, buffer int, match func(*order.Order) bool) (*watch.Subscription, bool)
+}
+
+// OrderSummary is the data of an order event, enough for a dashboard row.
+// The full order is served by GET /order/{order_uid}.
+type OrderSummary struct {
+	OrderUID        string     `json:"order_uid"`
+	TrackNumber     string     `json:"track_number"`
+	CustomerID      string     `json:"customer_id"`
+	DeliveryService string     `json:"delivery_service"`
+	Amount          int        `json:"amount"`
+	Currency        string     `json:"currency"`
+	Items           int        `json:"items"`
+	DateCreated     time.Time  `json:"date_created"`
+	IngestedAt      *time.Time `json:"ingested_at,omitempty"`
+}
+
+func summary(o *order.Order) OrderSummary {
+	return OrderSummary{
+		OrderUID:        o.OrderUID,
+		TrackNumber:     o.TrackNumber,
+		CustomerID:      o.CustomerID,
+		DeliveryService: o.DeliveryService,
+		Amount:          o.Payment.Amount,
+		Currency:        o.Payment.Currency,
+		Items:           len(o.Items),
+		DateCreated:     o.DateCreated,
+		IngestedAt:      o.IngestedAt,
+	}
+}
+
+// StreamHandler pushes ingested orders to clients as Server-Sent Events.
+type StreamHandler struct {
+	watcher   Watcher
+	buffer    int
+	heartbeat time.Duration
+}
+
+func NewStreamHandler(watcher Watcher, cfg config.StreamConfig) *StreamHandler {
+	h := &StreamHandler{watcher: watcher, buffer: cfg.Buffer, heartbeat: cfg.Heartbeat}
+	if h.heartbeat <= 0 {
+		h.heartbeat = defaultHeartbeat
+	}
+	return h
+}
+
+// StreamOrdersHandler sends an "order" event with the ingest sequence number
+// as its id for every order saved from now on, filtered by the customer_id
+// and delivery_service query parameters. A client sending Last-Event-ID
+// first gets the orders it missed; if they are no longer kept it gets a
+// "reset" event instead. A client that does not keep up is disconnected
+// and resumes the same way.
+func (h *StreamHandler) StreamOrdersHandler(w http.ResponseWriter, r *http.Request) {
+	customerID := r.URL.Query().Get("customer_id")
+	deliveryService := r.URL.Query().Get("delivery_service")
+	var match func(*order.Order) bool
+	if customerID != "" || deliveryService != "" {
+		match = func(o *order.Order) bool {
+			return (customerID == "" || o.CustomerID == customerID) &&
+				(deliveryService == "" || o.DeliveryService == deliveryService)
+		}
+	}
+
+	sub, resumed := h.subscribe(r.Header.Get("Last-Event-ID"), match)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// nginx не должен копить ответ в буфере
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	out := &eventWriter{w: w, rc: http.NewResponseController(w)}
+	first := ": connected\n\n"
+	if !resumed {
+		first = "event: reset\ndata: {}\n\n"
+	}
+	if err := out.write(first); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(h.heartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if err := out.write(": ping\n\n"); err != nil {
+				return
+			}
+		case ev, ok := <-sub.Events():
+			if !ok {
+				if errors.Is(sub.Err(), watch.ErrSlowSubscriber) {
+					slog.Warn("slow order stream client disconnected", "remote_addr", r.RemoteAddr)
+				}
+				return
+			}
+			data, err := json.Marshal(summary(&ev.Order))
+			if err != nil {
+				slog.Error("failed to encode order event", "error", err, "order_uid", ev.Order.OrderUID)
+				continue
+			}
+			if err := out.write(fmt.Sprintf("id: %d\nevent: order\ndata: %s\n\n", ev.Seq, data)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// subscribe resumes after lastEventID when possible. An empty id is a new
+// client, not a missed history.
+func (h *StreamHandler) subscribe(lastEventID string, match func(*order.Order) bool) (*watch.Subscription, bool) {
+	if lastEventID == "" {
+		return h.watcher.Watch(h.buffer, match), true
+	}
+	seq, err := strconv.ParseUint(lastEventID, 10, 64)
+	if err != nil {
+		return h.watcher.Watch(h.buffer, match), false
+	}
+	return h.watcher.WatchAfter(seq, h.buffer, match)
+}
+
+type eventWriter struct {
+	w  http.ResponseWriter
+	rc *http.ResponseController
+}
+
+// write sends s right away. An error means the client is gone or too slow.
+func (e *eventWriter) write(s string) error {
+	// не все ResponseWriter поддерживают дедлайны, например httptest.ResponseRecorder
+	_ = e.rc.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+	if _, err := fmt.Fprint(e.w, s); err != nil {
+		return err
+	}
+	return e.rc.Flush()
+}
@@ -0,0 +1,220 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Egor-Pomidor-pdf/order-service/internal/config"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order/watch"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type hubWatcher struct {
+	*watch.Hub
+}
+
+func (w hubWatcher) Watch(buffer int, match func(*order.Order) bool) *watch.Subscription {
+	return w.Subscribe(buffer, match)
+}
+
+func (w hubWatcher) WatchAfter(seq uint64, buffer int, match func(*order.Order) bool) (*watch.Subscription, bool) {
+	return w.SubscribeAfter(seq, buffer, match)
+}
+
+type sseEvent struct {
+	id    string
+	event string
+	data  string
+}
+
+// sseReader reads events from a stream, skipping comments.
+type sseReader struct {
+	t       *testing.T
+	scanner *bufio.Scanner
+}
+
+func openStream(t *testing.T, url string, header map[string]string) *sseReader {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	require.NoError(t, err)
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	return &sseReader{t: t, scanner: bufio.NewScanner(resp.Body)}
+}
+
+// comment waits for the comment line sent on connect, after it the
+// subscription is active.
+func (r *sseReader) comment() string {
+	r.t.Helper()
+	require.True(r.t, r.scanner.Scan(), "stream ended")
+	line := r.scanner.Text()
+	require.True(r.t, strings.HasPrefix(line, ":"), line)
+	require.True(r.t, r.scanner.Scan())
+	return line
+}
+
+func (r *sseReader) next() sseEvent {
+	r.t.Helper()
+	var ev sseEvent
+	for r.scanner.Scan() {
+		line := r.scanner.Text()
+		switch {
+		case line == "" && ev.event != "":
+			return ev
+		case strings.HasPrefix(line, "id: "):
+			ev.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			ev.event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			ev.data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+	r.t.Fatal("stream ended")
+	return ev
+}
+
+func (r *sseReader) order() (OrderSummary, string) {
+	r.t.Helper()
+	ev := r.next()
+	require.Equal(r.t, "order", ev.event)
+	var s OrderSummary
+	require.NoError(r.t, json.Unmarshal([]byte(ev.data), &s))
+	return s, ev.id
+}
+
+func streamOrder(uid, customerID, deliveryService string) order.Order {
+	return order.Order{
+		OrderUID:        uid,
+		CustomerID:      customerID,
+		DeliveryService: deliveryService,
+		Payment:         order.Payment{Amount: 1817, Currency: "USD"},
+		Items:           []order.Item{{ChrtID: 1}, {ChrtID: 2}},
+	}
+}
+
+func newStreamServer(t *testing.T, hub *watch.Hub, cfg config.StreamConfig) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(NewStreamHandler(hubWatcher{hub}, cfg).StreamOrdersHandler))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestStreamOrdersHandler(t *testing.T) {
+	hub := watch.NewHub(10)
+	srv := newStreamServer(t, hub, config.StreamConfig{})
+
+	all := openStream(t, srv.URL, nil)
+	assert.Equal(t, ": connected", all.comment())
+	filtered := openStream(t, srv.URL+"?customer_id=c1&delivery_service=meest", nil)
+	filtered.comment()
+
+	hub.Publish(streamOrder("a1", "c1", "dhl"))
+	hub.Publish(streamOrder("a2", "c1", "meest"))
+
+	got, id1 := all.order()
+	assert.Equal(t, OrderSummary{OrderUID: "a1", CustomerID: "c1", DeliveryService: "dhl", Amount: 1817, Currency: "USD", Items: 2}, got)
+	got, id2 := all.order()
+	assert.Equal(t, "a2", got.OrderUID)
+	seq1, err := strconv.ParseUint(id1, 10, 64)
+	require.NoError(t, err)
+	assert.Equal(t, strconv.FormatUint(seq1+1, 10), id2)
+
+	got, id := filtered.order()
+	assert.Equal(t, "a2", got.OrderUID)
+	assert.Equal(t, id2, id)
+}
+
+func TestStreamOrdersHandler_Resume(t *testing.T) {
+	hub := watch.NewHub(2)
+	srv := newStreamServer(t, hub, config.StreamConfig{})
+
+	first := openStream(t, srv.URL, nil)
+	first.comment()
+	for _, uid := range []string{"a1", "a2", "a3"} {
+		hub.Publish(streamOrder(uid, "c1", "meest"))
+	}
+	_, id1 := first.order()
+	_, id2 := first.order()
+
+	// переподключение получает пропущенные заказы
+	resumed := openStream(t, srv.URL, map[string]string{"Last-Event-ID": id2})
+	resumed.comment()
+	got, _ := resumed.order()
+	assert.Equal(t, "a3", got.OrderUID)
+	hub.Publish(streamOrder("a4", "c1", "meest"))
+	got, _ = resumed.order()
+	assert.Equal(t, "a4", got.OrderUID)
+
+	// a2 уже вытеснен из истории, клиенту нужно перечитать список
+	for _, last := range []string{id1, "garbage"} {
+		reset := openStream(t, srv.URL, map[string]string{"Last-Event-ID": last})
+		assert.Equal(t, "reset", reset.next().event)
+		hub.Publish(streamOrder("a5-"+last, "c1", "meest"))
+		got, _ = reset.order()
+		assert.Equal(t, "a5-"+last, got.OrderUID)
+	}
+}
+
+func TestStreamOrdersHandler_Heartbeat(t *testing.T) {
+	srv := newStreamServer(t, watch.NewHub(0), config.StreamConfig{Heartbeat: 10 * time.Millisecond})
+
+	stream := openStream(t, srv.URL, nil)
+	stream.comment()
+	assert.Equal(t, ": ping", stream.comment())
+}
+
+// blockingWriter stands for a client that stopped reading.
+type blockingWriter struct {
+	*httptest.ResponseRecorder
+	release chan struct{}
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	<-w.release
+	return w.ResponseRecorder.Write(p)
+}
+
+func TestStreamOrdersHandler_SlowClient(t *testing.T) {
+	hub := watch.NewHub(0)
+	h := NewStreamHandler(hubWatcher{hub}, config.StreamConfig{Buffer: 2})
+	w := &blockingWriter{ResponseRecorder: httptest.NewRecorder(), release: make(chan struct{})}
+
+	done := make(chan struct{})
+	go func() {
+		h.StreamOrdersHandler(w, httptest.NewRequest(http.MethodGet, "/orders/stream", nil))
+		close(done)
+	}()
+	require.Eventually(t, func() bool { return hub.Subscribers() == 1 }, time.Second, time.Millisecond)
+
+	for _, uid := range []string{"a1", "a2", "a3"} {
+		hub.Publish(streamOrder(uid, "c1", "meest"))
+	}
+	// буфер на 2 заказа переполнен, подписка снята, не дожидаясь клиента
+	assert.Zero(t, hub.Subscribers())
+
+	close(w.release)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("handler did not return")
+	}
+	assert.Contains(t, w.Body.String(), "a2")
+	assert.NotContains(t, w.Body.String(), "a3")
+}
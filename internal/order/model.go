@@ -21,6 +21,9 @@ type Order struct {
 	SMID              int       `json:"sm_id" db:"sm_id" validate:"required,min=1"`
 	DateCreated       time.Time `json:"date_created" db:"date_created" validate:"required"`
 	OOFShard          string    `json:"oof_shard" db:"oof_shard" validate:"required"`
+	Version           int       `json:"-" db:"version"`
+	// IngestedAt - время сохранения заказа сервисом, задается репозиторием
+	IngestedAt *time.Time `json:"ingested_at,omitempty" db:"ingested_at"`
 }
 
 type Delivery struct {
@@ -64,4 +67,6 @@ type Item struct {
 	NmID       int    `json:"nm_id" db:"nm_id" validate:"required,min=1"`
 	Brand      string `json:"brand" db:"brand" validate:"required"`
 	Status     int    `json:"status" db:"status" validate:"required,min=0"`
+	// DateCreated повторяет дату заказа, по ней секционирована items
+	DateCreated time.Time `json:"-" db:"date_created"`
 }
\ No newline at end of file
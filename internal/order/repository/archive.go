@@ -0,0 +1,186 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// ExpiredOrderUIDs returns up to limit orders created before the cutoff,
+// oldest first.
+func (r *OrderRepository) ExpiredOrderUIDs(ctx context.Context, before time.Time, limit int) ([]string, error) {
+	const op = "repository.order.ExpiredOrderUIDs"
+
+	var expired []order.Order
+	for _, shard := range r.shards {
+		var orders []order.Order
+		err := shard.DB.SelectContext(ctx, &orders, `
+			SELECT order_uid, date_created FROM orders WHERE date_created < $1
+			ORDER BY date_created LIMIT $2`, before, limit)
+		if err != nil {
+			return nil, fmt.Errorf("%s: shard %s: %w", op, shard.ID, err)
+		}
+		expired = append(expired, orders...)
+	}
+
+	sortByDateCreated(expired)
+	if len(expired) > limit {
+		expired = expired[:limit]
+	}
+	uids := make([]string, len(expired))
+	for i, o := range expired {
+		uids[i] = o.OrderUID
+	}
+	return uids, nil
+}
+
+// GetOrdersByUIDs loads full orders, skipping uids that do not exist.
+func (r *OrderRepository) GetOrdersByUIDs(ctx context.Context, uids []string) ([]order.Order, error) {
+	const op = "repository.order.GetOrdersByUIDs"
+
+	groups, err := r.locateAll(ctx, uids)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var all []order.Order
+	for shard, shardUIDs := range groups {
+		var orders []order.Order
+		err := shard.DB.SelectContext(ctx, &orders, `
+			SELECT * FROM orders WHERE order_uid = ANY($1)`, pq.Array(shardUIDs))
+		if err != nil {
+			return nil, fmt.Errorf("%s: shard %s: %w", op, shard.ID, err)
+		}
+
+		for i := range orders {
+			if err := r.loadDetails(ctx, shard.DB, hotTables, &orders[i]); err != nil {
+				return nil, fmt.Errorf("%s: shard %s: %w", op, shard.ID, err)
+			}
+		}
+		all = append(all, orders...)
+	}
+	sortByDateCreated(all)
+	return all, nil
+}
+
+// ArchiveOrders moves orders with their details into the archive tables of
+// their shard and returns how many orders were moved. Each shard is moved in
+// one transaction.
+func (r *OrderRepository) ArchiveOrders(ctx context.Context, uids []string) (int, error) {
+	const op = "repository.order.ArchiveOrders"
+
+	groups, err := r.locateAll(ctx, uids)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var moved int
+	for shard, shardUIDs := range groups {
+		n, err := archiveOrders(ctx, shard.DB, shardUIDs)
+		if err != nil {
+			return moved, fmt.Errorf("%s: shard %s: %w", op, shard.ID, err)
+		}
+		moved += n
+		if err := r.release(ctx, shardUIDs...); err != nil {
+			return moved, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	return moved, nil
+}
+
+func archiveOrders(ctx context.Context, db *sqlx.DB, uids []string) (int, error) {
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// A copy archived earlier (the order was ingested again before ingest
+	// checked the archive) is replaced by the newer one, otherwise the
+	// primary key of orders_archive fails the whole batch on every run.
+	for _, table := range []string{archiveTables.deliveries, archiveTables.payments, archiveTables.items, archiveTables.orders} {
+		_, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE order_uid = ANY($1)`, pq.Array(uids))
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	// details first: the archive has no foreign keys, but the delete below
+	// cascades from order_keys
+	for _, t := range [][2]string{
+		{hotTables.deliveries, archiveTables.deliveries},
+		{hotTables.payments, archiveTables.payments},
+		{hotTables.items, archiveTables.items},
+		{hotTables.orders, archiveTables.orders},
+	} {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO `+t[1]+` SELECT * FROM `+t[0]+` WHERE order_uid = ANY($1)`, pq.Array(uids))
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM order_keys WHERE order_uid = ANY($1)`, pq.Array(uids))
+	if err != nil {
+		return 0, err
+	}
+	moved, _ := res.RowsAffected()
+
+	if err := notifyChanges(ctx, tx, removed(uids)...); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return int(moved), nil
+}
+
+// DeleteOrders removes orders with their details and returns how many were
+// deleted. Used after the orders were exported elsewhere.
+func (r *OrderRepository) DeleteOrders(ctx context.Context, uids []string) (int, error) {
+	const op = "repository.order.DeleteOrders"
+
+	groups, err := r.locateAll(ctx, uids)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var deleted int
+	for shard, shardUIDs := range groups {
+		res, err := shard.DB.ExecContext(ctx, `DELETE FROM order_keys WHERE order_uid = ANY($1)`, pq.Array(shardUIDs))
+		if err != nil {
+			return deleted, fmt.Errorf("%s: shard %s: %w", op, shard.ID, err)
+		}
+		n, _ := res.RowsAffected()
+		deleted += int(n)
+		if err := notifyChanges(ctx, shard.DB, removed(shardUIDs)...); err != nil {
+			return deleted, fmt.Errorf("%s: shard %s: %w", op, shard.ID, err)
+		}
+		if err := r.release(ctx, shardUIDs...); err != nil {
+			return deleted, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	return deleted, nil
+}
+
+// GetArchivedOrderByUID looks an order up in the archive tables of every
+// shard, nil if it was never archived.
+func (r *OrderRepository) GetArchivedOrderByUID(ctx context.Context, uid string) (*order.Order, error) {
+	const op = "repository.order.GetArchivedOrderByUID"
+
+	for _, shard := range r.shards {
+		order, err := r.getOrder(ctx, shard.DB, archiveTables, uid)
+		if err != nil {
+			return nil, fmt.Errorf("%s: shard %s: %w", op, shard.ID, err)
+		}
+		if order != nil {
+			return order, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order/fixture"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/pgtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEraseCustomer_Archived(t *testing.T) {
+	repo := NewOrderRepository(pgtest.NewDB(t))
+	gen := newFixture(fixture.Options{})
+
+	archived, hot := gen.Order(), gen.Order()
+	archived.CustomerID, hot.CustomerID = "c1", "c1"
+	require.NoError(t, repo.SaveOrder(ctx, &archived))
+	require.NoError(t, repo.SaveOrder(ctx, &hot))
+	moved, err := repo.ArchiveOrders(ctx, []string{archived.OrderUID})
+	require.NoError(t, err)
+	require.Equal(t, 1, moved)
+
+	erasure, err := repo.EraseCustomer(ctx, "c1", "ops", "http")
+	require.NoError(t, err)
+	assert.Equal(t, 2, erasure.Orders)
+	assert.ElementsMatch(t, []string{archived.OrderUID, hot.OrderUID}, erasure.OrderUIDs)
+
+	got, err := repo.GetArchivedOrderByUID(ctx, archived.OrderUID)
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, ErasedName, got.Delivery.Name)
+	assert.Empty(t, got.Delivery.Phone)
+	assert.Empty(t, got.Delivery.Email)
+	assert.Empty(t, got.Delivery.Address)
+	assert.NotEqual(t, "c1", got.CustomerID)
+	assert.Equal(t, archived.Payment.Amount, got.Payment.Amount, "payments are kept")
+
+	got, err = repo.GetOrderByUID(ctx, hot.OrderUID)
+	require.NoError(t, err)
+	assert.Equal(t, ErasedName, got.Delivery.Name)
+}
+
+func TestArchiveOrders_Reingested(t *testing.T) {
+	db := pgtest.NewDB(t)
+	repo := NewOrderRepository(db)
+	gen := newFixture(fixture.Options{})
+
+	o := gen.Order()
+	require.NoError(t, repo.SaveOrder(ctx, &o))
+	_, err := repo.ArchiveOrders(ctx, []string{o.OrderUID})
+	require.NoError(t, err)
+
+	// повторная доставка архивного заказа - дубликат
+	again := o
+	err = repo.SaveOrder(ctx, &again)
+	assert.ErrorIs(t, err, ErrOrderArchived)
+	assert.ErrorIs(t, err, ErrOrderExists)
+	errs, err := repo.SaveOrders(ctx, []order.Order{o})
+	require.NoError(t, err)
+	assert.ErrorIs(t, errs[0], ErrOrderArchived)
+
+	// копия, попавшая в основные таблицы до проверки архива, заменяет архивную
+	_, err = db.Exec(`DELETE FROM orders_archive WHERE order_uid = $1`, o.OrderUID)
+	require.NoError(t, err)
+	require.NoError(t, repo.SaveOrder(ctx, &again))
+	_, err = db.Exec(`INSERT INTO orders_archive SELECT * FROM orders WHERE order_uid = $1`, o.OrderUID)
+	require.NoError(t, err)
+
+	moved, err := repo.ArchiveOrders(ctx, []string{o.OrderUID})
+	require.NoError(t, err)
+	assert.Equal(t, 1, moved)
+	for _, table := range []string{"orders_archive", "deliveries_archive", "payments_archive"} {
+		var n int
+		require.NoError(t, db.Get(&n, `SELECT count(*) FROM `+table+` WHERE order_uid = $1`, o.OrderUID))
+		assert.Equal(t, 1, n, table)
+	}
+	var items int
+	require.NoError(t, db.Get(&items, `SELECT count(*) FROM items_archive WHERE order_uid = $1`, o.OrderUID))
+	assert.Equal(t, len(o.Items), items)
+}
@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// ChangesChannel is the Postgres NOTIFY channel every write to an existing
+// order is announced on, so other replicas can drop their cached copy.
+const ChangesChannel = "order_changes"
+
+// Change is the payload of a ChangesChannel notification. Version is the
+// order version after the write, for a deleted order one past its last
+// version, so caches can refuse copies read before the change. 0 means the
+// order was removed or changed in bulk and must be dropped regardless of the
+// cached version.
+type Change struct {
+	OrderUID string `json:"order_uid" db:"order_uid"`
+	Version  int    `json:"version,omitempty" db:"version"`
+}
+
+// notifyChanges queues notifications on e. Inside a transaction Postgres
+// delivers them on commit and drops them on rollback.
+func notifyChanges(ctx context.Context, e sqlx.ExecerContext, changes ...Change) error {
+	if len(changes) == 0 {
+		return nil
+	}
+	payloads := make([]string, len(changes))
+	for i, c := range changes {
+		data, err := json.Marshal(c)
+		if err != nil {
+			return err
+		}
+		payloads[i] = string(data)
+	}
+	_, err := e.ExecContext(ctx, `
+		SELECT pg_notify($1, payload) FROM unnest($2::text[]) AS payload`,
+		ChangesChannel, pq.Array(payloads))
+	return err
+}
+
+// removed builds version 0 changes for uids.
+func removed(uids []string) []Change {
+	changes := make([]Change, len(uids))
+	for i, uid := range uids {
+		changes[i] = Change{OrderUID: uid}
+	}
+	return changes
+}
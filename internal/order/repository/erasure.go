@@ -0,0 +1,154 @@
+package repository
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// ErasedName replaces the recipient name of anonymized deliveries.
+const ErasedName = "ERASED"
+
+// EraseCustomer anonymizes every order of a customer, archived ones included:
+// delivery name, phone, email, address and zip are wiped, the customer id is
+// replaced by a random pseudonym and an audit record is written. Payments and
+// items are left intact so revenue and item statistics stay correct. Orders
+// in the main database are erased in the audit transaction, every other shard
+// in a transaction of its own committed before it. Orders exported to files
+// by retention are out of reach.
+func (r *OrderRepository) EraseCustomer(ctx context.Context, customerID, requestedBy, source string) (*order.Erasure, error) {
+	const op = "repository.order.EraseCustomer"
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	alias, err := pseudonym()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	// [] вместо null в ответе API, если заказов нет
+	uids := []string{}
+	versions := make(map[string]int)
+	for _, shard := range r.shards {
+		var erased []Change
+		if shard.DB == r.db {
+			erased, err = eraseOrders(ctx, tx, customerID, alias)
+		} else {
+			erased, err = eraseOnShard(ctx, shard.DB, customerID, alias)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: shard %s: %w", op, shard.ID, err)
+		}
+		for _, c := range erased {
+			uids = append(uids, c.OrderUID)
+			versions[c.OrderUID] = c.Version
+		}
+	}
+
+	erasure := &order.Erasure{
+		CustomerHash: hashCustomerID(customerID),
+		Orders:       len(uids),
+		RequestedBy:  requestedBy,
+		Source:       source,
+		OrderUIDs:    uids,
+		Versions:     versions,
+	}
+	err = tx.QueryRowxContext(ctx, `
+		INSERT INTO erasure_audit (customer_hash, orders, requested_by, source)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, erased_at`,
+		erasure.CustomerHash, erasure.Orders, erasure.RequestedBy, erasure.Source,
+	).Scan(&erasure.ID, &erasure.ErasedAt)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return erasure, nil
+}
+
+func eraseOnShard(ctx context.Context, db *sqlx.DB, customerID, alias string) ([]Change, error) {
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	erased, err := eraseOrders(ctx, tx, customerID, alias)
+	if err != nil {
+		return nil, err
+	}
+	return erased, tx.Commit()
+}
+
+// eraseOrders anonymizes the customer's hot and archived orders visible to
+// tx and returns their uids with the new versions.
+func eraseOrders(ctx context.Context, tx *sqlx.Tx, customerID, alias string) ([]Change, error) {
+	hot, err := eraseInTables(ctx, tx, hotTables, customerID, alias)
+	if err != nil {
+		return nil, err
+	}
+	// новая версия, а не 0: кеш не примет копию, прочитанную до удаления
+	if err := notifyChanges(ctx, tx, hot...); err != nil {
+		return nil, err
+	}
+
+	// архивные заказы не кешируются, уведомлять о них некого
+	archived, err := eraseInTables(ctx, tx, archiveTables, customerID, alias)
+	if err != nil {
+		return nil, err
+	}
+	return append(hot, archived...), nil
+}
+
+func eraseInTables(ctx context.Context, tx *sqlx.Tx, tables tableSet, customerID, alias string) ([]Change, error) {
+	var uids []string
+	err := tx.SelectContext(ctx, &uids, `
+		SELECT order_uid FROM `+tables.orders+` WHERE customer_id = $1 FOR UPDATE`, customerID)
+	if err != nil || len(uids) == 0 {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE `+tables.deliveries+` SET name = $2, phone = '', email = '', address = '', zip = ''
+		WHERE order_uid = ANY($1)`, pq.Array(uids), ErasedName)
+	if err != nil {
+		return nil, err
+	}
+
+	var erased []Change
+	err = tx.SelectContext(ctx, &erased, `
+		UPDATE `+tables.orders+` SET customer_id = $2, internal_signature = '', version = version + 1
+		WHERE order_uid = ANY($1)
+		RETURNING order_uid, version`, pq.Array(uids), alias)
+	if err != nil {
+		return nil, err
+	}
+	return erased, nil
+}
+
+func hashCustomerID(customerID string) string {
+	sum := sha256.Sum256([]byte(customerID))
+	return hex.EncodeToString(sum[:])
+}
+
+// pseudonym is random so anonymized orders cannot be linked back to the
+// customer, even by someone who knows the original id.
+func pseudonym() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate pseudonym: %w", err)
+	}
+	return "erased-" + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// RebalanceStats reports what Rebalance did or, in a dry run, would do.
+type RebalanceStats struct {
+	Scanned int            `json:"scanned"`
+	Moved   int            `json:"moved"`
+	Indexed int            `json:"indexed"`
+	ByShard map[string]int `json:"moved_by_shard,omitempty"`
+}
+
+// ErrNotSharded is returned by Rebalance when WithShards was not used.
+var ErrNotSharded = errors.New("sharding is not configured")
+
+// Rebalance walks every shard and moves orders whose ShardKey now maps to a
+// different shard, e.g. after a shard was added, and fills the directory for
+// orders written before sharding was enabled. It can be rerun after a
+// failure: an order is copied, re-pointed in the directory and only then
+// deleted from its old shard. Writes to an order while it is being moved may
+// be lost, so run it when the consumer is stopped.
+func (r *OrderRepository) Rebalance(ctx context.Context, batchSize int, dryRun bool) (RebalanceStats, error) {
+	const op = "repository.order.Rebalance"
+
+	stats := RebalanceStats{ByShard: make(map[string]int)}
+	if !r.sharded() {
+		return stats, fmt.Errorf("%s: %w", op, ErrNotSharded)
+	}
+
+	for _, src := range r.shards {
+		last := ""
+		for {
+			var keys []struct {
+				UID      string `db:"order_uid"`
+				ShardKey string `db:"shardkey"`
+			}
+			err := src.DB.SelectContext(ctx, &keys, `
+				SELECT order_uid, shardkey FROM orders WHERE order_uid > $1
+				ORDER BY order_uid LIMIT $2`, last, batchSize)
+			if err != nil {
+				return stats, fmt.Errorf("%s: shard %s: %w", op, src.ID, err)
+			}
+			if len(keys) == 0 {
+				break
+			}
+			last = keys[len(keys)-1].UID
+
+			for _, k := range keys {
+				stats.Scanned++
+				dst := r.shardFor(k.ShardKey)
+
+				if dst == src {
+					if dryRun {
+						continue
+					}
+					changed, err := r.assign(ctx, k.UID, src)
+					if err != nil {
+						return stats, fmt.Errorf("%s: %s: %w", op, k.UID, err)
+					}
+					if changed {
+						stats.Indexed++
+					}
+					continue
+				}
+
+				if !dryRun {
+					if err := r.moveOrder(ctx, k.UID, src, dst); err != nil {
+						return stats, fmt.Errorf("%s: %s: %w", op, k.UID, err)
+					}
+				}
+				stats.Moved++
+				stats.ByShard[dst.ID]++
+			}
+		}
+	}
+	return stats, nil
+}
+
+// moveOrder copies an order with its version from src to dst, points the
+// directory at dst and deletes it from src.
+func (r *OrderRepository) moveOrder(ctx context.Context, uid string, src, dst *Shard) error {
+	o, err := r.getOrder(ctx, src.DB, hotTables, uid)
+	if err != nil {
+		return err
+	}
+	if o == nil {
+		return nil
+	}
+
+	// ErrOrderExists: copied by an earlier interrupted run. An archived copy
+	// on dst is a different order, src must not be deleted then.
+	err = r.saveOrder(ctx, dst.DB, o)
+	if err != nil && (!errors.Is(err, ErrOrderExists) || errors.Is(err, ErrOrderArchived)) {
+		return err
+	}
+	if _, err := r.assign(ctx, uid, dst); err != nil {
+		return err
+	}
+	_, err = src.DB.ExecContext(ctx, `DELETE FROM order_keys WHERE order_uid = $1`, uid)
+	return err
+}
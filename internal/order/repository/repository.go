@@ -5,47 +5,353 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"time"
 
+	"github.com/Egor-Pomidor-pdf/order-service/internal/fieldcrypt"
 	"github.com/Egor-Pomidor-pdf/order-service/internal/order"
 	"github.com/jmoiron/sqlx"
 	"github.com/lib/pq"
 )
 
+// tableSet names the tables an order is spread across. Archived orders live
+// in tables with the same layout. keys is the order_uid directory of the
+// partitioned hot tables, empty for the archive.
+type tableSet struct {
+	orders, deliveries, payments, items, keys string
+}
+
+var (
+	hotTables     = tableSet{"orders", "deliveries", "payments", "items", "order_keys"}
+	archiveTables = tableSet{"orders_archive", "deliveries_archive", "payments_archive", "items_archive", ""}
+)
+
+// OrderRepository stores orders in one or more shard databases. Without
+// WithShards the main database is the only shard.
 type OrderRepository struct {
-	db *sqlx.DB
+	db     *sqlx.DB
+	shards []*Shard
+	cipher *fieldcrypt.Cipher
+}
+
+type Option func(*OrderRepository)
+
+// WithFieldCipher encrypts the delivery name, phone, email and address
+// columns at rest. A nil cipher leaves them in plain text.
+func WithFieldCipher(c *fieldcrypt.Cipher) Option {
+	return func(r *OrderRepository) {
+		r.cipher = c
+	}
+}
+
+// FieldCipher returns the cipher of the delivery PII columns, nil when they
+// are stored in plain text.
+func (r *OrderRepository) FieldCipher() *fieldcrypt.Cipher {
+	return r.cipher
 }
 
-func NewOrderRepository(db *sqlx.DB) *OrderRepository {
-	return &OrderRepository{db: db}
+func NewOrderRepository(db *sqlx.DB, opts ...Option) *OrderRepository {
+	r := &OrderRepository{db: db}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if len(r.shards) == 0 {
+		r.shards = []*Shard{{ID: DefaultShard, DB: db}}
+	}
+	return r
 }
 
+// SaveOrder stores a new order on the shard chosen by its ShardKey.
 func (r *OrderRepository) SaveOrder(ctx context.Context, order *order.Order) error {
 	const op = "repository.order.SaveOrder"
 
-	tx, err := r.db.BeginTxx(ctx, nil)
-	if err != nil {
+	shard := r.shardFor(order.ShardKey)
+	if err := r.claim(ctx, order.OrderUID, shard); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	order.Version = 1
+	order.IngestedAt = ingestedNow()
+	if err := r.saveOrder(ctx, shard.DB, order); err != nil {
+		if releaseErr := r.release(ctx, order.OrderUID); releaseErr != nil {
+			err = errors.Join(err, releaseErr)
+		}
 		return fmt.Errorf("%s: %w", op, err)
 	}
+	return nil
+}
+
+// saveOrder inserts order with its details and version into db.
+func (r *OrderRepository) saveOrder(ctx context.Context, db *sqlx.DB, order *order.Order) error {
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
 	defer tx.Rollback()
 
+	if err := r.insertOrder(ctx, tx, order); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SaveOrders stores new orders in one transaction per shard and returns an
+// error per order, nil for the saved ones. A failed order is rolled back to
+// its savepoint and does not affect the rest of the batch. The second result
+// is set when the batch could not be written at all.
+func (r *OrderRepository) SaveOrders(ctx context.Context, orders []order.Order) ([]error, error) {
+	const op = "repository.order.SaveOrders"
+
+	errs := make([]error, len(orders))
+	groups := make(map[*Shard][]int)
+	for i := range orders {
+		shard := r.shardFor(orders[i].ShardKey)
+		if err := r.claim(ctx, orders[i].OrderUID, shard); err != nil {
+			errs[i] = err
+			continue
+		}
+		orders[i].Version = 1
+		orders[i].IngestedAt = ingestedNow()
+		groups[shard] = append(groups[shard], i)
+	}
+
+	var batchErr error
+	var unclaimed []string
+	for shard, idx := range groups {
+		if batchErr == nil {
+			batchErr = r.saveBatch(ctx, shard.DB, orders, idx, errs)
+		}
+		for _, i := range idx {
+			if batchErr != nil || errs[i] != nil {
+				unclaimed = append(unclaimed, orders[i].OrderUID)
+			}
+		}
+	}
+	if len(unclaimed) > 0 {
+		if err := r.release(ctx, unclaimed...); err != nil {
+			batchErr = errors.Join(batchErr, err)
+		}
+	}
+	if batchErr != nil {
+		return errs, fmt.Errorf("%s: %w", op, batchErr)
+	}
+	return errs, nil
+}
+
+// saveBatch inserts orders[idx] into db, recording per order errors in errs.
+func (r *OrderRepository) saveBatch(ctx context.Context, db *sqlx.DB, orders []order.Order, idx []int, errs []error) error {
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, i := range idx {
+		if _, err := tx.ExecContext(ctx, `SAVEPOINT save_order`); err != nil {
+			return err
+		}
+		if err := r.insertOrder(ctx, tx, &orders[i]); err != nil {
+			errs[i] = err
+			if _, err := tx.ExecContext(ctx, `ROLLBACK TO SAVEPOINT save_order`); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, `RELEASE SAVEPOINT save_order`); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func ingestedNow() *time.Time {
+	now := time.Now().UTC()
+	return &now
+}
+
+// insertOrder inserts order with its details and version within tx.
+func (r *OrderRepository) insertOrder(ctx context.Context, tx *sqlx.Tx, order *order.Order) error {
+	// orders секционирована по date_created, уникальность order_uid
+	// проверяет order_keys
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO order_keys (order_uid, date_created) VALUES ($1, $2)`,
+		order.OrderUID, order.DateCreated)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return ErrOrderExists
+		}
+		return err
+	}
+
+	// архивный заказ тоже считается сохраненным: повторная доставка или
+	// replay не должны создавать вторую копию, ее нельзя будет архивировать
+	var archived bool
+	err = tx.GetContext(ctx, &archived, `
+		SELECT EXISTS (SELECT 1 FROM orders_archive WHERE order_uid = $1)`, order.OrderUID)
+	if err != nil {
+		return err
+	}
+	if archived {
+		return ErrOrderArchived
+	}
+
 	// Сохраняем основной заказ
 	_, err = tx.NamedExecContext(ctx, `
 		INSERT INTO orders (
 			order_uid, track_number, entry, locale, 
 			internal_signature, customer_id, delivery_service,
-			shardkey, sm_id, date_created, oof_shard
+			shardkey, sm_id, date_created, oof_shard, version, ingested_at
 		) VALUES (
 			:order_uid, :track_number, :entry, :locale,
 			:internal_signature, :customer_id, :delivery_service,
-			:shardkey, :sm_id, :date_created, :oof_shard
+			:shardkey, :sm_id, :date_created, :oof_shard, :version, :ingested_at
 		)`, order)
 	if err != nil {
-		var pqErr *pq.Error
-		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
-			return fmt.Errorf("%s: %w", op, ErrOrderExists)
+		return err
+	}
+
+	return r.insertDetails(ctx, tx, order)
+}
+
+// UpdateOrder replaces an order and its delivery, payment and items if the
+// stored version still equals expectedVersion (0 skips the check). On success
+// order.Version holds the new version. The order stays on its current shard,
+// a changed ShardKey is applied by Rebalance.
+func (r *OrderRepository) UpdateOrder(ctx context.Context, order *order.Order, expectedVersion int) error {
+	const op = "repository.order.UpdateOrder"
+
+	shard, err := r.locate(ctx, order.OrderUID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if shard == nil {
+		return fmt.Errorf("%s: %w", op, ErrOrderNotFound)
+	}
+
+	tx, err := shard.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	var version int
+	err = tx.QueryRowxContext(ctx, `
+		UPDATE orders SET
+			track_number = $2, entry = $3, locale = $4,
+			internal_signature = $5, customer_id = $6, delivery_service = $7,
+			shardkey = $8, sm_id = $9, date_created = $10, oof_shard = $11,
+			version = version + 1
+		WHERE order_uid = $1 AND ($12 = 0 OR version = $12)
+		  AND date_created = (SELECT date_created FROM order_keys WHERE order_uid = $1)
+		RETURNING version, ingested_at`,
+		order.OrderUID, order.TrackNumber, order.Entry, order.Locale,
+		order.InternalSignature, order.CustomerID, order.DeliveryService,
+		order.ShardKey, order.SMID, order.DateCreated, order.OOFShard,
+		expectedVersion).Scan(&version, &order.IngestedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%s: %w", op, r.missOrConflict(ctx, tx, order.OrderUID))
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// date_created могла измениться, тогда строка уже в другой секции
+	_, err = tx.ExecContext(ctx, `
+		UPDATE order_keys SET date_created = $2 WHERE order_uid = $1`,
+		order.OrderUID, order.DateCreated)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, table := range []string{"deliveries", "payments", "items"} {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE order_uid = $1`, order.OrderUID); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
 		}
+	}
+
+	if err := r.insertDetails(ctx, tx, order); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := notifyChanges(ctx, tx, Change{OrderUID: order.OrderUID, Version: version}); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
+	order.Version = version
+	return nil
+}
+
+// DeleteOrder removes an order if the stored version still equals
+// expectedVersion (0 skips the check) and returns the version the removal is
+// announced with, see Change. The order and its details go with ON DELETE
+// CASCADE from order_keys.
+func (r *OrderRepository) DeleteOrder(ctx context.Context, uid string, expectedVersion int) (int, error) {
+	const op = "repository.order.DeleteOrder"
+
+	shard, err := r.locate(ctx, uid)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	if shard == nil {
+		return 0, fmt.Errorf("%s: %w", op, ErrOrderNotFound)
+	}
+
+	tx, err := shard.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	var version int
+	err = tx.GetContext(ctx, &version, `
+		DELETE FROM order_keys k USING orders o
+		WHERE k.order_uid = $1 AND o.order_uid = k.order_uid AND o.date_created = k.date_created
+		  AND ($2 = 0 OR o.version = $2)
+		RETURNING o.version`,
+		uid, expectedVersion)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("%s: %w", op, r.missOrConflict(ctx, tx, uid))
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	version++
+	if err := notifyChanges(ctx, tx, Change{OrderUID: uid, Version: version}); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := r.release(ctx, uid); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return version, nil
+}
+
+// missOrConflict explains why a versioned write matched no rows.
+func (r *OrderRepository) missOrConflict(ctx context.Context, tx *sqlx.Tx, uid string) error {
+	var exists bool
+	err := tx.GetContext(ctx, &exists, `SELECT EXISTS (SELECT 1 FROM orders WHERE order_uid = $1)`, uid)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return ErrVersionConflict
+	}
+	return ErrOrderNotFound
+}
+
+// insertDetails сохраняет доставку, оплату и товары заказа
+func (r *OrderRepository) insertDetails(ctx context.Context, tx *sqlx.Tx, order *order.Order) error {
+	delivery, err := r.cipher.SealDelivery(order.Delivery)
+	if err != nil {
+		return err
+	}
 
 	// Сохраняем доставку
 	_, err = tx.ExecContext(ctx, `
@@ -53,10 +359,10 @@ func (r *OrderRepository) SaveOrder(ctx context.Context, order *order.Order) err
             order_uid, name, phone, zip, city, 
             address, region, email
         ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
-        order.OrderUID, order.Delivery.Name, order.Delivery.Phone, order.Delivery.Zip,
-        order.Delivery.City, order.Delivery.Address, order.Delivery.Region, order.Delivery.Email)
+        order.OrderUID, delivery.Name, delivery.Phone, delivery.Zip,
+        delivery.City, delivery.Address, delivery.Region, delivery.Email)
 	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+		return err
 	}
 
 	// Сохраняем оплату
@@ -70,7 +376,7 @@ func (r *OrderRepository) SaveOrder(ctx context.Context, order *order.Order) err
 	order.Payment.Provider, order.Payment.Amount, order.Payment.PaymentDT, order.Payment.Bank,
 	order.Payment.DeliveryCost, order.Payment.GoodsTotal, order.Payment.CustomFee)
 	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+		return err
 	}
 
 	// Сохраняем товары
@@ -79,89 +385,189 @@ func (r *OrderRepository) SaveOrder(ctx context.Context, order *order.Order) err
 		INSERT INTO items (
 			order_uid, chrt_id, track_number, price, rid,
 			name, sale, size, total_price, nm_id,
-			brand, status
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
+			brand, status, date_created
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`,
 		order.OrderUID, item.ChrtID, item.TrackNumber, item.Price, item.RID,
 		item.Name, item.Sale, item.Size, item.TotalPrice, item.NmID,
-		item.Brand, item.Status)
+		item.Brand, item.Status, order.DateCreated)
 		if err != nil {
-			return fmt.Errorf("%s: %w", op, err)
+			return err
 		}
 	}
 
-	return tx.Commit()
+	return nil
 }
 
 func (r *OrderRepository) GetOrderByUID(ctx context.Context, uid string) (*order.Order, error) {
 	const op = "repository.order.GetOrderByUID"
 
+	shard, err := r.locate(ctx, uid)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if shard == nil {
+		return nil, nil
+	}
+
+	order, err := r.getOrder(ctx, shard.DB, hotTables, uid)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return order, nil
+}
+
+// getOrder loads an order with its details from tables, nil if it is missing.
+func (r *OrderRepository) getOrder(ctx context.Context, db *sqlx.DB, tables tableSet, uid string) (*order.Order, error) {
+	query := `SELECT * FROM ` + tables.orders + ` WHERE order_uid = $1`
+	if tables.keys != "" {
+		// дата из order_keys позволяет читать только одну секцию
+		query += ` AND date_created = (SELECT date_created FROM ` + tables.keys + ` WHERE order_uid = $1)`
+	}
+
 	var order order.Order
-	err := r.db.GetContext(ctx, &order, `
-		SELECT * FROM orders WHERE order_uid = $1`, uid)
+	err := db.GetContext(ctx, &order, query, uid)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil
 		}
-		return nil, fmt.Errorf("%s: %w", op, err)
+		return nil, err
+	}
+
+	if err := r.loadDetails(ctx, db, tables, &order); err != nil {
+		return nil, err
 	}
+	return &order, nil
+}
 
+// loadDetails подгружает доставку, оплату и товары заказа
+func (r *OrderRepository) loadDetails(ctx context.Context, db *sqlx.DB, tables tableSet, order *order.Order) error {
 	// Загружаем доставку
-	err = r.db.GetContext(ctx, &order.Delivery, `
-		SELECT * FROM deliveries WHERE order_uid = $1`, uid)
+	err := db.GetContext(ctx, &order.Delivery, `
+		SELECT * FROM `+tables.deliveries+` WHERE order_uid = $1`, order.OrderUID)
 	if err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
+		return err
+	}
+	if err := r.cipher.OpenDelivery(&order.Delivery); err != nil {
+		return err
 	}
 
 	// Загружаем оплату
-	err = r.db.GetContext(ctx, &order.Payment, `
-		SELECT * FROM payments WHERE order_uid = $1`, uid)
+	err = db.GetContext(ctx, &order.Payment, `
+		SELECT * FROM `+tables.payments+` WHERE order_uid = $1`, order.OrderUID)
 	if err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
+		return err
 	}
 
 	// Загружаем товары
-	err = r.db.SelectContext(ctx, &order.Items, `
-		SELECT * FROM items WHERE order_uid = $1`, uid)
-	if err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
-	}
-
-	return &order, nil
+	return db.SelectContext(ctx, &order.Items, `
+		SELECT * FROM `+tables.items+` WHERE order_uid = $1 AND date_created = $2
+		ORDER BY id`,
+		order.OrderUID, order.DateCreated)
 }
 
 func (r *OrderRepository) GetAllOrders(ctx context.Context) ([]order.Order, error) {
 	const op = "repository.order.GetAllOrders"
 
-	var orders []order.Order
-	err := r.db.SelectContext(ctx, &orders, `SELECT * FROM orders`)
-	if err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
+	var all []order.Order
+	for _, shard := range r.shards {
+		var orders []order.Order
+		err := shard.DB.SelectContext(ctx, &orders, `SELECT * FROM orders`)
+		if err != nil {
+			return nil, fmt.Errorf("%s: shard %s: %w", op, shard.ID, err)
+		}
+
+		// Для каждого заказа подгружаем связанные данные
+		for i := range orders {
+			if err := r.loadDetails(ctx, shard.DB, hotTables, &orders[i]); err != nil {
+				return nil, fmt.Errorf("%s: shard %s: %w", op, shard.ID, err)
+			}
+		}
+		all = append(all, orders...)
 	}
 
-	// Для каждого заказа подгружаем связанные данные
-	for i := range orders {
-		uid := orders[i].OrderUID
-		
-		err = r.db.GetContext(ctx, &orders[i].Delivery, `
-			SELECT * FROM deliveries WHERE order_uid = $1`, uid)
+	return all, nil
+}
+
+// GetOrdersIngestedAfter loads orders stored after t, used to bring a cache
+// snapshot up to date.
+func (r *OrderRepository) GetOrdersIngestedAfter(ctx context.Context, t time.Time) ([]order.Order, error) {
+	const op = "repository.order.GetOrdersIngestedAfter"
+
+	var all []order.Order
+	for _, shard := range r.shards {
+		var orders []order.Order
+		err := shard.DB.SelectContext(ctx, &orders, `SELECT * FROM orders WHERE ingested_at > $1`, t)
 		if err != nil {
-			return nil, fmt.Errorf("%s: %w", op, err)
+			return nil, fmt.Errorf("%s: shard %s: %w", op, shard.ID, err)
 		}
 
-		err = r.db.GetContext(ctx, &orders[i].Payment, `
-			SELECT * FROM payments WHERE order_uid = $1`, uid)
-		if err != nil {
-			return nil, fmt.Errorf("%s: %w", op, err)
+		for i := range orders {
+			if err := r.loadDetails(ctx, shard.DB, hotTables, &orders[i]); err != nil {
+				return nil, fmt.Errorf("%s: shard %s: %w", op, shard.ID, err)
+			}
 		}
+		all = append(all, orders...)
+	}
+	return all, nil
+}
+
+// RecentCutoff returns a date_created such that at least n orders were
+// created at or after it, or the zero time when there are fewer than n
+// orders. With several shards the cutoff may admit up to n per shard.
+func (r *OrderRepository) RecentCutoff(ctx context.Context, n int) (time.Time, error) {
+	const op = "repository.order.RecentCutoff"
 
-		err = r.db.SelectContext(ctx, &orders[i].Items, `
-			SELECT * FROM items WHERE order_uid = $1`, uid)
+	var cutoff time.Time
+	for _, shard := range r.shards {
+		var t time.Time
+		err := shard.DB.GetContext(ctx, &t, `
+			SELECT date_created FROM orders ORDER BY date_created DESC OFFSET $1 LIMIT 1`, n-1)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
 		if err != nil {
-			return nil, fmt.Errorf("%s: %w", op, err)
+			return time.Time{}, fmt.Errorf("%s: shard %s: %w", op, shard.ID, err)
+		}
+		if t.After(cutoff) {
+			cutoff = t
 		}
 	}
+	return cutoff, nil
+}
+
+// OrderVersions returns the current version of every stored order.
+func (r *OrderRepository) OrderVersions(ctx context.Context) (map[string]int, error) {
+	const op = "repository.order.OrderVersions"
 
-	return orders, nil
+	versions := make(map[string]int)
+	for _, shard := range r.shards {
+		rows, err := shard.DB.QueryContext(ctx, `SELECT order_uid, version FROM orders`)
+		if err != nil {
+			return nil, fmt.Errorf("%s: shard %s: %w", op, shard.ID, err)
+		}
+		for rows.Next() {
+			var uid string
+			var version int
+			if err := rows.Scan(&uid, &version); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("%s: shard %s: %w", op, shard.ID, err)
+			}
+			versions[uid] = version
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: shard %s: %w", op, shard.ID, err)
+		}
+	}
+	return versions, nil
 }
 
-var ErrOrderExists = errors.New("order already exists")
\ No newline at end of file
+var (
+	ErrOrderExists     = errors.New("order already exists")
+	ErrOrderNotFound   = errors.New("order not found")
+	ErrVersionConflict = errors.New("order version conflict")
+	// ErrOrderArchived is an ErrOrderExists for an order retention moved to
+	// the archive tables.
+	ErrOrderArchived = fmt.Errorf("%w in archive", ErrOrderExists)
+)
\ No newline at end of file
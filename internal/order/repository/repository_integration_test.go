@@ -0,0 +1,223 @@
+package repository
+
+import (
+	"context"
+	"math"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Egor-Pomidor-pdf/order-service/internal/fieldcrypt"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order/fixture"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/pgtest"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Тесты с настоящей БД пропускаются без TEST_POSTGRES_BIN, см. pgtest.
+func TestMain(m *testing.M) {
+	os.Exit(pgtest.Main(m))
+}
+
+var ctx = context.Background()
+
+func newFixture(opts fixture.Options) *fixture.Generator {
+	opts.Seed = 42
+	// заказы попадают в секции, созданные миграцией
+	opts.MaxAge = time.Hour
+	return fixture.New(opts)
+}
+
+// assertStored checks that got is want as the repository returns it.
+func assertStored(t *testing.T, want order.Order, got *order.Order) {
+	t.Helper()
+	require.NotNil(t, got)
+	require.NotNil(t, got.IngestedAt)
+	require.NotNil(t, want.IngestedAt)
+	assert.WithinDuration(t, *want.IngestedAt, *got.IngestedAt, time.Millisecond)
+
+	stored := *got
+	stored.IngestedAt, want.IngestedAt = nil, nil
+	stored.DateCreated = stored.DateCreated.UTC()
+	stored.Delivery.ID, stored.Delivery.OrderUID = 0, ""
+	stored.Payment.ID, stored.Payment.OrderUID = 0, ""
+	stored.Items = make([]order.Item, len(got.Items))
+	for i, it := range got.Items {
+		assert.Equal(t, want.OrderUID, it.OrderUID)
+		assert.True(t, it.DateCreated.Equal(want.DateCreated))
+		it.ID, it.OrderUID, it.DateCreated = 0, "", time.Time{}
+		stored.Items[i] = it
+	}
+	assert.Equal(t, want, stored)
+}
+
+// countRows returns how many rows of the order are left in each table.
+func countRows(t *testing.T, db *sqlx.DB, uid string) map[string]int {
+	t.Helper()
+	counts := make(map[string]int)
+	for _, table := range []string{"order_keys", "orders", "deliveries", "payments", "items"} {
+		var n int
+		require.NoError(t, db.Get(&n, `SELECT count(*) FROM `+table+` WHERE order_uid = $1`, uid))
+		counts[table] = n
+	}
+	return counts
+}
+
+func TestSaveOrder_RoundTrip(t *testing.T) {
+	db := pgtest.NewDB(t)
+	cipher, err := fieldcrypt.New(make([]byte, 32))
+	require.NoError(t, err)
+	repo := NewOrderRepository(db, WithFieldCipher(cipher))
+
+	o := newFixture(fixture.Options{MinItems: 3, MaxItems: 3}).Order()
+	require.NoError(t, repo.SaveOrder(ctx, &o))
+	assert.Equal(t, 1, o.Version)
+
+	got, err := repo.GetOrderByUID(ctx, o.OrderUID)
+	require.NoError(t, err)
+	assertStored(t, o, got)
+
+	var phone string
+	require.NoError(t, db.Get(&phone, `SELECT phone FROM deliveries WHERE order_uid = $1`, o.OrderUID))
+	assert.NotEqual(t, o.Delivery.Phone, phone, "encrypted at rest")
+
+	got, err = repo.GetOrderByUID(ctx, "missing")
+	require.NoError(t, err)
+	assert.Nil(t, got)
+}
+
+func TestSaveOrder_Duplicate(t *testing.T) {
+	repo := NewOrderRepository(pgtest.NewDB(t))
+	gen := newFixture(fixture.Options{})
+
+	o := gen.Order()
+	require.NoError(t, repo.SaveOrder(ctx, &o))
+
+	dup := gen.Order()
+	dup.OrderUID = o.OrderUID
+	assert.ErrorIs(t, repo.SaveOrder(ctx, &dup), ErrOrderExists)
+
+	got, err := repo.GetOrderByUID(ctx, o.OrderUID)
+	require.NoError(t, err)
+	assertStored(t, o, got)
+}
+
+func TestSaveOrder_RollbackOnFailure(t *testing.T) {
+	db := pgtest.NewDB(t)
+	repo := NewOrderRepository(db)
+
+	o := newFixture(fixture.Options{MinItems: 5, MaxItems: 5}).Order()
+	valid := o
+	valid.Items = append([]order.Item(nil), o.Items...)
+	// items.price - INT, последний товар не вставится после заказа, доставки и оплаты
+	o.Items[4].Price = math.MaxInt32 + 1
+
+	require.Error(t, repo.SaveOrder(ctx, &o))
+	for table, n := range countRows(t, db, o.OrderUID) {
+		assert.Zero(t, n, table)
+	}
+
+	require.NoError(t, repo.SaveOrder(ctx, &valid), "the failed attempt left no key behind")
+	got, err := repo.GetOrderByUID(ctx, valid.OrderUID)
+	require.NoError(t, err)
+	assertStored(t, valid, got)
+}
+
+func TestSaveOrder_LargeItemList(t *testing.T) {
+	repo := NewOrderRepository(pgtest.NewDB(t))
+
+	o := newFixture(fixture.Options{MinItems: 2000, MaxItems: 2000}).Order()
+	require.NoError(t, repo.SaveOrder(ctx, &o))
+
+	got, err := repo.GetOrderByUID(ctx, o.OrderUID)
+	require.NoError(t, err)
+	require.Len(t, got.Items, 2000)
+	assertStored(t, o, got)
+}
+
+func TestGetAllOrders(t *testing.T) {
+	repo := NewOrderRepository(pgtest.NewDB(t))
+	gen := newFixture(fixture.Options{MaxItems: 3})
+
+	want := make(map[string]order.Order)
+	for i := 0; i < 5; i++ {
+		o := gen.Order()
+		if i == 0 {
+			// вне созданных секций, попадает в orders_default
+			o.DateCreated = time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)
+		}
+		require.NoError(t, repo.SaveOrder(ctx, &o))
+		want[o.OrderUID] = o
+	}
+
+	all, err := repo.GetAllOrders(ctx)
+	require.NoError(t, err)
+	require.Len(t, all, len(want))
+	for i := range all {
+		assertStored(t, want[all[i].OrderUID], &all[i])
+	}
+}
+
+func TestSaveOrders_PartialFailure(t *testing.T) {
+	db := pgtest.NewDB(t)
+	repo := NewOrderRepository(db)
+	gen := newFixture(fixture.Options{MaxItems: 2})
+
+	existing := gen.Order()
+	require.NoError(t, repo.SaveOrder(ctx, &existing))
+
+	batch := []order.Order{gen.Order(), gen.Order(), gen.Order(), gen.Order()}
+	batch[1].OrderUID = existing.OrderUID
+	batch[2].Items[0].Price = math.MaxInt32 + 1
+	batch[3].OrderUID = batch[0].OrderUID
+
+	errs, err := repo.SaveOrders(ctx, batch)
+	require.NoError(t, err)
+	assert.NoError(t, errs[0])
+	assert.ErrorIs(t, errs[1], ErrOrderExists)
+	assert.Error(t, errs[2])
+	assert.ErrorIs(t, errs[3], ErrOrderExists)
+
+	got, err := repo.GetOrderByUID(ctx, batch[0].OrderUID)
+	require.NoError(t, err)
+	assertStored(t, batch[0], got)
+
+	got, err = repo.GetOrderByUID(ctx, existing.OrderUID)
+	require.NoError(t, err)
+	assertStored(t, existing, got)
+
+	for table, n := range countRows(t, db, batch[2].OrderUID) {
+		assert.Zero(t, n, table)
+	}
+}
+
+func TestSaveOrder_Sharded(t *testing.T) {
+	mainDB := pgtest.NewDB(t)
+	shards := []*Shard{{ID: "a", DB: pgtest.NewDB(t)}, {ID: "b", DB: pgtest.NewDB(t)}}
+	repo := NewOrderRepository(mainDB, WithShards(shards))
+	gen := newFixture(fixture.Options{})
+
+	o := gen.Order()
+	require.NoError(t, repo.SaveOrder(ctx, &o))
+	got, err := repo.GetOrderByUID(ctx, o.OrderUID)
+	require.NoError(t, err)
+	assertStored(t, o, got)
+
+	// другой shardkey не обходит проверку уникальности через справочник
+	dup := gen.Order()
+	dup.OrderUID = o.OrderUID
+	for dup.ShardKey = "0"; repo.shardFor(dup.ShardKey) == repo.shardFor(o.ShardKey); {
+		dup.ShardKey += "0"
+	}
+	assert.ErrorIs(t, repo.SaveOrder(ctx, &dup), ErrOrderExists)
+
+	// неудачная запись освобождает order_uid в справочнике
+	failed := gen.Order()
+	failed.Items[0].Price = math.MaxInt32 + 1
+	require.Error(t, repo.SaveOrder(ctx, &failed))
+	var claimed int
+	require.NoError(t, mainDB.Get(&claimed, `SELECT count(*) FROM order_shards WHERE order_uid = $1`, failed.OrderUID))
+	assert.Zero(t, claimed)
+}
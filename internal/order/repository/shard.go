@@ -0,0 +1,223 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sort"
+
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// DefaultShard is the id of the main database when sharding is off.
+const DefaultShard = "default"
+
+// Shard is a database holding the orders whose ShardKey maps to ID.
+type Shard struct {
+	ID string
+	DB *sqlx.DB
+}
+
+// WithShards spreads orders over shards by ShardKey. The main database keeps
+// the order_uid -> shard directory (order_shards) and the erasure audit. The
+// repository takes ownership of the shard connections, see Close.
+func WithShards(shards []*Shard) Option {
+	return func(r *OrderRepository) {
+		r.shards = shards
+	}
+}
+
+// Close closes the shard connections opened for WithShards. The main
+// database is left to its owner.
+func (r *OrderRepository) Close() error {
+	var errs []error
+	for _, shard := range r.shards {
+		if shard.DB != r.db {
+			errs = append(errs, shard.DB.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Shards returns the databases orders are stored in.
+func (r *OrderRepository) Shards() []*Shard {
+	return r.shards
+}
+
+func (r *OrderRepository) sharded() bool {
+	return len(r.shards) > 1 || r.shards[0].DB != r.db
+}
+
+// shardFor picks the shard of a ShardKey by rendezvous hashing: the shard
+// with the highest hash of (shard id, key) wins. Adding a shard only moves
+// the keys the new shard wins, and the order of shards in the config does
+// not matter.
+func (r *OrderRepository) shardFor(shardKey string) *Shard {
+	return pickShard(r.shards, shardKey)
+}
+
+func pickShard(shards []*Shard, shardKey string) *Shard {
+	var best *Shard
+	var bestScore uint64
+	for _, shard := range shards {
+		h := fnv.New64a()
+		h.Write([]byte(shard.ID))
+		h.Write([]byte{0})
+		h.Write([]byte(shardKey))
+		if score := mix(h.Sum64()); best == nil || score > bestScore {
+			best, bestScore = shard, score
+		}
+	}
+	return best
+}
+
+// mix is the splitmix64 finalizer. FNV alone leaves scores of similar
+// shard ids correlated, which skews the distribution.
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+func (r *OrderRepository) shardByID(id string) (*Shard, error) {
+	for _, shard := range r.shards {
+		if shard.ID == id {
+			return shard, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown shard %q", id)
+}
+
+// claim records the shard of a new order in the directory. The directory
+// primary key keeps order_uid unique across shards.
+func (r *OrderRepository) claim(ctx context.Context, uid string, shard *Shard) error {
+	if !r.sharded() {
+		return nil
+	}
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO order_shards (order_uid, shard) VALUES ($1, $2)`, uid, shard.ID)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return ErrOrderExists
+		}
+		return err
+	}
+	return nil
+}
+
+// release removes orders from the directory.
+func (r *OrderRepository) release(ctx context.Context, uids ...string) error {
+	if !r.sharded() {
+		return nil
+	}
+	_, err := r.db.ExecContext(ctx, `DELETE FROM order_shards WHERE order_uid = ANY($1)`, pq.Array(uids))
+	return err
+}
+
+// assign points the directory entry of an order at shard and reports
+// whether it changed.
+func (r *OrderRepository) assign(ctx context.Context, uid string, shard *Shard) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `
+		INSERT INTO order_shards (order_uid, shard) VALUES ($1, $2)
+		ON CONFLICT (order_uid) DO UPDATE SET shard = EXCLUDED.shard
+		WHERE order_shards.shard <> EXCLUDED.shard`, uid, shard.ID)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// locate finds the shard holding an order, nil if no shard has it. Orders
+// missing from the directory (written before sharding was enabled and not
+// yet rebalanced) are searched for on every shard.
+func (r *OrderRepository) locate(ctx context.Context, uid string) (*Shard, error) {
+	if !r.sharded() {
+		return r.shards[0], nil
+	}
+
+	var id string
+	err := r.db.GetContext(ctx, &id, `SELECT shard FROM order_shards WHERE order_uid = $1`, uid)
+	if err == nil {
+		return r.shardByID(id)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	for _, shard := range r.shards {
+		var exists bool
+		err := shard.DB.GetContext(ctx, &exists, `SELECT EXISTS (SELECT 1 FROM order_keys WHERE order_uid = $1)`, uid)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			return shard, nil
+		}
+	}
+	return nil, nil
+}
+
+// locateAll groups uids by the shard holding them. Unknown uids are dropped.
+func (r *OrderRepository) locateAll(ctx context.Context, uids []string) (map[*Shard][]string, error) {
+	if !r.sharded() {
+		return map[*Shard][]string{r.shards[0]: uids}, nil
+	}
+
+	var entries []struct {
+		UID   string `db:"order_uid"`
+		Shard string `db:"shard"`
+	}
+	err := r.db.SelectContext(ctx, &entries, `
+		SELECT order_uid, shard FROM order_shards WHERE order_uid = ANY($1)`, pq.Array(uids))
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make(map[*Shard][]string)
+	known := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		shard, err := r.shardByID(e.Shard)
+		if err != nil {
+			return nil, err
+		}
+		groups[shard] = append(groups[shard], e.UID)
+		known[e.UID] = true
+	}
+
+	var unknown []string
+	for _, uid := range uids {
+		if !known[uid] {
+			unknown = append(unknown, uid)
+		}
+	}
+	if len(unknown) == 0 {
+		return groups, nil
+	}
+	for _, shard := range r.shards {
+		var found []string
+		err := shard.DB.SelectContext(ctx, &found, `
+			SELECT order_uid FROM order_keys WHERE order_uid = ANY($1)`, pq.Array(unknown))
+		if err != nil {
+			return nil, err
+		}
+		if len(found) > 0 {
+			groups[shard] = append(groups[shard], found...)
+		}
+	}
+	return groups, nil
+}
+
+func sortByDateCreated(orders []order.Order) {
+	sort.SliceStable(orders, func(i, j int) bool {
+		return orders[i].DateCreated.Before(orders[j].DateCreated)
+	})
+}
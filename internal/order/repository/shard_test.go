@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func shards(ids ...string) []*Shard {
+	s := make([]*Shard, len(ids))
+	for i, id := range ids {
+		s[i] = &Shard{ID: id}
+	}
+	return s
+}
+
+func TestPickShard(t *testing.T) {
+	three := shards("a", "b", "c")
+	reordered := []*Shard{three[2], three[0], three[1]}
+
+	counts := make(map[string]int)
+	for i := 0; i < 3000; i++ {
+		key := fmt.Sprint(i)
+		shard := pickShard(three, key)
+		counts[shard.ID]++
+
+		assert.Same(t, shard, pickShard(three, key), "same key must map to the same shard")
+		assert.Same(t, shard, pickShard(reordered, key), "config order must not matter")
+	}
+
+	for id, n := range counts {
+		assert.InDelta(t, 1000, n, 150, "shard %s is unbalanced", id)
+	}
+}
+
+func TestPickShard_AddShard(t *testing.T) {
+	before := shards("a", "b", "c")
+	after := append(shards("a", "b", "c"), &Shard{ID: "d"})
+
+	moved := 0
+	for i := 0; i < 3000; i++ {
+		key := fmt.Sprint(i)
+		old, cur := pickShard(before, key), pickShard(after, key)
+		if old.ID != cur.ID {
+			assert.Equal(t, "d", cur.ID, "keys may only move to the new shard")
+			moved++
+		}
+	}
+	assert.InDelta(t, 750, moved, 150)
+}
@@ -0,0 +1,142 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// OrderFilter narrows StreamOrders. Zero fields do not filter.
+type OrderFilter struct {
+	From        time.Time
+	To          time.Time
+	CustomerID  string
+	TrackNumber string
+}
+
+// StreamOrders calls fn for every order matching f, oldest first within a
+// shard. Orders are read through a server-side cursor batchSize at a time,
+// so memory use does not grow with the number of orders.
+func (r *OrderRepository) StreamOrders(ctx context.Context, f OrderFilter, batchSize int, fn func(*order.Order) error) error {
+	const op = "repository.order.StreamOrders"
+
+	for _, shard := range r.shards {
+		if err := r.streamShard(ctx, shard.DB, f, batchSize, fn); err != nil {
+			return fmt.Errorf("%s: shard %s: %w", op, shard.ID, err)
+		}
+	}
+	return nil
+}
+
+func (r *OrderRepository) streamShard(ctx context.Context, db *sqlx.DB, f OrderFilter, batchSize int, fn func(*order.Order) error) error {
+	tx, err := db.BeginTxx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	where, args := f.where()
+	_, err = tx.ExecContext(ctx, `
+		DECLARE orders_export NO SCROLL CURSOR FOR
+		SELECT * FROM orders`+where+` ORDER BY date_created`, args...)
+	if err != nil {
+		return err
+	}
+
+	fetch := fmt.Sprintf(`FETCH FORWARD %d FROM orders_export`, batchSize)
+	for {
+		var orders []order.Order
+		if err := tx.SelectContext(ctx, &orders, fetch); err != nil {
+			return err
+		}
+		if len(orders) == 0 {
+			return nil
+		}
+
+		if err := r.loadBatchDetails(ctx, tx, orders); err != nil {
+			return err
+		}
+		for i := range orders {
+			if err := fn(&orders[i]); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (f OrderFilter) where() (string, []any) {
+	var conds []string
+	var args []any
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+	if !f.From.IsZero() {
+		add("date_created >= $%d", f.From)
+	}
+	if !f.To.IsZero() {
+		add("date_created < $%d", f.To)
+	}
+	if f.CustomerID != "" {
+		add("customer_id = $%d", f.CustomerID)
+	}
+	if f.TrackNumber != "" {
+		add("track_number = $%d", f.TrackNumber)
+	}
+	if len(conds) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+// loadBatchDetails fills delivery, payment and items of orders with one
+// query per table.
+func (r *OrderRepository) loadBatchDetails(ctx context.Context, tx *sqlx.Tx, orders []order.Order) error {
+	uids := make([]string, len(orders))
+	byUID := make(map[string]*order.Order, len(orders))
+	for i := range orders {
+		uids[i] = orders[i].OrderUID
+		byUID[orders[i].OrderUID] = &orders[i]
+	}
+
+	var deliveries []order.Delivery
+	err := tx.SelectContext(ctx, &deliveries, `SELECT * FROM deliveries WHERE order_uid = ANY($1)`, pq.Array(uids))
+	if err != nil {
+		return err
+	}
+	for _, d := range deliveries {
+		if err := r.cipher.OpenDelivery(&d); err != nil {
+			return err
+		}
+		byUID[d.OrderUID].Delivery = d
+	}
+
+	var payments []order.Payment
+	err = tx.SelectContext(ctx, &payments, `SELECT * FROM payments WHERE order_uid = ANY($1)`, pq.Array(uids))
+	if err != nil {
+		return err
+	}
+	for _, p := range payments {
+		byUID[p.OrderUID].Payment = p
+	}
+
+	// orders идут по date_created, поэтому товары пачки лежат в соседних секциях
+	var items []order.Item
+	err = tx.SelectContext(ctx, &items, `
+		SELECT * FROM items WHERE order_uid = ANY($1) AND date_created BETWEEN $2 AND $3
+		ORDER BY id`, pq.Array(uids), orders[0].DateCreated, orders[len(orders)-1].DateCreated)
+	if err != nil {
+		return err
+	}
+	for _, it := range items {
+		o := byUID[it.OrderUID]
+		o.Items = append(o.Items, it)
+	}
+	return nil
+}
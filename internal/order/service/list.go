@@ -0,0 +1,62 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order/repository"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order/watch"
+)
+
+const listBatchSize = 500
+
+var errEnough = errors.New("enough orders")
+
+// ListOrders calls fn for every stored order matching f. The orders are
+// read from the database, not from the cache.
+func (s *OrderService) ListOrders(ctx context.Context, f repository.OrderFilter, fn func(*order.Order) error) error {
+	return s.repo.StreamOrders(ctx, f, listBatchSize, fn)
+}
+
+// SearchOrders returns up to limit stored orders matching f, limit must be
+// positive.
+func (s *OrderService) SearchOrders(ctx context.Context, f repository.OrderFilter, limit int) ([]order.Order, error) {
+	orders := []order.Order{}
+	err := s.repo.StreamOrders(ctx, f, min(limit, listBatchSize), func(o *order.Order) error {
+		orders = append(orders, *o)
+		if len(orders) == limit {
+			return errEnough
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errEnough) {
+		return nil, err
+	}
+	return orders, nil
+}
+
+// WithWatchHistory keeps the last n ingested orders for watchers resuming
+// with WatchAfter.
+func WithWatchHistory(n int) Option {
+	return func(s *OrderService) {
+		s.watchHistory = n
+	}
+}
+
+// Watch subscribes to the orders ProcessOrder saves from now on, see
+// watch.Hub. The caller closes the subscription.
+func (s *OrderService) Watch(buffer int, match func(*order.Order) bool) *watch.Subscription {
+	return s.hub.Subscribe(buffer, match)
+}
+
+// WatchAfter is Watch resuming after the event seq, see
+// watch.Hub.SubscribeAfter.
+func (s *OrderService) WatchAfter(seq uint64, buffer int, match func(*order.Order) bool) (*watch.Subscription, bool) {
+	return s.hub.SubscribeAfter(seq, buffer, match)
+}
+
+// CloseWatchers ends every Watch subscription, so streams finish on shutdown.
+func (s *OrderService) CloseWatchers() {
+	s.hub.Close()
+}
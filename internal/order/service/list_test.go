@@ -0,0 +1,49 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order/repository"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchOrders_Limit(t *testing.T) {
+	s, repo, _ := newService(t)
+	f := repository.OrderFilter{TrackNumber: "WB1"}
+	repo.EXPECT().StreamOrders(gomock.Any(), f, 2, gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ repository.OrderFilter, _ int, fn func(*order.Order) error) error {
+			for _, uid := range []string{"a1", "a2", "a3"} {
+				o := testOrder(uid, 1)
+				if err := fn(&o); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+
+	got, err := s.SearchOrders(ctx, f, 2)
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	assert.Equal(t, "a2", got[1].OrderUID)
+}
+
+func TestProcessOrder_Watch(t *testing.T) {
+	s, repo, _ := newService(t)
+	repo.EXPECT().SaveOrder(gomock.Any(), gomock.Any()).Return(nil).Times(2)
+	sub := s.Watch(10, func(o *order.Order) bool { return o.OrderUID != "skip" })
+	defer sub.Close()
+
+	_, err := s.ProcessOrder(ctx, testOrder("skip", 0))
+	require.NoError(t, err)
+	_, err = s.ProcessOrder(ctx, testOrder("a1", 0))
+	require.NoError(t, err)
+	assert.Equal(t, "a1", (<-sub.Events()).Order.OrderUID)
+
+	s.CloseWatchers()
+	_, open := <-sub.Events()
+	assert.False(t, open)
+}
@@ -35,6 +35,35 @@ func (m *MockOrderServiceInterface) EXPECT() *MockOrderServiceInterfaceMockRecor
 	return m.recorder
 }
 
+// DeleteOrder mocks base method.
+func (m *MockOrderServiceInterface) DeleteOrder(ctx context.Context, uid string, expectedVersion int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteOrder", ctx, uid, expectedVersion)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteOrder indicates an expected call of DeleteOrder.
+func (mr *MockOrderServiceInterfaceMockRecorder) DeleteOrder(ctx, uid, expectedVersion interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteOrder", reflect.TypeOf((*MockOrderServiceInterface)(nil).DeleteOrder), ctx, uid, expectedVersion)
+}
+
+// EraseCustomer mocks base method.
+func (m *MockOrderServiceInterface) EraseCustomer(ctx context.Context, customerID, requestedBy, source string) (*order.Erasure, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EraseCustomer", ctx, customerID, requestedBy, source)
+	ret0, _ := ret[0].(*order.Erasure)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EraseCustomer indicates an expected call of EraseCustomer.
+func (mr *MockOrderServiceInterfaceMockRecorder) EraseCustomer(ctx, customerID, requestedBy, source interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EraseCustomer", reflect.TypeOf((*MockOrderServiceInterface)(nil).EraseCustomer), ctx, customerID, requestedBy, source)
+}
+
 // GetOrder mocks base method.
 func (m *MockOrderServiceInterface) GetOrder(ctx context.Context, uid string) (*order.Order, error) {
 	m.ctrl.T.Helper()
@@ -50,16 +79,47 @@ func (mr *MockOrderServiceInterfaceMockRecorder) GetOrder(ctx, uid interface{})
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrder", reflect.TypeOf((*MockOrderServiceInterface)(nil).GetOrder), ctx, uid)
 }
 
+// OrderExists mocks base method.
+func (m *MockOrderServiceInterface) OrderExists(ctx context.Context, uid string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "OrderExists", ctx, uid)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// OrderExists indicates an expected call of OrderExists.
+func (mr *MockOrderServiceInterfaceMockRecorder) OrderExists(ctx, uid interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OrderExists", reflect.TypeOf((*MockOrderServiceInterface)(nil).OrderExists), ctx, uid)
+}
+
 // ProcessOrder mocks base method.
-func (m *MockOrderServiceInterface) ProcessOrder(ctx context.Context, order order.Order) error {
+func (m *MockOrderServiceInterface) ProcessOrder(ctx context.Context, created order.Order) (*order.Order, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ProcessOrder", ctx, order)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret := m.ctrl.Call(m, "ProcessOrder", ctx, created)
+	ret0, _ := ret[0].(*order.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // ProcessOrder indicates an expected call of ProcessOrder.
-func (mr *MockOrderServiceInterfaceMockRecorder) ProcessOrder(ctx, order interface{}) *gomock.Call {
+func (mr *MockOrderServiceInterfaceMockRecorder) ProcessOrder(ctx, created interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProcessOrder", reflect.TypeOf((*MockOrderServiceInterface)(nil).ProcessOrder), ctx, created)
+}
+
+// UpdateOrder mocks base method.
+func (m *MockOrderServiceInterface) UpdateOrder(ctx context.Context, updated order.Order, expectedVersion int) (*order.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateOrder", ctx, updated, expectedVersion)
+	ret0, _ := ret[0].(*order.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateOrder indicates an expected call of UpdateOrder.
+func (mr *MockOrderServiceInterfaceMockRecorder) UpdateOrder(ctx, updated, expectedVersion interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProcessOrder", reflect.TypeOf((*MockOrderServiceInterface)(nil).ProcessOrder), ctx, order)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateOrder", reflect.TypeOf((*MockOrderServiceInterface)(nil).UpdateOrder), ctx, updated, expectedVersion)
 }
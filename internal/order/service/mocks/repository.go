@@ -0,0 +1,185 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: repository.go
+
+// Package mock_service is a generated GoMock package.
+package mock_service
+
+import (
+	context "context"
+	reflect "reflect"
+	time "time"
+
+	order "github.com/Egor-Pomidor-pdf/order-service/internal/order"
+	repository "github.com/Egor-Pomidor-pdf/order-service/internal/order/repository"
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockRepository is a mock of Repository interface.
+type MockRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockRepositoryMockRecorder
+}
+
+// MockRepositoryMockRecorder is the mock recorder for MockRepository.
+type MockRepositoryMockRecorder struct {
+	mock *MockRepository
+}
+
+// NewMockRepository creates a new mock instance.
+func NewMockRepository(ctrl *gomock.Controller) *MockRepository {
+	mock := &MockRepository{ctrl: ctrl}
+	mock.recorder = &MockRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockRepository) EXPECT() *MockRepositoryMockRecorder {
+	return m.recorder
+}
+
+// DeleteOrder mocks base method.
+func (m *MockRepository) DeleteOrder(ctx context.Context, uid string, expectedVersion int) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteOrder", ctx, uid, expectedVersion)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteOrder indicates an expected call of DeleteOrder.
+func (mr *MockRepositoryMockRecorder) DeleteOrder(ctx, uid, expectedVersion interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteOrder", reflect.TypeOf((*MockRepository)(nil).DeleteOrder), ctx, uid, expectedVersion)
+}
+
+// EraseCustomer mocks base method.
+func (m *MockRepository) EraseCustomer(ctx context.Context, customerID, requestedBy, source string) (*order.Erasure, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EraseCustomer", ctx, customerID, requestedBy, source)
+	ret0, _ := ret[0].(*order.Erasure)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EraseCustomer indicates an expected call of EraseCustomer.
+func (mr *MockRepositoryMockRecorder) EraseCustomer(ctx, customerID, requestedBy, source interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EraseCustomer", reflect.TypeOf((*MockRepository)(nil).EraseCustomer), ctx, customerID, requestedBy, source)
+}
+
+// GetArchivedOrderByUID mocks base method.
+func (m *MockRepository) GetArchivedOrderByUID(ctx context.Context, uid string) (*order.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetArchivedOrderByUID", ctx, uid)
+	ret0, _ := ret[0].(*order.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetArchivedOrderByUID indicates an expected call of GetArchivedOrderByUID.
+func (mr *MockRepositoryMockRecorder) GetArchivedOrderByUID(ctx, uid interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetArchivedOrderByUID", reflect.TypeOf((*MockRepository)(nil).GetArchivedOrderByUID), ctx, uid)
+}
+
+// GetOrderByUID mocks base method.
+func (m *MockRepository) GetOrderByUID(ctx context.Context, uid string) (*order.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOrderByUID", ctx, uid)
+	ret0, _ := ret[0].(*order.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOrderByUID indicates an expected call of GetOrderByUID.
+func (mr *MockRepositoryMockRecorder) GetOrderByUID(ctx, uid interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrderByUID", reflect.TypeOf((*MockRepository)(nil).GetOrderByUID), ctx, uid)
+}
+
+// GetOrdersIngestedAfter mocks base method.
+func (m *MockRepository) GetOrdersIngestedAfter(ctx context.Context, t time.Time) ([]order.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOrdersIngestedAfter", ctx, t)
+	ret0, _ := ret[0].([]order.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOrdersIngestedAfter indicates an expected call of GetOrdersIngestedAfter.
+func (mr *MockRepositoryMockRecorder) GetOrdersIngestedAfter(ctx, t interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrdersIngestedAfter", reflect.TypeOf((*MockRepository)(nil).GetOrdersIngestedAfter), ctx, t)
+}
+
+// OrderVersions mocks base method.
+func (m *MockRepository) OrderVersions(ctx context.Context) (map[string]int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "OrderVersions", ctx)
+	ret0, _ := ret[0].(map[string]int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// OrderVersions indicates an expected call of OrderVersions.
+func (mr *MockRepositoryMockRecorder) OrderVersions(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OrderVersions", reflect.TypeOf((*MockRepository)(nil).OrderVersions), ctx)
+}
+
+// RecentCutoff mocks base method.
+func (m *MockRepository) RecentCutoff(ctx context.Context, n int) (time.Time, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecentCutoff", ctx, n)
+	ret0, _ := ret[0].(time.Time)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RecentCutoff indicates an expected call of RecentCutoff.
+func (mr *MockRepositoryMockRecorder) RecentCutoff(ctx, n interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecentCutoff", reflect.TypeOf((*MockRepository)(nil).RecentCutoff), ctx, n)
+}
+
+// SaveOrder mocks base method.
+func (m *MockRepository) SaveOrder(ctx context.Context, order *order.Order) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveOrder", ctx, order)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveOrder indicates an expected call of SaveOrder.
+func (mr *MockRepositoryMockRecorder) SaveOrder(ctx, order interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveOrder", reflect.TypeOf((*MockRepository)(nil).SaveOrder), ctx, order)
+}
+
+// StreamOrders mocks base method.
+func (m *MockRepository) StreamOrders(ctx context.Context, f repository.OrderFilter, batchSize int, fn func(*order.Order) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StreamOrders", ctx, f, batchSize, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// StreamOrders indicates an expected call of StreamOrders.
+func (mr *MockRepositoryMockRecorder) StreamOrders(ctx, f, batchSize, fn interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StreamOrders", reflect.TypeOf((*MockRepository)(nil).StreamOrders), ctx, f, batchSize, fn)
+}
+
+// UpdateOrder mocks base method.
+func (m *MockRepository) UpdateOrder(ctx context.Context, order *order.Order, expectedVersion int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateOrder", ctx, order, expectedVersion)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateOrder indicates an expected call of UpdateOrder.
+func (mr *MockRepositoryMockRecorder) UpdateOrder(ctx, order, expectedVersion interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateOrder", reflect.TypeOf((*MockRepository)(nil).UpdateOrder), ctx, order, expectedVersion)
+}
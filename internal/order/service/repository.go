@@ -0,0 +1,30 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order/repository"
+)
+
+//go:generate mockgen -source=repository.go -destination=mocks/repository.go -package=mock_service
+
+// Repository is the order storage the service works with, implemented by
+// repository.OrderRepository.
+type Repository interface {
+	SaveOrder(ctx context.Context, order *order.Order) error
+	GetOrderByUID(ctx context.Context, uid string) (*order.Order, error)
+	GetArchivedOrderByUID(ctx context.Context, uid string) (*order.Order, error)
+	UpdateOrder(ctx context.Context, order *order.Order, expectedVersion int) error
+	DeleteOrder(ctx context.Context, uid string, expectedVersion int) (int, error)
+	EraseCustomer(ctx context.Context, customerID, requestedBy, source string) (*order.Erasure, error)
+
+	// прогрев и снимок кеша
+	StreamOrders(ctx context.Context, f repository.OrderFilter, batchSize int, fn func(*order.Order) error) error
+	RecentCutoff(ctx context.Context, n int) (time.Time, error)
+	OrderVersions(ctx context.Context) (map[string]int, error)
+	GetOrdersIngestedAfter(ctx context.Context, t time.Time) ([]order.Order, error)
+}
+
+var _ Repository = (*repository.OrderRepository)(nil)
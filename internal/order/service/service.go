@@ -4,67 +4,81 @@ import (
 	"context"
 	"errors"
 	"log/slog"
-	"sync"
 
+	"github.com/Egor-Pomidor-pdf/order-service/internal/fieldcrypt"
 	"github.com/Egor-Pomidor-pdf/order-service/internal/order"
-	"github.com/Egor-Pomidor-pdf/order-service/internal/order/repository"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order/cache"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order/watch"
 )
 
 //go:generate mockgen -source=service.go -destination=mocks/mock.go
 
 type OrderServiceInterface interface {
-	ProcessOrder(ctx context.Context, order order.Order) error
+	ProcessOrder(ctx context.Context, created order.Order) (*order.Order, error)
 	GetOrder(ctx context.Context, uid string) (*order.Order, error)
+	OrderExists(ctx context.Context, uid string) (bool, error)
+	UpdateOrder(ctx context.Context, updated order.Order, expectedVersion int) (*order.Order, error)
+	DeleteOrder(ctx context.Context, uid string, expectedVersion int) error
+	EraseCustomer(ctx context.Context, customerID, requestedBy, source string) (*order.Erasure, error)
 }
 
 type OrderService struct {
-	repo  repository.OrderRepository
-	cache map[string]order.Order
-	mu    sync.RWMutex
+	repo  Repository
+	cache cache.Cache
+	hub   *watch.Hub
+
+	archiveFallback bool
+	snapshotPath    string
+	snapshotCipher  *fieldcrypt.Cipher
+	watchHistory    int
+	warmUp          warmUpState
 }
 
-func NewOrderService(repo *repository.OrderRepository) *OrderService {
-	service := &OrderService{
-		repo:  *repo,
-		cache: make(map[string]order.Order),
+type Option func(*OrderService)
+
+// WithArchiveFallback makes GetOrder look in the archive tables for orders
+// that were moved out of the hot tables by retention.
+func WithArchiveFallback() Option {
+	return func(s *OrderService) {
+		s.archiveFallback = true
 	}
-	service.restoreCache(context.Background())
-	return service
 }
 
-func (s *OrderService) restoreCache(ctx context.Context) {
-	orders, err := s.repo.GetAllOrders(ctx)
-	if err != nil {
-		return
+// WithCache replaces the default in-memory cache, e.g. with a cache.Tiered.
+func WithCache(c cache.Cache) Option {
+	return func(s *OrderService) {
+		s.cache = c
 	}
+}
 
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	for _, order := range orders {
-		s.cache[order.OrderUID] = order
+func NewOrderService(repo Repository, opts ...Option) *OrderService {
+	service := &OrderService{
+		repo:  repo,
+		cache: cache.NewMemory(0),
 	}
-	slog.Info("Restore cache")
+	for _, opt := range opts {
+		opt(service)
+	}
+	service.hub = watch.NewHub(service.watchHistory)
+	return service
 }
 
-
-func (s *OrderService) ProcessOrder(ctx context.Context, order order.Order) error {
+// ProcessOrder stores a new order and returns it with the version assigned
+// by the repository.
+func (s *OrderService) ProcessOrder(ctx context.Context, order order.Order) (*order.Order, error) {
 	if err := s.repo.SaveOrder(ctx, &order); err != nil {
-		return err
+		return nil, err
 	}
 
-	s.mu.Lock()
-	s.cache[order.OrderUID] = order
-	s.mu.Unlock()
+	s.cache.Set(ctx, order)
+	s.hub.Publish(order)
 
-	return nil
+	return &order, nil
 }
 
 func (s *OrderService) GetOrder(ctx context.Context, uid string) (*order.Order, error) {
 	// Проверяем кэш
-	s.mu.RLock()
-	cachedOrder, exists := s.cache[uid]
-	s.mu.RUnlock()
-
+	cachedOrder, exists := s.cache.Get(ctx, uid)
 	if exists {
 		slog.Info(" Order from cache\n","uid", uid)
 		return &cachedOrder, nil
@@ -77,12 +91,92 @@ func (s *OrderService) GetOrder(ctx context.Context, uid string) (*order.Order,
 		return nil, errors.New("Service Error")
 	}
 
+	// Архивные заказы не кешируем, к ним обращаются редко
+	if order == nil && s.archiveFallback {
+		archived, err := s.repo.GetArchivedOrderByUID(ctx, uid)
+		if err != nil {
+			return nil, errors.New("Service Error")
+		}
+		if archived != nil {
+			slog.Info(" Order from archive\n", "uid", uid)
+		}
+		return archived, nil
+	}
+
 	// Обновляем кэш
 	if order != nil {
-		s.mu.Lock()
-		s.cache[order.OrderUID] = *order
-		s.mu.Unlock()
+		s.cache.Set(ctx, *order)
 	}
 
 	return order, nil
-}
\ No newline at end of file
+}
+
+// OrderExists reports whether uid is stored. It reads the database and
+// leaves the cache alone, so a dry run does not warm it up.
+func (s *OrderService) OrderExists(ctx context.Context, uid string) (bool, error) {
+	order, err := s.repo.GetOrderByUID(ctx, uid)
+	if err != nil {
+		return false, err
+	}
+	return order != nil, nil
+}
+
+// UpdateOrder replaces a stored order if its version still equals
+// expectedVersion and refreshes the cache with the new version.
+func (s *OrderService) UpdateOrder(ctx context.Context, order order.Order, expectedVersion int) (*order.Order, error) {
+	if err := s.repo.UpdateOrder(ctx, &order, expectedVersion); err != nil {
+		return nil, err
+	}
+
+	s.cache.Set(ctx, order)
+
+	return &order, nil
+}
+
+// DeleteOrder removes a stored order if its version still equals
+// expectedVersion and evicts it from the cache. The cache is invalidated
+// with the version of the removal, so a copy read before it is not stored
+// again.
+func (s *OrderService) DeleteOrder(ctx context.Context, uid string, expectedVersion int) error {
+	version, err := s.repo.DeleteOrder(ctx, uid, expectedVersion)
+	if err != nil {
+		return err
+	}
+
+	s.cache.Invalidate(ctx, uid, version)
+
+	return nil
+}
+
+// EraseCustomer anonymizes all orders of a customer and evicts them from the
+// cache so no copy of the personal data is served afterwards.
+func (s *OrderService) EraseCustomer(ctx context.Context, customerID, requestedBy, source string) (*order.Erasure, error) {
+	erasure, err := s.repo.EraseCustomer(ctx, customerID, requestedBy, source)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, uid := range erasure.OrderUIDs {
+		s.cache.Invalidate(ctx, uid, erasure.Versions[uid])
+	}
+
+	slog.Info("customer data erased", "customer_hash", erasure.CustomerHash, "orders", erasure.Orders, "source", source)
+	return erasure, nil
+}
+
+// Evict drops orders from the cache, e.g. after retention removed them.
+func (s *OrderService) Evict(uids ...string) {
+	s.cache.Delete(context.Background(), uids...)
+}
+
+// Invalidate applies a change made by any replica: the cached copy is
+// dropped unless it already has version or a newer one, which also skips the
+// notifications of this replica's own writes. Version 0 always drops it.
+func (s *OrderService) Invalidate(uid string, version int) {
+	s.cache.Invalidate(context.Background(), uid, version)
+}
+
+// Reset empties the cache, used when change notifications may have been lost.
+func (s *OrderService) Reset() {
+	s.cache.Reset(context.Background())
+}
@@ -0,0 +1,167 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order/cache"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order/repository"
+	mock_service "github.com/Egor-Pomidor-pdf/order-service/internal/order/service/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var ctx = context.Background()
+
+func testOrder(uid string, version int) order.Order {
+	return order.Order{
+		OrderUID:    uid,
+		CustomerID:  "test",
+		DateCreated: time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+		Payment:     order.Payment{Currency: "USD", Amount: 1817},
+		Items:       []order.Item{{ChrtID: 9934930, Name: "Mascaras", TotalPrice: 317}},
+		Version:     version,
+	}
+}
+
+func newService(t *testing.T, opts ...Option) (*OrderService, *mock_service.MockRepository, *cache.Memory) {
+	t.Helper()
+	repo := mock_service.NewMockRepository(gomock.NewController(t))
+	memory := cache.NewMemory(0)
+	return NewOrderService(repo, append([]Option{WithCache(memory)}, opts...)...), repo, memory
+}
+
+func TestGetOrder_CacheHit(t *testing.T) {
+	s, _, memory := newService(t)
+	memory.Set(ctx, testOrder("a1", 1))
+
+	got, err := s.GetOrder(ctx, "a1")
+	require.NoError(t, err)
+	assert.Equal(t, "a1", got.OrderUID)
+}
+
+func TestGetOrder_CacheMiss(t *testing.T) {
+	s, repo, _ := newService(t)
+	o := testOrder("a1", 1)
+	repo.EXPECT().GetOrderByUID(gomock.Any(), "a1").Return(&o, nil).Times(1)
+
+	got, err := s.GetOrder(ctx, "a1")
+	require.NoError(t, err)
+	assert.Equal(t, o, *got)
+
+	// второе чтение идет из кеша
+	got, err = s.GetOrder(ctx, "a1")
+	require.NoError(t, err)
+	assert.Equal(t, o, *got)
+}
+
+func TestGetOrder_NotFound(t *testing.T) {
+	s, repo, memory := newService(t)
+	repo.EXPECT().GetOrderByUID(gomock.Any(), "a1").Return(nil, nil)
+
+	got, err := s.GetOrder(ctx, "a1")
+	require.NoError(t, err)
+	assert.Nil(t, got)
+	assert.Zero(t, memory.Len())
+}
+
+func TestGetOrder_RepositoryError(t *testing.T) {
+	s, repo, _ := newService(t)
+	repo.EXPECT().GetOrderByUID(gomock.Any(), "a1").Return(nil, errors.New("connection refused"))
+
+	_, err := s.GetOrder(ctx, "a1")
+	assert.EqualError(t, err, "Service Error")
+}
+
+func TestGetOrder_ArchiveFallback(t *testing.T) {
+	s, repo, memory := newService(t, WithArchiveFallback())
+	o := testOrder("a1", 1)
+	repo.EXPECT().GetOrderByUID(gomock.Any(), "a1").Return(nil, nil)
+	repo.EXPECT().GetArchivedOrderByUID(gomock.Any(), "a1").Return(&o, nil)
+
+	got, err := s.GetOrder(ctx, "a1")
+	require.NoError(t, err)
+	assert.Equal(t, o, *got)
+	assert.Zero(t, memory.Len(), "archived orders are not cached")
+}
+
+func TestProcessOrder(t *testing.T) {
+	s, repo, memory := newService(t)
+	repo.EXPECT().SaveOrder(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, o *order.Order) error {
+		o.Version = 1
+		return nil
+	})
+
+	created, err := s.ProcessOrder(ctx, testOrder("a1", 0))
+	require.NoError(t, err)
+	assert.Equal(t, 1, created.Version)
+	got, ok := memory.Get(ctx, "a1")
+	require.True(t, ok)
+	assert.Equal(t, 1, got.Version, "the stored version is cached")
+}
+
+func TestProcessOrder_Error(t *testing.T) {
+	s, repo, memory := newService(t)
+	repo.EXPECT().SaveOrder(gomock.Any(), gomock.Any()).Return(repository.ErrOrderExists)
+
+	_, err := s.ProcessOrder(ctx, testOrder("a1", 0))
+	assert.ErrorIs(t, err, repository.ErrOrderExists)
+	assert.Zero(t, memory.Len())
+}
+
+func TestUpdateOrder(t *testing.T) {
+	s, repo, memory := newService(t)
+	memory.Set(ctx, testOrder("a1", 1))
+
+	repo.EXPECT().UpdateOrder(gomock.Any(), gomock.Any(), 1).DoAndReturn(func(_ context.Context, o *order.Order, _ int) error {
+		o.Version = 2
+		return nil
+	})
+	updated, err := s.UpdateOrder(ctx, testOrder("a1", 1), 1)
+	require.NoError(t, err)
+	assert.Equal(t, 2, updated.Version)
+
+	got, _ := memory.Get(ctx, "a1")
+	assert.Equal(t, 2, got.Version)
+
+	repo.EXPECT().UpdateOrder(gomock.Any(), gomock.Any(), 1).Return(repository.ErrVersionConflict)
+	_, err = s.UpdateOrder(ctx, testOrder("a1", 1), 1)
+	assert.ErrorIs(t, err, repository.ErrVersionConflict)
+
+	got, _ = memory.Get(ctx, "a1")
+	assert.Equal(t, 2, got.Version, "cache untouched on conflict")
+}
+
+func TestDeleteOrder(t *testing.T) {
+	s, repo, memory := newService(t)
+	memory.Set(ctx, testOrder("a1", 1))
+
+	repo.EXPECT().DeleteOrder(gomock.Any(), "a1", 2).Return(0, repository.ErrVersionConflict)
+	assert.ErrorIs(t, s.DeleteOrder(ctx, "a1", 2), repository.ErrVersionConflict)
+	_, ok := memory.Get(ctx, "a1")
+	assert.True(t, ok)
+
+	repo.EXPECT().DeleteOrder(gomock.Any(), "a1", 1).Return(2, nil)
+	require.NoError(t, s.DeleteOrder(ctx, "a1", 1))
+	_, ok = memory.Get(ctx, "a1")
+	assert.False(t, ok)
+}
+
+func TestEraseCustomer(t *testing.T) {
+	s, repo, memory := newService(t)
+	memory.Set(ctx, testOrder("a1", 1))
+	memory.Set(ctx, testOrder("a2", 1))
+	memory.Set(ctx, testOrder("b1", 1))
+
+	repo.EXPECT().EraseCustomer(gomock.Any(), "test", "support", "api").
+		Return(&order.Erasure{Orders: 2, OrderUIDs: []string{"a1", "a2"}, Versions: map[string]int{"a1": 2, "a2": 2}}, nil)
+
+	erasure, err := s.EraseCustomer(ctx, "test", "support", "api")
+	require.NoError(t, err)
+	assert.Equal(t, 2, erasure.Orders)
+	assert.Equal(t, 1, memory.Len())
+}
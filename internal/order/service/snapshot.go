@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/Egor-Pomidor-pdf/order-service/internal/fieldcrypt"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order/cache"
+)
+
+// snapshotOverlap is subtracted from the snapshot watermark: ingested_at is
+// set before the insert commits, so an order may become visible a little
+// after a newer one.
+const snapshotOverlap = time.Minute
+
+// WithSnapshot makes the service warm up from the cache snapshot at path
+// and fetch only orders stored after it. Delivery PII in the file is sealed
+// with cipher, nil keeps it in clear text. See SaveSnapshot and StartWarmUp.
+func WithSnapshot(path string, cipher *fieldcrypt.Cipher) Option {
+	return func(s *OrderService) {
+		s.snapshotPath = path
+		s.snapshotCipher = cipher
+	}
+}
+
+// SaveSnapshot writes the in-memory cache to the snapshot file.
+func (s *OrderService) SaveSnapshot(ctx context.Context) error {
+	if s.snapshotPath == "" {
+		return nil
+	}
+	// снимок недогретого кэша потерял бы заказы старше его watermark
+	if st := s.WarmUpStatus(); !st.Complete {
+		slog.Warn("cache snapshot skipped, warm-up not complete", "state", st.State)
+		return nil
+	}
+	lister, ok := s.cache.(cache.Lister)
+	if !ok {
+		return errors.New("cache does not support snapshots")
+	}
+	orders := lister.Orders(ctx)
+	if err := cache.WriteSnapshot(s.snapshotPath, orders, time.Now(), s.snapshotCipher); err != nil {
+		return err
+	}
+	slog.Info("cache snapshot saved", "orders", len(orders), "path", s.snapshotPath)
+	return nil
+}
+
+// RunSnapshots saves the cache every interval until ctx is done.
+func (s *OrderService) RunSnapshots(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := s.SaveSnapshot(ctx); err != nil {
+			slog.Error("failed to save cache snapshot", "error", err)
+		}
+	}
+}
+
+// restoreSnapshot fills the cache from the snapshot file. A snapshot entry
+// is only used if the database still has the order with the same version:
+// orders deleted, archived, updated or erased while the replica was down
+// are dropped, since their invalidations were never received. Orders stored
+// after the watermark are read from the database and replace the entries
+// for the same uid, e.g. an order deleted and ingested again.
+func (s *OrderService) restoreSnapshot(ctx context.Context, snap *cache.Snapshot) error {
+	fresh, err := s.repo.GetOrdersIngestedAfter(ctx, snap.Watermark.Add(-snapshotOverlap))
+	if err != nil {
+		return err
+	}
+	// версии читаются после свежих заказов, чтобы не пропустить изменения
+	// между двумя запросами
+	versions, err := s.repo.OrderVersions(ctx)
+	if err != nil {
+		return err
+	}
+
+	replaced := make(map[string]bool, len(fresh))
+	for _, o := range fresh {
+		replaced[o.OrderUID] = true
+	}
+	loaded := 0
+	for _, o := range snap.Orders {
+		if replaced[o.OrderUID] {
+			continue
+		}
+		if v, ok := versions[o.OrderUID]; ok && v == o.Version {
+			s.cache.Set(ctx, o)
+			loaded++
+		}
+	}
+	for _, o := range fresh {
+		s.cache.Set(ctx, o)
+	}
+	s.warmUp.update(func(st *WarmUpStatus) { st.Loaded = loaded + len(fresh) })
+
+	slog.Info("cache restored from snapshot",
+		"created_at", snap.CreatedAt, "watermark", snap.Watermark,
+		"orders", loaded, "stale", len(snap.Orders)-loaded, "fresh", len(fresh))
+	return nil
+}
@@ -0,0 +1,206 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Egor-Pomidor-pdf/order-service/internal/config"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order/cache"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order/repository"
+)
+
+// Warm-up strategies, see config.WarmUpConfig.
+const (
+	WarmUpNone   = "none"
+	WarmUpRecent = "recent"
+	WarmUpAll    = "all"
+)
+
+// Warm-up states reported by WarmUpStatus.
+const (
+	WarmUpPending = "pending"
+	WarmUpRunning = "running"
+	WarmUpReady   = "ready"
+)
+
+const (
+	warmUpBatchSize  = 500
+	warmUpLogEvery   = 10000
+	warmUpMaxBackoff = 30 * time.Second
+)
+
+// WarmUpStatus describes the background cache warm-up. Complete is false
+// when it gave up: the service is ready but reads miss the cache.
+type WarmUpStatus struct {
+	State      string    `json:"state"`
+	Strategy   string    `json:"strategy,omitempty"`
+	Snapshot   bool      `json:"snapshot,omitempty"`
+	Loaded     int       `json:"loaded"`
+	Attempts   int       `json:"attempts"`
+	Complete   bool      `json:"complete"`
+	Error      string    `json:"error,omitempty"`
+	StartedAt  time.Time `json:"started_at,omitzero"`
+	FinishedAt time.Time `json:"finished_at,omitzero"`
+}
+
+type warmUpState struct {
+	mu     sync.Mutex
+	status WarmUpStatus
+}
+
+func (w *warmUpState) get() WarmUpStatus {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.status
+}
+
+func (w *warmUpState) update(fn func(*WarmUpStatus)) {
+	w.mu.Lock()
+	fn(&w.status)
+	w.mu.Unlock()
+}
+
+// WarmUpStatus returns the progress of the cache warm-up started by StartWarmUp.
+func (s *OrderService) WarmUpStatus() WarmUpStatus {
+	st := s.warmUp.get()
+	if st.State == "" {
+		st.State = WarmUpPending
+	}
+	return st
+}
+
+// StartWarmUp fills the cache in the background: from the snapshot if there
+// is a usable one, otherwise as cfg.Strategy says. Database errors are
+// retried until cfg.Timeout, after that the cache is filled by reads.
+func (s *OrderService) StartWarmUp(ctx context.Context, cfg config.WarmUpConfig) error {
+	switch cfg.Strategy {
+	case "":
+		cfg.Strategy = WarmUpAll
+	case WarmUpNone, WarmUpAll:
+	case WarmUpRecent:
+		if cfg.Recent < 1 {
+			return fmt.Errorf("cache warm-up: recent must be positive, got %d", cfg.Recent)
+		}
+	default:
+		return fmt.Errorf("unknown cache warm-up strategy %q", cfg.Strategy)
+	}
+	if cfg.RetryBackoff <= 0 {
+		cfg.RetryBackoff = time.Second
+	}
+
+	s.warmUp.update(func(st *WarmUpStatus) {
+		*st = WarmUpStatus{State: WarmUpRunning, Strategy: cfg.Strategy, StartedAt: time.Now()}
+	})
+	go s.runWarmUp(ctx, cfg)
+	return nil
+}
+
+func (s *OrderService) runWarmUp(ctx context.Context, cfg config.WarmUpConfig) {
+	if cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.Timeout)
+		defer cancel()
+	}
+
+	snap := s.readSnapshot()
+	if snap == nil && cfg.Strategy == WarmUpNone {
+		s.finishWarmUp(nil)
+		return
+	}
+
+	backoff := cfg.RetryBackoff
+	for {
+		s.warmUp.update(func(st *WarmUpStatus) {
+			st.Attempts++
+			st.Loaded = 0
+		})
+		err := s.warmUpOnce(ctx, cfg, snap)
+		if err == nil {
+			s.finishWarmUp(nil)
+			return
+		}
+		s.warmUp.update(func(st *WarmUpStatus) { st.Error = err.Error() })
+		if ctx.Err() != nil {
+			s.finishWarmUp(err)
+			return
+		}
+
+		slog.Warn("cache warm-up failed, retrying", "error", err, "retry_in", backoff)
+		select {
+		case <-ctx.Done():
+			s.finishWarmUp(err)
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, warmUpMaxBackoff)
+	}
+}
+
+// readSnapshot returns the cache snapshot or nil if there is no usable one.
+func (s *OrderService) readSnapshot() *cache.Snapshot {
+	if s.snapshotPath == "" {
+		return nil
+	}
+	snap, err := cache.ReadSnapshot(s.snapshotPath, s.snapshotCipher)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			slog.Warn("cache snapshot not used", "path", s.snapshotPath, "error", err)
+		}
+		return nil
+	}
+	return snap
+}
+
+func (s *OrderService) warmUpOnce(ctx context.Context, cfg config.WarmUpConfig, snap *cache.Snapshot) error {
+	if snap != nil {
+		s.warmUp.update(func(st *WarmUpStatus) { st.Snapshot = true })
+		return s.restoreSnapshot(ctx, snap)
+	}
+
+	var f repository.OrderFilter
+	if cfg.Strategy == WarmUpRecent {
+		cutoff, err := s.repo.RecentCutoff(ctx, cfg.Recent)
+		if err != nil {
+			return err
+		}
+		f.From = cutoff
+	}
+
+	loaded := 0
+	return s.repo.StreamOrders(ctx, f, warmUpBatchSize, func(o *order.Order) error {
+		s.cache.Set(ctx, *o)
+		loaded++
+		s.warmUp.update(func(st *WarmUpStatus) { st.Loaded = loaded })
+		if loaded%warmUpLogEvery == 0 {
+			slog.Info("cache warm-up in progress", "orders", loaded)
+		}
+		return nil
+	})
+}
+
+func (s *OrderService) finishWarmUp(err error) {
+	var st WarmUpStatus
+	s.warmUp.update(func(cur *WarmUpStatus) {
+		cur.State = WarmUpReady
+		cur.Complete = err == nil
+		cur.FinishedAt = time.Now()
+		if err == nil {
+			cur.Error = ""
+		}
+		st = *cur
+	})
+
+	if err != nil {
+		slog.Warn("cache warm-up gave up, orders are read from the database",
+			"error", err, "attempts", st.Attempts, "orders", st.Loaded)
+		return
+	}
+	slog.Info("cache warmed up", "strategy", st.Strategy, "snapshot", st.Snapshot,
+		"orders", st.Loaded, "attempts", st.Attempts, "took", st.FinishedAt.Sub(st.StartedAt))
+}
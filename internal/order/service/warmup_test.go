@@ -0,0 +1,152 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Egor-Pomidor-pdf/order-service/internal/config"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order/cache"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order/repository"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func streamOf(orders ...order.Order) func(context.Context, repository.OrderFilter, int, func(*order.Order) error) error {
+	return func(_ context.Context, _ repository.OrderFilter, _ int, fn func(*order.Order) error) error {
+		for i := range orders {
+			if err := fn(&orders[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func waitReady(t *testing.T, s *OrderService) WarmUpStatus {
+	t.Helper()
+	var st WarmUpStatus
+	require.Eventually(t, func() bool {
+		st = s.WarmUpStatus()
+		return st.State == WarmUpReady
+	}, time.Second, time.Millisecond)
+	return st
+}
+
+func TestWarmUp_All(t *testing.T) {
+	s, repo, memory := newService(t)
+	assert.Equal(t, WarmUpPending, s.WarmUpStatus().State)
+
+	repo.EXPECT().StreamOrders(gomock.Any(), repository.OrderFilter{}, gomock.Any(), gomock.Any()).
+		DoAndReturn(streamOf(testOrder("a1", 1), testOrder("a2", 1)))
+
+	require.NoError(t, s.StartWarmUp(ctx, config.WarmUpConfig{Strategy: WarmUpAll}))
+	st := waitReady(t, s)
+	assert.True(t, st.Complete)
+	assert.Equal(t, 2, st.Loaded)
+	assert.Equal(t, 1, st.Attempts)
+	assert.Equal(t, 2, memory.Len())
+}
+
+func TestWarmUp_Recent(t *testing.T) {
+	s, repo, _ := newService(t)
+	cutoff := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	repo.EXPECT().RecentCutoff(gomock.Any(), 100).Return(cutoff, nil)
+	repo.EXPECT().StreamOrders(gomock.Any(), repository.OrderFilter{From: cutoff}, gomock.Any(), gomock.Any()).
+		DoAndReturn(streamOf(testOrder("a1", 1)))
+
+	require.NoError(t, s.StartWarmUp(ctx, config.WarmUpConfig{Strategy: WarmUpRecent, Recent: 100}))
+	assert.Equal(t, 1, waitReady(t, s).Loaded)
+}
+
+func TestWarmUp_None(t *testing.T) {
+	s, _, _ := newService(t)
+
+	require.NoError(t, s.StartWarmUp(ctx, config.WarmUpConfig{Strategy: WarmUpNone}))
+	st := waitReady(t, s)
+	assert.True(t, st.Complete)
+	assert.Zero(t, st.Attempts)
+}
+
+func TestWarmUp_Retry(t *testing.T) {
+	s, repo, memory := newService(t)
+
+	gomock.InOrder(
+		repo.EXPECT().StreamOrders(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+			Return(errors.New("connection refused")),
+		repo.EXPECT().StreamOrders(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(streamOf(testOrder("a1", 1))),
+	)
+
+	require.NoError(t, s.StartWarmUp(ctx, config.WarmUpConfig{RetryBackoff: time.Millisecond}))
+	st := waitReady(t, s)
+	assert.True(t, st.Complete)
+	assert.Equal(t, 2, st.Attempts)
+	assert.Empty(t, st.Error)
+	assert.Equal(t, 1, memory.Len())
+}
+
+func TestWarmUp_Timeout(t *testing.T) {
+	s, repo, _ := newService(t)
+
+	repo.EXPECT().StreamOrders(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(errors.New("connection refused")).MinTimes(1)
+
+	require.NoError(t, s.StartWarmUp(ctx, config.WarmUpConfig{
+		Timeout:      20 * time.Millisecond,
+		RetryBackoff: time.Millisecond,
+	}))
+	st := waitReady(t, s)
+	assert.False(t, st.Complete, "gave up, reads go to the database")
+	assert.Equal(t, "connection refused", st.Error)
+}
+
+func TestWarmUp_Snapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "orders.snapshot")
+	s, repo, memory := newService(t, WithSnapshot(path, nil))
+
+	ingested := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	kept, updated, erased, deleted, reingested :=
+		testOrder("a1", 1), testOrder("a2", 1), testOrder("a3", 1), testOrder("a4", 1), testOrder("a5", 1)
+	kept.IngestedAt = &ingested
+	require.NoError(t, cache.WriteSnapshot(path, []order.Order{kept, updated, erased, deleted, reingested}, ingested, nil))
+
+	// a5 удален и получен заново, пока реплика была остановлена
+	again := testOrder("a5", 1)
+	again.Payment.Amount = 42
+	gomock.InOrder(
+		repo.EXPECT().GetOrdersIngestedAfter(gomock.Any(), ingested.Add(-snapshotOverlap)).
+			Return([]order.Order{again}, nil),
+		// a3 стерт по запросу клиента: стирание повышает версию
+		repo.EXPECT().OrderVersions(gomock.Any()).
+			Return(map[string]int{"a1": 1, "a2": 2, "a3": 2, "a5": 1}, nil),
+	)
+
+	require.NoError(t, s.StartWarmUp(ctx, config.WarmUpConfig{Strategy: WarmUpNone}))
+	st := waitReady(t, s)
+	assert.True(t, st.Snapshot)
+	assert.Equal(t, 2, st.Loaded)
+
+	_, ok := memory.Get(ctx, "a1")
+	assert.True(t, ok)
+	for _, uid := range []string{"a2", "a3", "a4"} {
+		_, ok := memory.Get(ctx, uid)
+		assert.False(t, ok, uid)
+	}
+	got, ok := memory.Get(ctx, "a5")
+	require.True(t, ok)
+	assert.Equal(t, 42, got.Payment.Amount)
+}
+
+func TestWarmUp_InvalidConfig(t *testing.T) {
+	s, _, _ := newService(t)
+
+	assert.Error(t, s.StartWarmUp(ctx, config.WarmUpConfig{Strategy: "some"}))
+	assert.Error(t, s.StartWarmUp(ctx, config.WarmUpConfig{Strategy: WarmUpRecent}))
+	assert.Equal(t, WarmUpPending, s.WarmUpStatus().State)
+}
@@ -0,0 +1,181 @@
+// Package watch fans ingested orders out to subscribers, e.g. the gRPC
+// WatchOrders streams and GET /orders/stream. Publishing never blocks the
+// consumer: a subscriber whose buffer is full is dropped with
+// ErrSlowSubscriber.
+package watch
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order"
+)
+
+// DefaultBuffer is the number of orders a subscriber may fall behind by.
+const DefaultBuffer = 256
+
+var (
+	ErrSlowSubscriber = errors.New("subscriber is too slow")
+	ErrClosed         = errors.New("hub is closed")
+)
+
+// Event is a published order with its ingest sequence number. Numbers grow
+// by one per order and start at the hub creation time in microseconds, so
+// the numbers of an earlier run are lower than any current one.
+type Event struct {
+	Seq   uint64
+	Order order.Order
+}
+
+// Hub delivers published orders to every subscriber and keeps the last
+// history events for subscribers resuming after a reconnect.
+type Hub struct {
+	mu     sync.Mutex
+	subs   map[*Subscription]struct{}
+	closed bool
+	seq    uint64
+	// history - кольцевой буфер последних событий, start указывает на самое старое
+	history []Event
+	start   int
+	size    int
+}
+
+func NewHub(history int) *Hub {
+	return &Hub{
+		subs: make(map[*Subscription]struct{}),
+		seq:  uint64(time.Now().UnixMicro()),
+		size: max(history, 0),
+	}
+}
+
+// Subscription receives the orders published after Subscribe.
+type Subscription struct {
+	hub    *Hub
+	match  func(*order.Order) bool
+	events chan Event
+	err    error
+}
+
+// Subscribe registers a subscriber receiving the orders match accepts, all
+// of them if match is nil. buffer <= 0 means DefaultBuffer.
+func (h *Hub) Subscribe(buffer int, match func(*order.Order) bool) *Subscription {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.subscribe(buffer, match, nil)
+}
+
+// SubscribeAfter is Subscribe that first delivers the kept events after
+// seq. It reports false if some of them are no longer kept or seq is
+// unknown, the subscription then starts with the next published order.
+func (h *Hub) SubscribeAfter(seq uint64, buffer int, match func(*order.Order) bool) (*Subscription, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	// history хранит события с номерами h.seq-len+1 .. h.seq подряд
+	oldest := h.seq - uint64(len(h.history))
+	if seq < oldest || seq > h.seq {
+		return h.subscribe(buffer, match, nil), false
+	}
+	var missed []Event
+	for i := len(h.history) - int(h.seq-seq); i < len(h.history); i++ {
+		ev := h.history[(h.start+i)%len(h.history)]
+		if match == nil || match(&ev.Order) {
+			missed = append(missed, ev)
+		}
+	}
+	return h.subscribe(buffer, match, missed), true
+}
+
+// subscribe must be called with h.mu held. The missed events are queued
+// on top of the buffer.
+func (h *Hub) subscribe(buffer int, match func(*order.Order) bool, missed []Event) *Subscription {
+	if buffer <= 0 {
+		buffer = DefaultBuffer
+	}
+	s := &Subscription{hub: h, match: match, events: make(chan Event, buffer+len(missed))}
+	if h.closed {
+		s.err = ErrClosed
+		close(s.events)
+		return s
+	}
+	for _, ev := range missed {
+		s.events <- ev
+	}
+	h.subs[s] = struct{}{}
+	return s
+}
+
+// Publish numbers o and hands it to every matching subscriber.
+func (h *Hub) Publish(o order.Order) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.seq++
+	ev := Event{Seq: h.seq, Order: o}
+	switch {
+	case len(h.history) < h.size:
+		h.history = append(h.history, ev)
+	case h.size > 0:
+		h.history[h.start] = ev
+		h.start = (h.start + 1) % h.size
+	}
+
+	for s := range h.subs {
+		if s.match != nil && !s.match(&o) {
+			continue
+		}
+		select {
+		case s.events <- ev:
+		default:
+			h.drop(s, ErrSlowSubscriber)
+		}
+	}
+}
+
+// Close ends all subscriptions with ErrClosed, e.g. on shutdown.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for s := range h.subs {
+		h.drop(s, ErrClosed)
+	}
+}
+
+// Subscribers returns the number of active subscriptions.
+func (h *Hub) Subscribers() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subs)
+}
+
+// drop must be called with h.mu held.
+func (h *Hub) drop(s *Subscription, err error) {
+	if _, ok := h.subs[s]; !ok {
+		return
+	}
+	delete(h.subs, s)
+	s.err = err
+	close(s.events)
+}
+
+// Events is closed when the subscription ends, Err tells why.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Err returns ErrSlowSubscriber or ErrClosed once Events is closed, nil
+// after Close or while the subscription is active.
+func (s *Subscription) Err() error {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	return s.err
+}
+
+// Close unsubscribes. It is safe to call more than once.
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.drop(s, nil)
+}
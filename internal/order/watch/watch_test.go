@@ -0,0 +1,104 @@
+package watch
+
+import (
+	"testing"
+
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func drain(s *Subscription) []string {
+	var uids []string
+	for ev := range s.Events() {
+		uids = append(uids, ev.Order.OrderUID)
+	}
+	return uids
+}
+
+func TestHub_Publish(t *testing.T) {
+	h := NewHub(0)
+	all := h.Subscribe(10, nil)
+	c1 := h.Subscribe(10, func(o *order.Order) bool { return o.CustomerID == "c1" })
+
+	h.Publish(order.Order{OrderUID: "a1", CustomerID: "c1"})
+	h.Publish(order.Order{OrderUID: "a2", CustomerID: "c2"})
+	all.Close()
+	all.Close()
+	h.Publish(order.Order{OrderUID: "a3", CustomerID: "c1"})
+	assert.Equal(t, 1, h.Subscribers())
+	h.Close()
+
+	assert.Equal(t, []string{"a1", "a2"}, drain(all))
+	assert.NoError(t, all.Err())
+	assert.Equal(t, []string{"a1", "a3"}, drain(c1))
+	assert.ErrorIs(t, c1.Err(), ErrClosed)
+
+	late := h.Subscribe(0, nil)
+	assert.Empty(t, drain(late))
+	assert.ErrorIs(t, late.Err(), ErrClosed)
+}
+
+func TestHub_SlowSubscriber(t *testing.T) {
+	h := NewHub(0)
+	slow := h.Subscribe(2, nil)
+	fast := h.Subscribe(10, nil)
+
+	for _, uid := range []string{"a1", "a2", "a3", "a4"} {
+		h.Publish(order.Order{OrderUID: uid})
+	}
+
+	// отставший подписчик получает то, что успело попасть в буфер, и отключается
+	assert.Equal(t, []string{"a1", "a2"}, drain(slow))
+	assert.ErrorIs(t, slow.Err(), ErrSlowSubscriber)
+	require.Equal(t, 1, h.Subscribers())
+	fast.Close()
+	assert.Equal(t, []string{"a1", "a2", "a3", "a4"}, drain(fast))
+}
+
+func TestHub_SubscribeAfter(t *testing.T) {
+	h := NewHub(3)
+	c1 := func(o *order.Order) bool { return o.CustomerID == "c1" }
+
+	first := h.Subscribe(10, nil)
+	start := h.seq
+	for i, uid := range []string{"a1", "a2", "a3", "a4", "a5"} {
+		h.Publish(order.Order{OrderUID: uid, CustomerID: []string{"c1", "c2"}[i%2]})
+	}
+	seqs := make([]uint64, 0, 5)
+	for range 5 {
+		seqs = append(seqs, (<-first.Events()).Seq)
+	}
+	assert.Equal(t, []uint64{start + 1, start + 2, start + 3, start + 4, start + 5}, seqs)
+
+	tests := []struct {
+		name    string
+		after   uint64
+		match   func(*order.Order) bool
+		want    []string
+		resumed bool
+	}{
+		{"kept", seqs[2], nil, []string{"a4", "a5"}, true},
+		{"oldest kept", seqs[1], nil, []string{"a3", "a4", "a5"}, true},
+		{"filtered", seqs[1], c1, []string{"a3", "a5"}, true},
+		{"up to date", seqs[4], nil, nil, true},
+		{"evicted", seqs[0], nil, nil, false},
+		{"earlier run", 1, nil, nil, false},
+		{"unknown", seqs[4] + 1, nil, nil, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sub, resumed := h.SubscribeAfter(test.after, 10, test.match)
+			assert.Equal(t, test.resumed, resumed)
+			sub.Close()
+			assert.Equal(t, test.want, drain(sub))
+		})
+	}
+
+	// после пропущенных идут новые заказы
+	sub, resumed := h.SubscribeAfter(seqs[3], 1, nil)
+	require.True(t, resumed)
+	h.Publish(order.Order{OrderUID: "a6"})
+	sub.Close()
+	assert.Equal(t, []string{"a5", "a6"}, drain(sub))
+}
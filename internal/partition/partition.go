@@ -0,0 +1,94 @@
+package partition
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/Egor-Pomidor-pdf/order-service/internal/config"
+)
+
+// Tables are the parents partitioned by month of date_created.
+var Tables = []string{"orders", "items"}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// Manager keeps monthly partitions created ahead of time so inserts never
+// land in the default partition. Partitions are created by the
+// create_monthly_partition function from migration 005.
+type Manager struct {
+	db  execer
+	cfg config.PartitionConfig
+	now func() time.Time
+}
+
+func NewManager(db execer, cfg config.PartitionConfig) *Manager {
+	if cfg.MonthsAhead < 0 {
+		cfg.MonthsAhead = 0
+	}
+	return &Manager{db: db, cfg: cfg, now: time.Now}
+}
+
+// Run ensures the partitions every cfg.Interval until ctx is cancelled.
+func (m *Manager) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := m.EnsurePartitions(ctx); err != nil {
+			slog.Error("failed to create order partitions", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// EnsurePartitions creates the partitions for the current month and
+// cfg.MonthsAhead following months. Existing partitions are left alone, rows
+// of the month already in the default partition are moved to the new one.
+// A failed partition does not stop the others, the errors are joined.
+func (m *Manager) EnsurePartitions(ctx context.Context) error {
+	const op = "partition.EnsurePartitions"
+
+	var errs []error
+	for _, month := range Months(m.now(), m.cfg.MonthsAhead) {
+		for _, table := range Tables {
+			_, err := m.db.ExecContext(ctx, `SELECT create_monthly_partition($1, $2)`, table, month)
+			if err != nil {
+				if ctx.Err() != nil {
+					return fmt.Errorf("%s: %w", op, ctx.Err())
+				}
+				slog.Error("failed to create partition", "partition", Name(table, month), "error", err)
+				errs = append(errs, fmt.Errorf("%s: %s %s: %w", op, table, month.Format("2006-01"), err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Months returns the first day of the month of now and of ahead following
+// months, in UTC.
+func Months(now time.Time, ahead int) []time.Time {
+	now = now.UTC()
+	first := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	months := make([]time.Time, 0, ahead+1)
+	for i := 0; i <= ahead; i++ {
+		months = append(months, first.AddDate(0, i, 0))
+	}
+	return months
+}
+
+// Name is the partition of table holding month, e.g. orders_y2025m06.
+func Name(table string, month time.Time) string {
+	return fmt.Sprintf("%s_y%04dm%02d", table, month.Year(), int(month.Month()))
+}
@@ -0,0 +1,61 @@
+package partition
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Egor-Pomidor-pdf/order-service/internal/config"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order/fixture"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order/repository"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/pgtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Тесты с настоящей БД пропускаются без TEST_POSTGRES_BIN, см. pgtest.
+func TestMain(m *testing.M) {
+	os.Exit(pgtest.Main(m))
+}
+
+// Заказ с датой за окном созданных секций попадает в *_default, секция его
+// месяца создается позже вместе с переносом строк.
+func TestEnsurePartitions_RowsInDefault(t *testing.T) {
+	ctx := context.Background()
+	db := pgtest.NewDB(t)
+	repo := repository.NewOrderRepository(db)
+
+	// миграция создает секции на три месяца вперед
+	later := time.Now().UTC().AddDate(0, 6, 0)
+	o := fixture.New(fixture.Options{Seed: 1, MinItems: 2, MaxItems: 2}).Order()
+	o.DateCreated = later.Truncate(time.Second)
+	require.NoError(t, repo.SaveOrder(ctx, &o))
+
+	partitionOf := func(table string) string {
+		var name string
+		require.NoError(t, db.Get(&name, `SELECT DISTINCT tableoid::regclass::text FROM `+table+` WHERE order_uid = $1`, o.OrderUID))
+		return name
+	}
+	require.Equal(t, "orders_default", partitionOf("orders"))
+	require.Equal(t, "items_default", partitionOf("items"))
+
+	m := NewManager(db, config.PartitionConfig{MonthsAhead: 1})
+	m.now = func() time.Time { return later }
+	require.NoError(t, m.EnsurePartitions(ctx))
+
+	month := Months(later, 0)[0]
+	assert.Equal(t, Name("orders", month), partitionOf("orders"))
+	assert.Equal(t, Name("items", month), partitionOf("items"))
+
+	got, err := repo.GetOrderByUID(ctx, o.OrderUID)
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Len(t, got.Items, 2)
+
+	// повторный запуск ничего не меняет, новые заказы месяца идут в его секцию
+	require.NoError(t, m.EnsurePartitions(ctx))
+	next := fixture.New(fixture.Options{Seed: 2}).Order()
+	next.DateCreated = o.DateCreated
+	require.NoError(t, repo.SaveOrder(ctx, &next))
+}
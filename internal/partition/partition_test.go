@@ -0,0 +1,77 @@
+package partition
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Egor-Pomidor-pdf/order-service/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recorder struct {
+	created []string
+	err     error
+	// failing - секция, создание которой завершается ошибкой
+	failing string
+}
+
+func (r *recorder) ExecContext(_ context.Context, _ string, args ...any) (sql.Result, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	name := Name(args[0].(string), args[1].(time.Time))
+	if name == r.failing {
+		return nil, errors.New("updated partition constraint for default partition would be violated")
+	}
+	r.created = append(r.created, name)
+	return nil, nil
+}
+
+func TestManager_EnsurePartitions(t *testing.T) {
+	db := &recorder{}
+	m := NewManager(db, config.PartitionConfig{MonthsAhead: 2})
+	m.now = func() time.Time { return time.Date(2025, 11, 30, 23, 0, 0, 0, time.UTC) }
+
+	require.NoError(t, m.EnsurePartitions(context.Background()))
+	assert.Equal(t, []string{
+		"orders_y2025m11", "items_y2025m11",
+		"orders_y2025m12", "items_y2025m12",
+		"orders_y2026m01", "items_y2026m01",
+	}, db.created)
+}
+
+func TestManager_EnsurePartitionsError(t *testing.T) {
+	m := NewManager(&recorder{err: errors.New("function create_monthly_partition does not exist")}, config.PartitionConfig{})
+
+	assert.Error(t, m.EnsurePartitions(context.Background()))
+}
+
+func TestManager_EnsurePartitionsContinuesAfterError(t *testing.T) {
+	db := &recorder{failing: "orders_y2025m12"}
+	m := NewManager(db, config.PartitionConfig{MonthsAhead: 2})
+	m.now = func() time.Time { return time.Date(2025, 11, 1, 0, 0, 0, 0, time.UTC) }
+
+	err := m.EnsurePartitions(context.Background())
+	assert.ErrorContains(t, err, "orders 2025-12")
+	// следующие месяцы и таблицы все равно создаются
+	assert.Equal(t, []string{
+		"orders_y2025m11", "items_y2025m11",
+		"items_y2025m12",
+		"orders_y2026m01", "items_y2026m01",
+	}, db.created)
+}
+
+func TestMonths(t *testing.T) {
+	// границы месяцев считаются в UTC
+	msk := time.FixedZone("MSK", 3*60*60)
+	months := Months(time.Date(2025, 2, 1, 1, 0, 0, 0, msk), 1)
+
+	assert.Equal(t, []time.Time{
+		time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC),
+	}, months)
+}
@@ -0,0 +1,201 @@
+// Package pgtest starts a throwaway Postgres from local binaries for
+// integration tests. The server listens only on a unix socket in a temporary
+// directory, so nothing is downloaded and no port is taken.
+package pgtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Egor-Pomidor-pdf/order-service/internal/config"
+	dbpkg "github.com/Egor-Pomidor-pdf/order-service/internal/db"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/migrations"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+)
+
+// BinEnv names the directory with the initdb and postgres binaries, e.g.
+// /usr/lib/postgresql/16/bin. Tests using NewDB are skipped without it.
+const BinEnv = "TEST_POSTGRES_BIN"
+
+const (
+	startTimeout = 30 * time.Second
+	// порт только задает имя сокета, TCP не используется
+	port = 5432
+)
+
+type server struct {
+	dir     string
+	cmd     *exec.Cmd
+	exited  chan struct{}
+	waitErr error
+}
+
+var (
+	once     sync.Once
+	shared   *server
+	startErr error
+	dbSeq    atomic.Int64
+)
+
+// Main runs the tests of a package and stops the server if one was started:
+//
+//	func TestMain(m *testing.M) { os.Exit(pgtest.Main(m)) }
+func Main(m *testing.M) int {
+	code := m.Run()
+	if shared != nil {
+		shared.stop()
+	}
+	return code
+}
+
+// NewDB creates a database with every migration applied and drops it when
+// the test ends. The server is started on first use and shared by the tests
+// of the package.
+func NewDB(t testing.TB) *sqlx.DB {
+	t.Helper()
+
+	db, err := sqlx.Connect("postgres", dbpkg.ConnString(NewConfig(t)))
+	if err != nil {
+		t.Fatalf("connect to test database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// NewConfig is NewDB for code that connects by itself, e.g. the whole app.
+func NewConfig(t testing.TB) config.DatabaseConfig {
+	t.Helper()
+
+	bin := os.Getenv(BinEnv)
+	if bin == "" {
+		t.Skipf("%s is not set", BinEnv)
+	}
+	if os.Geteuid() == 0 {
+		t.Skip("postgres refuses to run as root")
+	}
+
+	once.Do(func() { shared, startErr = start(bin) })
+	if startErr != nil {
+		t.Fatalf("start postgres: %v", startErr)
+	}
+
+	name := fmt.Sprintf("test_%d", dbSeq.Add(1))
+	admin, err := sqlx.Connect("postgres", shared.dsn("postgres"))
+	if err != nil {
+		t.Fatalf("connect to postgres: %v", err)
+	}
+	defer admin.Close()
+	if _, err := admin.Exec(`CREATE DATABASE ` + name); err != nil {
+		t.Fatalf("create database: %v", err)
+	}
+	t.Cleanup(func() {
+		if admin, err := sqlx.Connect("postgres", shared.dsn("postgres")); err == nil {
+			// WITH (FORCE) закрывает соединения, которые тест мог не закрыть
+			admin.Exec(`DROP DATABASE IF EXISTS ` + name + ` WITH (FORCE)`)
+			admin.Close()
+		}
+	})
+
+	db, err := sqlx.Connect("postgres", shared.dsn(name))
+	if err != nil {
+		t.Fatalf("connect to %s: %v", name, err)
+	}
+	defer db.Close()
+	if err := migrations.Apply(context.Background(), db.DB); err != nil {
+		t.Fatalf("apply migrations: %v", err)
+	}
+
+	// с пустым паролем строка подключения db.ConnString разбирается неверно,
+	// а при trust-аутентификации пароль не проверяется
+	return config.DatabaseConfig{Host: shared.dir, Port: port, Name: name, User: "postgres", Password: "postgres"}
+}
+
+func start(bin string) (*server, error) {
+	dir, err := os.MkdirTemp("", "pgtest")
+	if err != nil {
+		return nil, err
+	}
+	s := &server{dir: dir}
+	data := filepath.Join(dir, "data")
+
+	initdb := exec.Command(filepath.Join(bin, "initdb"),
+		"-D", data, "-U", "postgres", "-A", "trust", "-E", "UTF8", "--locale=C", "--no-sync")
+	if out, err := initdb.CombinedOutput(); err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("initdb: %w: %s", err, out)
+	}
+
+	logFile, err := os.Create(filepath.Join(dir, "postgres.log"))
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	defer logFile.Close()
+
+	// только unix socket, надежность записи тестам не нужна
+	s.cmd = exec.Command(filepath.Join(bin, "postgres"),
+		"-D", data, "-k", dir, "-p", strconv.Itoa(port), "-c", "listen_addresses=",
+		"-c", "fsync=off", "-c", "synchronous_commit=off", "-c", "full_page_writes=off")
+	s.cmd.Stdout = logFile
+	s.cmd.Stderr = logFile
+	if err := s.cmd.Start(); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	s.exited = make(chan struct{})
+	go func() {
+		s.waitErr = s.cmd.Wait()
+		close(s.exited)
+	}()
+
+	deadline := time.Now().Add(startTimeout)
+	for {
+		db, err := sqlx.Open("postgres", s.dsn("postgres"))
+		if err == nil {
+			err = db.Ping()
+			db.Close()
+		}
+		if err == nil {
+			return s, nil
+		}
+
+		select {
+		case <-s.exited:
+			log, _ := os.ReadFile(logFile.Name())
+			os.RemoveAll(dir)
+			return nil, fmt.Errorf("postgres exited: %v: %s", s.waitErr, log)
+		case <-time.After(100 * time.Millisecond):
+		}
+		if time.Now().After(deadline) {
+			s.stop()
+			return nil, errors.Join(errors.New("postgres did not start in time"), err)
+		}
+	}
+}
+
+func (s *server) dsn(dbname string) string {
+	return fmt.Sprintf("host=%s port=%d user=postgres dbname=%s sslmode=disable", s.dir, port, dbname)
+}
+
+// stop shuts the server down (fast mode) and removes its data.
+func (s *server) stop() {
+	s.cmd.Process.Signal(os.Interrupt)
+	select {
+	case <-s.exited:
+	case <-time.After(10 * time.Second):
+		s.cmd.Process.Kill()
+		<-s.exited
+	}
+	os.RemoveAll(s.dir)
+}
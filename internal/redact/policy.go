@@ -0,0 +1,47 @@
+package redact
+
+import (
+	"github.com/Egor-Pomidor-pdf/order-service/internal/auth"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/config"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order"
+)
+
+// Policy decides which callers see personal data in clear text.
+// A nil Policy never masks.
+type Policy struct {
+	unmaskedRoles []string
+}
+
+// NewPolicy returns nil when redaction is disabled.
+func NewPolicy(cfg config.RedactionConfig) *Policy {
+	if !cfg.Enabled {
+		return nil
+	}
+	return &Policy{unmaskedRoles: cfg.UnmaskedRoles}
+}
+
+// Order returns o as the caller may see it. Admins always see clear text.
+func (p *Policy) Order(caller *auth.Principal, o *order.Order) *order.Order {
+	if p == nil || o == nil || p.unmasked(caller) {
+		return o
+	}
+
+	masked := *o
+	masked.Delivery = Delivery(o.Delivery)
+	return &masked
+}
+
+func (p *Policy) unmasked(caller *auth.Principal) bool {
+	if caller == nil {
+		return false
+	}
+	if caller.HasRole(auth.RoleAdmin) {
+		return true
+	}
+	for _, role := range p.unmaskedRoles {
+		if caller.HasRole(role) {
+			return true
+		}
+	}
+	return false
+}
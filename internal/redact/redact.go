@@ -0,0 +1,97 @@
+package redact
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order"
+)
+
+const mask = "***"
+
+// Delivery returns a copy of d with the customer's name, phone, email and
+// address masked. Zip, city and region are kept for support and analytics.
+func Delivery(d order.Delivery) order.Delivery {
+	d.Name = MaskName(d.Name)
+	d.Phone = MaskPhone(d.Phone)
+	d.Email = MaskEmail(d.Email)
+	d.Address = MaskAddress(d.Address)
+	return d
+}
+
+// MaskName keeps the first letter of every word: "Test Testov" -> "T*** T***".
+func MaskName(name string) string {
+	words := strings.Fields(name)
+	for i, w := range words {
+		r, _ := utf8.DecodeRuneInString(w)
+		words[i] = string(r) + mask
+	}
+	return strings.Join(words, " ")
+}
+
+// MaskPhone keeps the leading "+" and the last two digits.
+func MaskPhone(phone string) string {
+	if len(phone) <= 2 {
+		return mask
+	}
+	prefix := ""
+	if strings.HasPrefix(phone, "+") {
+		prefix = "+"
+	}
+	return prefix + mask + phone[len(phone)-2:]
+}
+
+// MaskEmail keeps the first letter of the local part and the domain.
+func MaskEmail(email string) string {
+	local, domain, ok := strings.Cut(email, "@")
+	if !ok || local == "" {
+		return mask
+	}
+	r, _ := utf8.DecodeRuneInString(local)
+	return string(r) + mask + "@" + domain
+}
+
+func MaskAddress(address string) string {
+	if address == "" {
+		return ""
+	}
+	return mask
+}
+
+// piiKeys are the JSON keys of order.Delivery that hold personal data.
+var piiKeys = map[string]func(string) string{
+	"name":    MaskName,
+	"phone":   MaskPhone,
+	"email":   MaskEmail,
+	"address": MaskAddress,
+}
+
+var piiPattern = regexp.MustCompile(`"(name|phone|email|address)"(\s*:\s*)"(?:[^"\\]|\\.)*"`)
+
+// Message masks the delivery block of a raw order message. Payloads that are
+// not valid JSON are scrubbed by key, which also hides item names; that is
+// acceptable for messages that could not be processed anyway.
+func Message(raw []byte) []byte {
+	var msg map[string]any
+	if err := json.Unmarshal(raw, &msg); err != nil {
+		return piiPattern.ReplaceAll(raw, []byte(`"$1"$2"`+mask+`"`))
+	}
+
+	delivery, ok := msg["delivery"].(map[string]any)
+	if !ok {
+		return raw
+	}
+	for key, maskFn := range piiKeys {
+		if v, ok := delivery[key].(string); ok {
+			delivery[key] = maskFn(v)
+		}
+	}
+
+	out, err := json.Marshal(msg)
+	if err != nil {
+		return piiPattern.ReplaceAll(raw, []byte(`"$1"$2"`+mask+`"`))
+	}
+	return out
+}
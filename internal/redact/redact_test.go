@@ -0,0 +1,111 @@
+package redact
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/Egor-Pomidor-pdf/order-service/internal/auth"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/config"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order"
+	"github.com/stretchr/testify/assert"
+)
+
+var delivery = order.Delivery{
+	Name:    "Test Testov",
+	Phone:   "+9720000000",
+	Zip:     "2639809",
+	City:    "Kiryat Mozkin",
+	Address: "Ploshad Mira 15",
+	Region:  "Kraiot",
+	Email:   "test@gmail.com",
+}
+
+func TestDelivery(t *testing.T) {
+	masked := Delivery(delivery)
+
+	assert.Equal(t, "T*** T***", masked.Name)
+	assert.Equal(t, "+***00", masked.Phone)
+	assert.Equal(t, "t***@gmail.com", masked.Email)
+	assert.Equal(t, "***", masked.Address)
+	assert.Equal(t, delivery.City, masked.City)
+	assert.Equal(t, delivery.Zip, masked.Zip)
+}
+
+func TestMessage(t *testing.T) {
+	tests := []struct {
+		name        string
+		raw         string
+		contains    []string
+		notContains []string
+	}{
+		{
+			name:        "valid order",
+			raw:         `{"order_uid":"1","delivery":{"name":"Test Testov","phone":"+9720000000","email":"test@gmail.com","address":"Ploshad Mira 15","city":"Kiryat Mozkin"},"items":[{"name":"Mascaras"}]}`,
+			contains:    []string{`"T*** T***"`, `"t***@gmail.com"`, `"Kiryat Mozkin"`, `"Mascaras"`},
+			notContains: []string{"Testov", "+9720000000", "test@gmail.com", "Ploshad"},
+		},
+		{
+			name:        "broken json",
+			raw:         `{"order_uid":"1","delivery":{"name":"Test Testov","phone": "+9720000000","email":"test@gmail.com"`,
+			contains:    []string{`"name":"***"`, `"phone": "***"`},
+			notContains: []string{"Testov", "+9720000000", "test@gmail.com"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out := string(Message([]byte(test.raw)))
+			for _, s := range test.contains {
+				assert.Contains(t, out, s)
+			}
+			for _, s := range test.notContains {
+				assert.NotContains(t, out, s)
+			}
+		})
+	}
+}
+
+func TestPolicy_Order(t *testing.T) {
+	o := &order.Order{OrderUID: "1", Delivery: delivery}
+	policy := NewPolicy(config.RedactionConfig{Enabled: true, UnmaskedRoles: []string{"pii"}})
+
+	tests := []struct {
+		name     string
+		policy   *Policy
+		caller   *auth.Principal
+		unmasked bool
+	}{
+		{name: "reader", policy: policy, caller: &auth.Principal{Roles: []string{auth.RoleReader}}},
+		{name: "anonymous", policy: policy},
+		{name: "pii role", policy: policy, caller: &auth.Principal{Roles: []string{auth.RoleReader, "pii"}}, unmasked: true},
+		{name: "admin", policy: policy, caller: &auth.Principal{Roles: []string{auth.RoleAdmin}}, unmasked: true},
+		{name: "disabled", policy: NewPolicy(config.RedactionConfig{}), unmasked: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := test.policy.Order(test.caller, o)
+			assert.Equal(t, test.unmasked, got.Delivery.Email == delivery.Email)
+			assert.Equal(t, delivery.Email, o.Delivery.Email, "original must not be modified")
+		})
+	}
+}
+
+func TestLogHandler(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewLogHandler(slog.NewTextHandler(&buf, nil))).With("email", "test@gmail.com")
+
+	logger.ErrorContext(context.Background(), "SKIPPING_INVALID_MESSAGE",
+		"raw_message", `{"delivery":{"name":"Test Testov","phone":"+9720000000"}}`,
+		slog.Group("customer", "phone", "+9720000000"),
+		"name", "orders",
+	)
+
+	out := buf.String()
+	assert.NotContains(t, out, "test@gmail.com")
+	assert.NotContains(t, out, "Testov")
+	assert.NotContains(t, out, "+9720000000")
+	assert.Contains(t, out, "name=orders")
+}
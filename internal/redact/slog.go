@@ -0,0 +1,67 @@
+package redact
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+)
+
+// LogHandler scrubs personal data from log records before passing them on:
+// attributes named like a PII field are masked and string values that carry
+// an order payload (e.g. "raw_message") go through Message.
+type LogHandler struct {
+	next slog.Handler
+}
+
+func NewLogHandler(next slog.Handler) *LogHandler {
+	return &LogHandler{next: next}
+}
+
+func (h *LogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *LogHandler) Handle(ctx context.Context, r slog.Record) error {
+	scrubbed := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		scrubbed.AddAttrs(scrubAttr(a))
+		return true
+	})
+	return h.next.Handle(ctx, scrubbed)
+}
+
+func (h *LogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	scrubbed := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		scrubbed[i] = scrubAttr(a)
+	}
+	return &LogHandler{next: h.next.WithAttrs(scrubbed)}
+}
+
+func (h *LogHandler) WithGroup(name string) slog.Handler {
+	return &LogHandler{next: h.next.WithGroup(name)}
+}
+
+func scrubAttr(a slog.Attr) slog.Attr {
+	a.Value = a.Value.Resolve()
+
+	switch a.Value.Kind() {
+	case slog.KindGroup:
+		group := a.Value.Group()
+		scrubbed := make([]slog.Attr, len(group))
+		for i, ga := range group {
+			scrubbed[i] = scrubAttr(ga)
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(scrubbed...)}
+	case slog.KindString:
+		v := a.Value.String()
+		// "name" is too common a log key to be treated as personal data
+		if maskFn, ok := piiKeys[strings.ToLower(a.Key)]; ok && a.Key != "name" {
+			return slog.String(a.Key, maskFn(v))
+		}
+		if strings.Contains(v, `"delivery"`) || piiPattern.MatchString(v) {
+			return slog.String(a.Key, string(Message([]byte(v))))
+		}
+	}
+	return a
+}
@@ -0,0 +1,193 @@
+package retention
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Egor-Pomidor-pdf/order-service/internal/config"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order"
+)
+
+const (
+	ModeArchive = "archive"
+	ModeExport  = "export"
+)
+
+// Store is the part of the order repository retention works with.
+type Store interface {
+	ExpiredOrderUIDs(ctx context.Context, before time.Time, limit int) ([]string, error)
+	ArchiveOrders(ctx context.Context, uids []string) (int, error)
+	GetOrdersByUIDs(ctx context.Context, uids []string) ([]order.Order, error)
+	DeleteOrders(ctx context.Context, uids []string) (int, error)
+}
+
+// Evictor drops removed orders from the cache of this process right away,
+// other replicas are told by the repository's change notifications.
+type Evictor interface {
+	Evict(uids ...string)
+}
+
+type Stats struct {
+	Orders  int    `json:"orders"`
+	Batches int    `json:"batches"`
+	File    string `json:"file,omitempty"`
+}
+
+type Archiver struct {
+	cfg   config.RetentionConfig
+	store Store
+	cache Evictor
+	now   func() time.Time
+}
+
+func NewArchiver(cfg config.RetentionConfig, store Store, cache Evictor) (*Archiver, error) {
+	if cfg.Mode != ModeArchive && cfg.Mode != ModeExport {
+		return nil, fmt.Errorf("unknown retention mode %q", cfg.Mode)
+	}
+	if cfg.MaxAgeDays < 1 {
+		return nil, fmt.Errorf("retention max age must be at least one day")
+	}
+	if cfg.BatchSize < 1 {
+		cfg.BatchSize = 500
+	}
+	return &Archiver{cfg: cfg, store: store, cache: cache, now: time.Now}, nil
+}
+
+// Run applies the policy every cfg.Interval until ctx is cancelled.
+func (a *Archiver) Run(ctx context.Context) {
+	ticker := time.NewTicker(a.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		stats, err := a.RunOnce(ctx)
+		if err != nil {
+			slog.Error("retention run failed", "error", err, "orders", stats.Orders)
+		} else if stats.Orders > 0 {
+			slog.Info("retention run finished", "mode", a.cfg.Mode, "orders", stats.Orders, "batches", stats.Batches)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce moves every expired order out of the hot tables in batches.
+// A batch is only deleted after it has been archived or written to disk.
+func (a *Archiver) RunOnce(ctx context.Context) (Stats, error) {
+	const op = "retention.RunOnce"
+
+	var stats Stats
+	cutoff := a.now().AddDate(0, 0, -a.cfg.MaxAgeDays)
+
+	var export *exportFile
+	defer func() {
+		if export != nil {
+			if err := export.Close(); err != nil {
+				slog.Error("failed to close retention export", "file", export.path, "error", err)
+			}
+		}
+	}()
+
+	for ctx.Err() == nil {
+		uids, err := a.store.ExpiredOrderUIDs(ctx, cutoff, a.cfg.BatchSize)
+		if err != nil {
+			return stats, fmt.Errorf("%s: %w", op, err)
+		}
+		if len(uids) == 0 {
+			break
+		}
+
+		var moved int
+		switch a.cfg.Mode {
+		case ModeArchive:
+			moved, err = a.store.ArchiveOrders(ctx, uids)
+		case ModeExport:
+			if export == nil {
+				if export, err = newExportFile(a.cfg.ExportDir, a.now()); err != nil {
+					return stats, fmt.Errorf("%s: %w", op, err)
+				}
+				stats.File = export.path
+			}
+			moved, err = a.exportBatch(ctx, export, uids)
+		}
+		if err != nil {
+			return stats, fmt.Errorf("%s: %w", op, err)
+		}
+
+		a.cache.Evict(uids...)
+		stats.Orders += moved
+		stats.Batches++
+
+		if moved == 0 {
+			// nothing could be removed, stop instead of spinning on the same batch
+			break
+		}
+	}
+	return stats, ctx.Err()
+}
+
+func (a *Archiver) exportBatch(ctx context.Context, export *exportFile, uids []string) (int, error) {
+	orders, err := a.store.GetOrdersByUIDs(ctx, uids)
+	if err != nil {
+		return 0, err
+	}
+	for i := range orders {
+		if err := export.Write(&orders[i]); err != nil {
+			return 0, err
+		}
+	}
+	if err := export.Sync(); err != nil {
+		return 0, err
+	}
+	return a.store.DeleteOrders(ctx, uids)
+}
+
+// exportFile is a gzipped JSONL file with one order per line.
+type exportFile struct {
+	path string
+	file *os.File
+	gz   *gzip.Writer
+	enc  *json.Encoder
+}
+
+func newExportFile(dir string, now time.Time) (*exportFile, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, fmt.Sprintf("orders-%s.jsonl.gz", now.UTC().Format("20060102T150405Z")))
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o640)
+	if err != nil {
+		return nil, err
+	}
+	gz := gzip.NewWriter(file)
+	return &exportFile{path: path, file: file, gz: gz, enc: json.NewEncoder(gz)}, nil
+}
+
+func (f *exportFile) Write(o *order.Order) error {
+	return f.enc.Encode(o)
+}
+
+// Sync makes everything written so far durable.
+func (f *exportFile) Sync() error {
+	if err := f.gz.Flush(); err != nil {
+		return err
+	}
+	return f.file.Sync()
+}
+
+func (f *exportFile) Close() error {
+	if err := f.gz.Close(); err != nil {
+		f.file.Close()
+		return err
+	}
+	return f.file.Close()
+}
@@ -0,0 +1,152 @@
+package retention
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/Egor-Pomidor-pdf/order-service/internal/config"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var now = time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+type fakeStore struct {
+	hot      map[string]order.Order
+	archived map[string]order.Order
+}
+
+func newFakeStore(ages ...int) *fakeStore {
+	s := &fakeStore{hot: map[string]order.Order{}, archived: map[string]order.Order{}}
+	for i, days := range ages {
+		uid := fmt.Sprintf("order-%d", i)
+		s.hot[uid] = order.Order{OrderUID: uid, DateCreated: now.AddDate(0, 0, -days)}
+	}
+	return s
+}
+
+func (s *fakeStore) ExpiredOrderUIDs(_ context.Context, before time.Time, limit int) ([]string, error) {
+	var uids []string
+	for uid, o := range s.hot {
+		if o.DateCreated.Before(before) {
+			uids = append(uids, uid)
+		}
+	}
+	sort.Strings(uids)
+	if len(uids) > limit {
+		uids = uids[:limit]
+	}
+	return uids, nil
+}
+
+func (s *fakeStore) ArchiveOrders(_ context.Context, uids []string) (int, error) {
+	for _, uid := range uids {
+		s.archived[uid] = s.hot[uid]
+		delete(s.hot, uid)
+	}
+	return len(uids), nil
+}
+
+func (s *fakeStore) GetOrdersByUIDs(_ context.Context, uids []string) ([]order.Order, error) {
+	var orders []order.Order
+	for _, uid := range uids {
+		orders = append(orders, s.hot[uid])
+	}
+	return orders, nil
+}
+
+func (s *fakeStore) DeleteOrders(_ context.Context, uids []string) (int, error) {
+	for _, uid := range uids {
+		delete(s.hot, uid)
+	}
+	return len(uids), nil
+}
+
+type fakeCache struct {
+	evicted []string
+}
+
+func (c *fakeCache) Evict(uids ...string) {
+	c.evicted = append(c.evicted, uids...)
+}
+
+func newTestArchiver(t *testing.T, cfg config.RetentionConfig, store Store, cache Evictor) *Archiver {
+	t.Helper()
+	a, err := NewArchiver(cfg, store, cache)
+	require.NoError(t, err)
+	a.now = func() time.Time { return now }
+	return a
+}
+
+func TestArchiver_Archive(t *testing.T) {
+	store := newFakeStore(400, 500, 10, 366, 1)
+	cache := &fakeCache{}
+	a := newTestArchiver(t, config.RetentionConfig{Mode: ModeArchive, MaxAgeDays: 365, BatchSize: 2}, store, cache)
+
+	stats, err := a.RunOnce(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, stats.Orders)
+	assert.Equal(t, 2, stats.Batches)
+	assert.Len(t, store.hot, 2)
+	assert.Contains(t, store.archived, "order-3")
+	assert.ElementsMatch(t, []string{"order-0", "order-1", "order-3"}, cache.evicted)
+}
+
+func TestArchiver_Export(t *testing.T) {
+	store := newFakeStore(400, 10, 366)
+	dir := t.TempDir()
+	a := newTestArchiver(t, config.RetentionConfig{Mode: ModeExport, MaxAgeDays: 365, BatchSize: 1, ExportDir: dir}, store, &fakeCache{})
+
+	stats, err := a.RunOnce(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.Orders)
+	assert.Len(t, store.hot, 1)
+
+	f, err := os.Open(stats.File)
+	require.NoError(t, err)
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	require.NoError(t, err)
+
+	var uids []string
+	scanner := bufio.NewScanner(gz)
+	for scanner.Scan() {
+		var o order.Order
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &o))
+		uids = append(uids, o.OrderUID)
+	}
+	require.NoError(t, scanner.Err())
+	assert.Equal(t, []string{"order-0", "order-2"}, uids)
+}
+
+func TestArchiver_NothingExpired(t *testing.T) {
+	store := newFakeStore(1, 2)
+	dir := t.TempDir()
+	a := newTestArchiver(t, config.RetentionConfig{Mode: ModeExport, MaxAgeDays: 30, ExportDir: dir}, store, &fakeCache{})
+
+	stats, err := a.RunOnce(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, stats.Orders)
+	assert.Empty(t, stats.File)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "no file should be created when nothing expired")
+}
+
+func TestNewArchiver_InvalidConfig(t *testing.T) {
+	_, err := NewArchiver(config.RetentionConfig{Mode: "s3", MaxAgeDays: 1}, newFakeStore(), &fakeCache{})
+	assert.Error(t, err)
+
+	_, err = NewArchiver(config.RetentionConfig{Mode: ModeArchive}, newFakeStore(), &fakeCache{})
+	assert.Error(t, err)
+}
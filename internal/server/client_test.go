@@ -0,0 +1,59 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Egor-Pomidor-pdf/order-service/api/client"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order/fixture"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order/repository"
+	mock_service "github.com/Egor-Pomidor-pdf/order-service/internal/order/service/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestClient checks that the generated client works against the server.
+func TestClient(t *testing.T) {
+	o := fixture.New(fixture.Options{Seed: 1}).Order()
+	o.Version = 3
+	orders := mock_service.NewMockOrderServiceInterface(gomock.NewController(t))
+	orders.EXPECT().GetOrder(gomock.Any(), o.OrderUID).Return(&o, nil)
+	orders.EXPECT().DeleteOrder(gomock.Any(), o.OrderUID, 2).Return(repository.ErrVersionConflict)
+
+	ts := httptest.NewServer(contractServer(t, orders, o))
+	defer ts.Close()
+	c, err := client.NewClientWithResponses(ts.URL, client.WithAPIKey(adminKey))
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	got, err := c.GetOrderWithResponse(ctx, o.OrderUID)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, got.StatusCode())
+	require.NotNil(t, got.JSON200)
+	assert.Equal(t, o.OrderUID, got.JSON200.OrderUid)
+	assert.Equal(t, client.OrderLocale(o.Locale), got.JSON200.Locale)
+	assert.Equal(t, o.Delivery.Phone, got.JSON200.Delivery.Phone, "admin sees clear text")
+	assert.Len(t, got.JSON200.Items, len(o.Items))
+	assert.Equal(t, `"3"`, got.HTTPResponse.Header.Get("ETag"))
+
+	deleted, err := c.DeleteOrderWithResponse(ctx, o.OrderUID, &client.DeleteOrderParams{IfMatch: `"2"`})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusPreconditionFailed, deleted.StatusCode())
+	require.NotNil(t, deleted.JSON412)
+	assert.NotEmpty(t, deleted.JSON412.Error)
+
+	ready, err := c.GetReadinessWithResponse(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, ready.JSON200)
+	assert.Equal(t, client.WarmUpStatusState("ready"), ready.JSON200.State)
+
+	anonymous, err := client.NewClientWithResponses(ts.URL)
+	require.NoError(t, err)
+	denied, err := anonymous.GetOrderWithResponse(ctx, o.OrderUID)
+	require.NoError(t, err)
+	require.NotNil(t, denied.JSON401)
+	assert.Equal(t, "Unauthorized", denied.JSON401.Error)
+}
@@ -0,0 +1,338 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Egor-Pomidor-pdf/order-service/api"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/auth"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/config"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/export"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/kafka"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order/fixture"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order/repository"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order/service"
+	mock_service "github.com/Egor-Pomidor-pdf/order-service/internal/order/service/mocks"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order/watch"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/stats"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/legacy"
+	"github.com/go-chi/chi/v5"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	openapi3filter.RegisterBodyDecoder("application/x-ndjson", openapi3filter.FileBodyDecoder)
+	openapi3filter.RegisterBodyDecoder("application/vnd.apache.parquet", openapi3filter.FileBodyDecoder)
+	openapi3filter.RegisterBodyDecoder("text/event-stream", openapi3filter.FileBodyDecoder)
+}
+
+const (
+	readerKey = "reader-key"
+	adminKey  = "admin-key"
+)
+
+type fakeReplayer struct{}
+
+func (fakeReplayer) Replay(_ context.Context, req kafka.ReplayRequest) (*kafka.ReplayReport, error) {
+	return &kafka.ReplayReport{GroupID: "orders-replay-1", DryRun: req.DryRun, Processed: 3, Inserted: 2, Duplicated: 1}, nil
+}
+
+type fakeStats struct{}
+
+func (fakeStats) Revenue(context.Context, stats.Query) ([]stats.RevenueRow, error) {
+	return []stats.RevenueRow{{Period: time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC), Currency: "RUB", Revenue: 1817, Orders: 1}}, nil
+}
+
+func (fakeStats) TopBrands(context.Context, stats.Query) ([]stats.BrandRow, error) {
+	return []stats.BrandRow{{Brand: "Vivienne Sabo", Items: 3, Revenue: 951}}, nil
+}
+
+func (fakeStats) DeliveryCost(context.Context, stats.Query) ([]stats.DeliveryCostRow, error) {
+	return []stats.DeliveryCostRow{{DeliveryService: "meest", Orders: 3, AvgDeliveryCost: 1000.0 / 3}}, nil
+}
+
+func (fakeStats) Regions(context.Context, stats.Query) ([]stats.RegionRow, error) {
+	return nil, nil
+}
+
+func (fakeStats) Refresh(context.Context) error { return nil }
+
+type orderSource []order.Order
+
+func (s orderSource) StreamOrders(_ context.Context, _ repository.OrderFilter, _ int, fn func(*order.Order) error) error {
+	for i := range s {
+		if err := fn(&s[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// contractServer is the full router with fakes behind every dependency and
+// API key auth for a reader and an admin.
+func contractServer(t *testing.T, orders *mock_service.MockOrderServiceInterface, o order.Order) http.Handler {
+	t.Helper()
+	authenticator, err := auth.New(config.AuthConfig{
+		Enabled: true,
+		APIKeys: []string{"support:" + readerKey + ":reader", "ops:" + adminKey + ":admin"},
+	})
+	require.NoError(t, err)
+
+	svc := &fakeOrderService{
+		MockOrderServiceInterface: orders,
+		fakeWarmUp:                fakeWarmUp{status: service.WarmUpStatus{State: service.WarmUpReady, Strategy: service.WarmUpAll, Loaded: 1, Attempts: 1, Complete: true}},
+		Hub:                       watch.NewHub(0),
+	}
+	cfg := config.ServerConfig{Redaction: config.RedactionConfig{Enabled: true}}
+	return NewServer(cfg, svc, fakeReplayer{}, fakeStats{}, export.NewExporter(orderSource{o}, 0), authenticator).Handler
+}
+
+type contractCase struct {
+	name   string
+	method string
+	path   string
+	key    string
+	header map[string]string
+	body   string
+	mock   func(m *mock_service.MockOrderServiceInterface)
+	status int
+	// badRequest marks requests that break the specification on purpose
+	badRequest bool
+	// stream requests are sent with a done context, so the event stream
+	// ends after the first event
+	stream bool
+}
+
+func TestContract(t *testing.T) {
+	doc, err := api.Load()
+	require.NoError(t, err)
+	router, err := legacy.NewRouter(doc)
+	require.NoError(t, err)
+
+	o := fixture.New(fixture.Options{Seed: 1, MaxItems: 3}).Order()
+	o.Version = 1
+	body, err := json.Marshal(o)
+	require.NoError(t, err)
+	uid := o.OrderUID
+	stored := func() *order.Order { c := o; return &c }
+
+	cases := []contractCase{
+		{name: "get order", method: http.MethodGet, path: "/order/" + uid, key: readerKey, status: http.StatusOK,
+			mock: func(m *mock_service.MockOrderServiceInterface) {
+				m.EXPECT().GetOrder(gomock.Any(), uid).Return(stored(), nil)
+			}},
+		{name: "get order unmasked", method: http.MethodGet, path: "/order/" + uid, key: adminKey, status: http.StatusOK,
+			mock: func(m *mock_service.MockOrderServiceInterface) {
+				m.EXPECT().GetOrder(gomock.Any(), uid).Return(stored(), nil)
+			}},
+		{name: "get missing order", method: http.MethodGet, path: "/order/missing", key: readerKey, status: http.StatusNotFound,
+			mock: func(m *mock_service.MockOrderServiceInterface) {
+				m.EXPECT().GetOrder(gomock.Any(), "missing").Return(nil, nil)
+			}},
+		{name: "get order error", method: http.MethodGet, path: "/order/" + uid, key: readerKey, status: http.StatusInternalServerError,
+			mock: func(m *mock_service.MockOrderServiceInterface) {
+				m.EXPECT().GetOrder(gomock.Any(), uid).Return(nil, errors.New("Service Error"))
+			}},
+		{name: "get order without key", method: http.MethodGet, path: "/order/" + uid, status: http.StatusUnauthorized},
+
+		{name: "create order", method: http.MethodPost, path: "/orders", key: adminKey, body: string(body), status: http.StatusCreated,
+			mock: func(m *mock_service.MockOrderServiceInterface) {
+				m.EXPECT().ProcessOrder(gomock.Any(), gomock.Any()).Return(stored(), nil)
+			}},
+		{name: "create duplicate", method: http.MethodPost, path: "/orders", key: adminKey, body: string(body), status: http.StatusConflict,
+			mock: func(m *mock_service.MockOrderServiceInterface) {
+				m.EXPECT().ProcessOrder(gomock.Any(), gomock.Any()).Return(nil, repository.ErrOrderExists)
+			}},
+		{name: "create invalid order", method: http.MethodPost, path: "/orders", key: adminKey, body: `{"order_uid": "x"}`,
+			status: http.StatusBadRequest, badRequest: true},
+		{name: "create as reader", method: http.MethodPost, path: "/orders", key: readerKey, body: string(body), status: http.StatusForbidden},
+
+		{name: "update order", method: http.MethodPut, path: "/orders/" + uid, key: adminKey, header: map[string]string{"If-Match": `"1"`},
+			body: string(body), status: http.StatusOK,
+			mock: func(m *mock_service.MockOrderServiceInterface) {
+				m.EXPECT().UpdateOrder(gomock.Any(), gomock.Any(), 1).Return(stored(), nil)
+			}},
+		{name: "update stale order", method: http.MethodPut, path: "/orders/" + uid, key: adminKey, header: map[string]string{"If-Match": `"1"`},
+			body: string(body), status: http.StatusPreconditionFailed,
+			mock: func(m *mock_service.MockOrderServiceInterface) {
+				m.EXPECT().UpdateOrder(gomock.Any(), gomock.Any(), 1).Return(nil, repository.ErrVersionConflict)
+			}},
+		{name: "update without If-Match", method: http.MethodPut, path: "/orders/" + uid, key: adminKey, body: string(body),
+			status: http.StatusPreconditionRequired, badRequest: true},
+		{name: "delete order", method: http.MethodDelete, path: "/orders/" + uid, key: adminKey, header: map[string]string{"If-Match": "*"},
+			status: http.StatusNoContent,
+			mock: func(m *mock_service.MockOrderServiceInterface) {
+				m.EXPECT().DeleteOrder(gomock.Any(), uid, 0).Return(nil)
+			}},
+		{name: "delete missing order", method: http.MethodDelete, path: "/orders/missing", key: adminKey, header: map[string]string{"If-Match": `"2"`},
+			status: http.StatusNotFound,
+			mock: func(m *mock_service.MockOrderServiceInterface) {
+				m.EXPECT().DeleteOrder(gomock.Any(), "missing", 2).Return(repository.ErrOrderNotFound)
+			}},
+
+		{name: "stream orders", method: http.MethodGet, path: "/orders/stream?customer_id=c1&delivery_service=meest", key: readerKey,
+			status: http.StatusOK, stream: true},
+		{name: "stream orders unknown Last-Event-ID", method: http.MethodGet, path: "/orders/stream", key: readerKey,
+			header: map[string]string{"Last-Event-ID": "42"}, status: http.StatusOK, stream: true},
+		{name: "stream orders without key", method: http.MethodGet, path: "/orders/stream", status: http.StatusUnauthorized},
+
+		{name: "export csv", method: http.MethodGet, path: "/orders/export?set=items", key: adminKey, status: http.StatusOK},
+		{name: "export jsonl", method: http.MethodGet, path: "/orders/export?format=jsonl&from=2020-01-01", key: adminKey, status: http.StatusOK},
+		{name: "export parquet", method: http.MethodGet, path: "/orders/export?format=parquet", key: adminKey, status: http.StatusOK},
+		{name: "export with pii", method: http.MethodGet, path: "/orders/export?columns=order_uid,delivery_name&include_pii=true", key: adminKey, status: http.StatusOK},
+		{name: "export unknown format", method: http.MethodGet, path: "/orders/export?format=xml", key: adminKey,
+			status: http.StatusBadRequest, badRequest: true},
+
+		{name: "start replay", method: http.MethodPost, path: "/admin/replay", key: adminKey,
+			body: `{"since": "2025-06-01T00:00:00Z", "dry_run": true}`, status: http.StatusAccepted},
+		{name: "start empty replay", method: http.MethodPost, path: "/admin/replay", key: adminKey, body: `{}`, status: http.StatusBadRequest},
+		{name: "get missing replay", method: http.MethodGet, path: "/admin/replay/unknown", key: adminKey, status: http.StatusNotFound},
+		{name: "erase customer", method: http.MethodPost, path: "/admin/customers/erase", key: adminKey,
+			body: `{"customer_id": "c1"}`, status: http.StatusOK,
+			mock: func(m *mock_service.MockOrderServiceInterface) {
+				m.EXPECT().EraseCustomer(gomock.Any(), "c1", "ops", order.ErasureSourceHTTP).Return(&order.Erasure{
+					ID: 1, CustomerHash: "ab12", Orders: 1, RequestedBy: "ops", Source: order.ErasureSourceHTTP,
+					ErasedAt: time.Now(), OrderUIDs: []string{uid},
+				}, nil)
+			}},
+		{name: "erase without customer", method: http.MethodPost, path: "/admin/customers/erase", key: adminKey,
+			body: `{}`, status: http.StatusBadRequest, badRequest: true},
+
+		{name: "revenue", method: http.MethodGet, path: "/stats/revenue?group=month&currency=rub", key: readerKey, status: http.StatusOK},
+		{name: "revenue csv", method: http.MethodGet, path: "/stats/revenue?format=csv", key: readerKey, status: http.StatusOK},
+		{name: "revenue invalid group", method: http.MethodGet, path: "/stats/revenue?group=year", key: readerKey,
+			status: http.StatusBadRequest, badRequest: true},
+		{name: "brands", method: http.MethodGet, path: "/stats/brands?limit=5", key: readerKey, status: http.StatusOK},
+		{name: "delivery cost", method: http.MethodGet, path: "/stats/delivery-cost?from=2025-01-01&to=2025-02-01", key: readerKey, status: http.StatusOK},
+		{name: "regions", method: http.MethodGet, path: "/stats/regions", key: readerKey, status: http.StatusOK},
+		{name: "refresh stats", method: http.MethodPost, path: "/stats/refresh", key: adminKey, status: http.StatusNoContent},
+
+		{name: "healthz", method: http.MethodGet, path: "/healthz", status: http.StatusOK},
+		{name: "readyz", method: http.MethodGet, path: "/readyz", status: http.StatusOK},
+		{name: "metrics", method: http.MethodGet, path: "/metrics", status: http.StatusOK},
+		{name: "openapi", method: http.MethodGet, path: "/openapi.json", status: http.StatusOK},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			orders := mock_service.NewMockOrderServiceInterface(gomock.NewController(t))
+			if tc.mock != nil {
+				tc.mock(orders)
+			}
+			handler := contractServer(t, orders, o)
+
+			req := newContractRequest(tc)
+			if tc.stream {
+				ctx, cancel := context.WithCancel(req.Context())
+				cancel()
+				req = req.WithContext(ctx)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			require.Equal(t, tc.status, rec.Code, rec.Body.String())
+
+			validateContract(t, router, newContractRequest(tc), rec, tc.badRequest)
+		})
+	}
+}
+
+func newContractRequest(tc contractCase) *http.Request {
+	var body io.Reader
+	if tc.body != "" {
+		body = strings.NewReader(tc.body)
+	}
+	req := httptest.NewRequest(tc.method, tc.path, body)
+	if tc.body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if tc.key != "" {
+		req.Header.Set("X-API-Key", tc.key)
+	}
+	for k, v := range tc.header {
+		req.Header.Set(k, v)
+	}
+	return req
+}
+
+// validateContract checks the request and the recorded response against the
+// specification: the status is documented and the body matches its schema.
+func validateContract(t *testing.T, router routers.Router, req *http.Request, rec *httptest.ResponseRecorder, badRequest bool) {
+	t.Helper()
+	route, params, err := router.FindRoute(req)
+	require.NoError(t, err, "route is not documented")
+
+	input := &openapi3filter.RequestValidationInput{
+		Request:    req,
+		PathParams: params,
+		Route:      route,
+		Options:    &openapi3filter.Options{AuthenticationFunc: openapi3filter.NoopAuthenticationFunc},
+	}
+	if err := openapi3filter.ValidateRequest(context.Background(), input); badRequest {
+		require.Error(t, err, "request was expected to break the specification")
+	} else {
+		require.NoError(t, err)
+	}
+
+	err = openapi3filter.ValidateResponse(context.Background(), &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: input,
+		Status:                 rec.Code,
+		Header:                 rec.Header(),
+		Body:                   io.NopCloser(bytes.NewReader(rec.Body.Bytes())),
+		Options:                &openapi3filter.Options{IncludeResponseStatus: true},
+	})
+	require.NoError(t, err, rec.Body.String())
+}
+
+// TestContract_Routes checks that the specification and the router list the
+// same operations.
+func TestContract_Routes(t *testing.T) {
+	doc, err := api.Load()
+	require.NoError(t, err)
+
+	var documented []string
+	for path, item := range doc.Paths.Map() {
+		for method := range item.Operations() {
+			documented = append(documented, method+" "+path)
+		}
+	}
+
+	handler := NewServer(config.ServerConfig{}, &fakeOrderService{}, nil, nil, nil, nil).Handler
+	var served []string
+	err = chi.Walk(handler.(chi.Routes), func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		// статика из web/
+		if route != "/*" {
+			served = append(served, method+" "+strings.TrimSuffix(route, "/"))
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	sort.Strings(documented)
+	sort.Strings(served)
+	assert.Equal(t, documented, served)
+}
+
+func TestOpenAPIHandler(t *testing.T) {
+	rec := httptest.NewRecorder()
+	openAPIHandler(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	doc, err := openapi3.NewLoader().LoadFromData(rec.Body.Bytes())
+	require.NoError(t, err)
+	assert.NotNil(t, doc.Paths.Find("/order/{order_uid}"))
+}
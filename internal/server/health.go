@@ -0,0 +1,33 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order/service"
+)
+
+// WarmUpReporter reports the progress of the cache warm-up.
+type WarmUpReporter interface {
+	WarmUpStatus() service.WarmUpStatus
+}
+
+// healthHandler answers liveness probes: the process is up.
+func healthHandler(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ok"))
+}
+
+// readyHandler answers readiness probes with 503 until the cache warm-up
+// has finished, the body is the warm-up status.
+func readyHandler(warmUp WarmUpReporter) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		status := warmUp.WarmUpStatus()
+		w.Header().Set("Content-Type", "application/json")
+		if status.State != service.WarmUpReady {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(status)
+	}
+}
@@ -0,0 +1,38 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeWarmUp struct {
+	status service.WarmUpStatus
+}
+
+func (f *fakeWarmUp) WarmUpStatus() service.WarmUpStatus {
+	return f.status
+}
+
+func TestReadyHandler(t *testing.T) {
+	warmUp := &fakeWarmUp{status: service.WarmUpStatus{State: service.WarmUpRunning, Strategy: service.WarmUpAll, Loaded: 1500}}
+	handler := readyHandler(warmUp)
+
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+	var got service.WarmUpStatus
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+	assert.Equal(t, warmUp.status, got)
+
+	warmUp.status.State = service.WarmUpReady
+	rec = httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
@@ -0,0 +1,24 @@
+package server
+
+import (
+	"log/slog"
+	"net/http"
+	"sync"
+
+	"github.com/Egor-Pomidor-pdf/order-service/api"
+)
+
+var openAPISpec = sync.OnceValues(api.JSON)
+
+// openAPIHandler serves the API specification, the viewer at /docs.html
+// renders it.
+func openAPIHandler(w http.ResponseWriter, _ *http.Request) {
+	spec, err := openAPISpec()
+	if err != nil {
+		slog.Error("failed to load OpenAPI specification", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(spec)
+}
@@ -4,24 +4,75 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/Egor-Pomidor-pdf/order-service/internal/auth"
 	"github.com/Egor-Pomidor-pdf/order-service/internal/config"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/export"
 	"github.com/Egor-Pomidor-pdf/order-service/internal/order/handler"
 	"github.com/Egor-Pomidor-pdf/order-service/internal/order/service"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/redact"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/stats"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-func NewServer(cfg config.ServerConfig, orderService *service.OrderService) *http.Server {
+// OrderService is what the HTTP API needs from the order service.
+type OrderService interface {
+	service.OrderServiceInterface
+	WarmUpReporter
+	handler.Watcher
+}
+
+func NewServer(cfg config.ServerConfig, orderService OrderService, replayer handler.Replayer, statsStore stats.Store, exporter *export.Exporter, authenticator auth.Authenticator) *http.Server {
 	router := chi.NewRouter()
 	router.Use(middleware.RequestID)
 	router.Use(middleware.Logger)
 	router.Use(middleware.Recoverer)
-	router.Use(middleware.URLFormat)
 
-	orderHandler := handler.NewOrderHandler(orderService)
+	orderHandler := handler.NewOrderHandler(orderService).WithRedaction(redact.NewPolicy(cfg.Redaction))
+
+	adminHandler := handler.NewAdminHandler(replayer)
+
+	statsHandler := stats.NewHandler(statsStore)
+
+	exportHandler := export.NewHandler(exporter)
+
+	streamHandler := handler.NewStreamHandler(orderService, cfg.Stream)
+
+	router.Group(func(r chi.Router) {
+		r.Use(auth.Middleware(authenticator))
+		r.Use(auth.Audit)
+
+		r.With(auth.RequireRole(auth.RoleReader)).Get("/order/{order_uid}", orderHandler.GetOrderHandler)
+		r.With(auth.RequireRole(auth.RoleReader)).Get("/orders/stream", streamHandler.StreamOrdersHandler)
+
+		r.Route("/stats", func(r chi.Router) {
+			// /stats/revenue.csv; для всего роутера отрезал бы точку в order_uid и /openapi.json
+			r.Use(middleware.URLFormat)
+			r.Use(auth.RequireRole(auth.RoleReader))
+			r.Get("/revenue", statsHandler.RevenueHandler)
+			r.Get("/brands", statsHandler.BrandsHandler)
+			r.Get("/delivery-cost", statsHandler.DeliveryCostHandler)
+			r.Get("/regions", statsHandler.RegionsHandler)
+			r.With(auth.RequireRole(auth.RoleAdmin)).Post("/refresh", statsHandler.RefreshHandler)
+		})
 
-	router.Get("/order/{order_uid}", orderHandler.GetOrderHandler)
+		r.Group(func(r chi.Router) {
+			r.Use(auth.RequireRole(auth.RoleAdmin))
+			r.Post("/orders", orderHandler.CreateOrderHandler)
+			r.Get("/orders/export", exportHandler.ExportHandler)
+			r.Put("/orders/{order_uid}", orderHandler.UpdateOrderHandler)
+			r.Delete("/orders/{order_uid}", orderHandler.DeleteOrderHandler)
+			r.Post("/admin/replay", adminHandler.StartReplayHandler)
+			r.Get("/admin/replay/{job_id}", adminHandler.GetReplayHandler)
+			r.Post("/admin/customers/erase", orderHandler.EraseCustomerHandler)
+		})
+	})
 
+	router.Method(http.MethodGet, "/metrics", promhttp.Handler())
+	router.Get("/openapi.json", openAPIHandler)
+	router.Get("/healthz", healthHandler)
+	router.Get("/readyz", readyHandler(orderService))
 	router.Handle("/*", http.FileServer(http.Dir("./web")))
 
 	srv := &http.Server{
@@ -31,6 +82,8 @@ func NewServer(cfg config.ServerConfig, orderService *service.OrderService) *htt
 		WriteTimeout: 10 * time.Second,
 		IdleTimeout: 10 * time.Second, 
 	}
+	// запущенные replay не переживают остановку сервера
+	srv.RegisterOnShutdown(adminHandler.Close)
 		return srv
 		
 }	
@@ -0,0 +1,87 @@
+package server
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Egor-Pomidor-pdf/order-service/internal/config"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order"
+	mock_service "github.com/Egor-Pomidor-pdf/order-service/internal/order/service/mocks"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order/watch"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeOrderService struct {
+	*mock_service.MockOrderServiceInterface
+	fakeWarmUp
+	*watch.Hub
+}
+
+func (f *fakeOrderService) Watch(buffer int, match func(*order.Order) bool) *watch.Subscription {
+	return f.Subscribe(buffer, match)
+}
+
+func (f *fakeOrderService) WatchAfter(seq uint64, buffer int, match func(*order.Order) bool) (*watch.Subscription, bool) {
+	return f.SubscribeAfter(seq, buffer, match)
+}
+
+func TestNewServer_GetOrder(t *testing.T) {
+	orders := mock_service.NewMockOrderServiceInterface(gomock.NewController(t))
+	orders.EXPECT().GetOrder(gomock.Any(), "a1").Return(&order.Order{OrderUID: "a1"}, nil)
+
+	srv := NewServer(config.ServerConfig{}, &fakeOrderService{MockOrderServiceInterface: orders}, nil, nil, nil, nil)
+
+	rec := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/order/a1", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"order_uid":"a1"`)
+
+	rec = httptest.NewRecorder()
+	srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestNewServer_URLFormat(t *testing.T) {
+	orders := mock_service.NewMockOrderServiceInterface(gomock.NewController(t))
+	orders.EXPECT().GetOrder(gomock.Any(), "a1.b2").Return(&order.Order{OrderUID: "a1.b2"}, nil)
+
+	srv := NewServer(config.ServerConfig{}, &fakeOrderService{MockOrderServiceInterface: orders}, nil, fakeStats{}, nil, nil)
+
+	// точка в order_uid не считается расширением
+	rec := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/order/a1.b2", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = httptest.NewRecorder()
+	srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/stats/revenue.csv", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", rec.Header().Get("Content-Type"))
+}
+
+func TestNewServer_OrderStream(t *testing.T) {
+	hub := watch.NewHub(0)
+	srv := NewServer(config.ServerConfig{}, &fakeOrderService{Hub: hub}, nil, nil, nil, nil)
+	ts := httptest.NewServer(srv.Handler)
+	defer ts.Close()
+
+	// события доходят до клиента сразу, через все middleware роутера
+	resp, err := http.Get(ts.URL + "/orders/stream")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	lines := bufio.NewScanner(resp.Body)
+	require.True(t, lines.Scan())
+	assert.Equal(t, ": connected", lines.Text())
+	lines.Scan()
+
+	hub.Publish(order.Order{OrderUID: "a1"})
+	for range 3 {
+		require.True(t, lines.Scan())
+	}
+	assert.Contains(t, lines.Text(), `"order_uid":"a1"`)
+}
@@ -0,0 +1,188 @@
+package stats
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+const (
+	defaultRange = 30 * 24 * time.Hour
+	defaultLimit = 10
+	maxLimit     = 1000
+)
+
+// Handler serves /stats. Every endpoint accepts from and to (RFC 3339 or
+// YYYY-MM-DD, the last 30 days by default) and answers JSON, or CSV for
+// ?format=csv, a .csv suffix or Accept: text/csv.
+type Handler struct {
+	store Store
+	now   func() time.Time
+}
+
+func NewHandler(store Store) *Handler {
+	return &Handler{store: store, now: time.Now}
+}
+
+// RevenueHandler: GET /stats/revenue?group=day|week|month&currency=RUB
+func (h *Handler) RevenueHandler(w http.ResponseWriter, r *http.Request) {
+	q, ok := h.query(w, r)
+	if !ok {
+		return
+	}
+	rows, err := h.store.Revenue(r.Context(), q)
+	writeRows(w, r, rows, err)
+}
+
+// BrandsHandler: GET /stats/brands?limit=10
+func (h *Handler) BrandsHandler(w http.ResponseWriter, r *http.Request) {
+	q, ok := h.query(w, r)
+	if !ok {
+		return
+	}
+	rows, err := h.store.TopBrands(r.Context(), q)
+	writeRows(w, r, rows, err)
+}
+
+// DeliveryCostHandler: GET /stats/delivery-cost
+func (h *Handler) DeliveryCostHandler(w http.ResponseWriter, r *http.Request) {
+	q, ok := h.query(w, r)
+	if !ok {
+		return
+	}
+	rows, err := h.store.DeliveryCost(r.Context(), q)
+	writeRows(w, r, rows, err)
+}
+
+// RegionsHandler: GET /stats/regions
+func (h *Handler) RegionsHandler(w http.ResponseWriter, r *http.Request) {
+	q, ok := h.query(w, r)
+	if !ok {
+		return
+	}
+	rows, err := h.store.Regions(r.Context(), q)
+	writeRows(w, r, rows, err)
+}
+
+// RefreshHandler: POST /stats/refresh recomputes the materialized views.
+func (h *Handler) RefreshHandler(w http.ResponseWriter, r *http.Request) {
+	if err := h.store.Refresh(r.Context()); err != nil {
+		slog.Error("failed to refresh stats views", "error", err)
+		writeError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) query(w http.ResponseWriter, r *http.Request) (Query, bool) {
+	q, err := parseQuery(r, h.now())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return q, false
+	}
+	return q, true
+}
+
+func parseQuery(r *http.Request, now time.Time) (Query, error) {
+	values := r.URL.Query()
+	q := Query{
+		To:       now,
+		Group:    GroupDay,
+		Currency: strings.ToUpper(values.Get("currency")),
+		Limit:    defaultLimit,
+	}
+
+	var err error
+	if v := values.Get("to"); v != "" {
+		if q.To, err = parseTime(v); err != nil {
+			return q, fmt.Errorf("invalid to: %w", err)
+		}
+	}
+	q.From = q.To.Add(-defaultRange)
+	if v := values.Get("from"); v != "" {
+		if q.From, err = parseTime(v); err != nil {
+			return q, fmt.Errorf("invalid from: %w", err)
+		}
+	}
+	if !q.From.Before(q.To) {
+		return q, errors.New("from must be before to")
+	}
+
+	if v := values.Get("group"); v != "" {
+		q.Group = v
+	}
+	if q.Group != GroupDay && q.Group != GroupWeek && q.Group != GroupMonth {
+		return q, ErrInvalidGroup
+	}
+
+	if v := values.Get("limit"); v != "" {
+		if q.Limit, err = strconv.Atoi(v); err != nil || q.Limit < 1 || q.Limit > maxLimit {
+			return q, fmt.Errorf("limit must be between 1 and %d", maxLimit)
+		}
+	}
+	return q, nil
+}
+
+func parseTime(v string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, v); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
+
+type row interface {
+	header() []string
+	record() []string
+}
+
+func writeRows[T row](w http.ResponseWriter, r *http.Request, rows []T, err error) {
+	if err != nil {
+		slog.Error("failed to query stats", "error", err, "path", r.URL.Path)
+		writeError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	if !wantsCSV(r) {
+		if rows == nil {
+			rows = []T{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(rows)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	cw := csv.NewWriter(w)
+	var zero T
+	cw.Write(zero.header())
+	for _, row := range rows {
+		cw.Write(row.record())
+	}
+	cw.Flush()
+}
+
+func wantsCSV(r *http.Request) bool {
+	if format, _ := r.Context().Value(middleware.URLFormatCtxKey).(string); format != "" {
+		return format == "csv"
+	}
+	if format := r.URL.Query().Get("format"); format != "" {
+		return format == "csv"
+	}
+	return strings.Contains(r.Header.Get("Accept"), "text/csv")
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
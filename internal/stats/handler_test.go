@@ -0,0 +1,165 @@
+package stats
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var now = time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+
+type fakeStore struct {
+	query Query
+	err   error
+}
+
+func (s *fakeStore) Revenue(_ context.Context, q Query) ([]RevenueRow, error) {
+	s.query = q
+	return []RevenueRow{
+		{Period: time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC), Currency: "RUB", Revenue: 1817, Orders: 1},
+		{Period: time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC), Currency: "USD", Revenue: 300, Orders: 2},
+	}, s.err
+}
+
+func (s *fakeStore) TopBrands(_ context.Context, q Query) ([]BrandRow, error) {
+	s.query = q
+	return []BrandRow{{Brand: "Vivienne Sabo", Items: 3, Revenue: 951}}, s.err
+}
+
+func (s *fakeStore) DeliveryCost(_ context.Context, q Query) ([]DeliveryCostRow, error) {
+	s.query = q
+	return []DeliveryCostRow{{DeliveryService: "meest", Orders: 3, AvgDeliveryCost: 1000.0 / 3}}, s.err
+}
+
+func (s *fakeStore) Regions(_ context.Context, q Query) ([]RegionRow, error) {
+	s.query = q
+	return []RegionRow{{Region: "Kraiot", Orders: 4}}, s.err
+}
+
+func (s *fakeStore) Refresh(context.Context) error {
+	return s.err
+}
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		want    Query
+		wantErr bool
+	}{
+		{
+			name: "defaults",
+			url:  "/stats/revenue",
+			want: Query{From: now.Add(-defaultRange), To: now, Group: GroupDay, Limit: defaultLimit},
+		},
+		{
+			name: "dates and grouping",
+			url:  "/stats/revenue?from=2025-01-01&to=2025-04-01T00:00:00Z&group=month&currency=usd&limit=5",
+			want: Query{
+				From:     time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+				To:       time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC),
+				Group:    GroupMonth,
+				Currency: "USD",
+				Limit:    5,
+			},
+		},
+		{name: "bad group", url: "/stats/revenue?group=hour", wantErr: true},
+		{name: "bad date", url: "/stats/revenue?from=yesterday", wantErr: true},
+		{name: "empty range", url: "/stats/revenue?from=2025-02-01&to=2025-01-01", wantErr: true},
+		{name: "limit too big", url: "/stats/brands?limit=100000", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			q, err := parseQuery(httptest.NewRequest("GET", test.url, nil), now)
+			if test.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.want, q)
+		})
+	}
+}
+
+func TestHandler_Formats(t *testing.T) {
+	tests := []struct {
+		name         string
+		url          string
+		accept       string
+		expectedType string
+		expectedBody string
+	}{
+		{
+			name:         "json",
+			url:          "/stats/revenue",
+			expectedType: "application/json",
+			expectedBody: `[{"period":"2025-06-01T00:00:00Z","currency":"RUB","revenue":1817,"orders":1},` +
+				`{"period":"2025-06-02T00:00:00Z","currency":"USD","revenue":300,"orders":2}]` + "\n",
+		},
+		{
+			name:         "csv param",
+			url:          "/stats/revenue?format=csv",
+			expectedType: "text/csv; charset=utf-8",
+			expectedBody: "period,currency,revenue,orders\n2025-06-01,RUB,1817,1\n2025-06-02,USD,300,2\n",
+		},
+		{
+			name:         "csv accept",
+			url:          "/stats/revenue",
+			accept:       "text/csv",
+			expectedType: "text/csv; charset=utf-8",
+			expectedBody: "period,currency,revenue,orders\n2025-06-01,RUB,1817,1\n2025-06-02,USD,300,2\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := NewHandler(&fakeStore{})
+			h.now = func() time.Time { return now }
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", test.url, nil)
+			if test.accept != "" {
+				r.Header.Set("Accept", test.accept)
+			}
+			h.RevenueHandler(w, r)
+
+			assert.Equal(t, 200, w.Code)
+			assert.Equal(t, test.expectedType, w.Header().Get("Content-Type"))
+			assert.Equal(t, test.expectedBody, w.Body.String())
+		})
+	}
+}
+
+func TestHandler_DeliveryCostCSV(t *testing.T) {
+	h := NewHandler(&fakeStore{})
+
+	w := httptest.NewRecorder()
+	h.DeliveryCostHandler(w, httptest.NewRequest("GET", "/stats/delivery-cost?format=csv", nil))
+
+	assert.Equal(t, "delivery_service,orders,avg_delivery_cost\nmeest,3,333.33\n", w.Body.String())
+}
+
+func TestHandler_Errors(t *testing.T) {
+	store := &fakeStore{}
+	h := NewHandler(store)
+
+	w := httptest.NewRecorder()
+	h.BrandsHandler(w, httptest.NewRequest("GET", "/stats/brands?limit=0", nil))
+	assert.Equal(t, 400, w.Code)
+
+	store.err = errors.New("connection refused")
+	w = httptest.NewRecorder()
+	h.RegionsHandler(w, httptest.NewRequest("GET", "/stats/regions", nil))
+	assert.Equal(t, 500, w.Code)
+	assert.Equal(t, `{"error":"Internal server error"}`+"\n", w.Body.String())
+
+	w = httptest.NewRecorder()
+	h.RefreshHandler(w, httptest.NewRequest("POST", "/stats/refresh", nil))
+	assert.Equal(t, 500, w.Code)
+}
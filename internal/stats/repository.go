@@ -0,0 +1,263 @@
+package stats
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Repository aggregates orders with SQL. With several shard databases each
+// shard is queried and the partial results are merged, so every query
+// returns sums and counts rather than averages.
+type Repository struct {
+	dbs      []*sqlx.DB
+	useViews bool
+}
+
+type Option func(*Repository)
+
+// WithViews reads revenue and brands from the daily materialized views of
+// migration 007 instead of the order tables. The views are only as fresh as
+// the last Refresh. They hold whole days, so a range that does not start and
+// end at midnight is still read from the order tables.
+func WithViews() Option {
+	return func(r *Repository) {
+		r.useViews = true
+	}
+}
+
+func NewRepository(dbs []*sqlx.DB, opts ...Option) *Repository {
+	r := &Repository{dbs: dbs}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// viewsFor reports whether q can be answered from the daily views.
+func (r *Repository) viewsFor(q Query) bool {
+	return r.useViews && midnight(q.From) && midnight(q.To)
+}
+
+func midnight(t time.Time) bool {
+	h, m, s := t.Clock()
+	return h == 0 && m == 0 && s == 0 && t.Nanosecond() == 0
+}
+
+func (r *Repository) Revenue(ctx context.Context, q Query) ([]RevenueRow, error) {
+	const op = "repository.stats.Revenue"
+
+	query := `
+		SELECT date_trunc($1, o.date_created) AS period, COALESCE(p.currency, '') AS currency,
+		       COALESCE(SUM(p.amount), 0) AS revenue, COUNT(*) AS orders
+		FROM orders o JOIN payments p ON p.order_uid = o.order_uid
+		WHERE o.date_created >= $2 AND o.date_created < $3 AND ($4 = '' OR p.currency = $4)
+		GROUP BY 1, 2`
+	if r.viewsFor(q) {
+		query = `
+			SELECT date_trunc($1, day) AS period, currency,
+			       SUM(revenue)::BIGINT AS revenue, SUM(orders)::BIGINT AS orders
+			FROM stats_daily_revenue
+			WHERE day >= $2 AND day < $3 AND ($4 = '' OR currency = $4)
+			GROUP BY 1, 2`
+	}
+
+	merged := make(map[[2]string]*RevenueRow)
+	for _, db := range r.dbs {
+		var rows []RevenueRow
+		if err := db.SelectContext(ctx, &rows, query, q.Group, q.From, q.To, q.Currency); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		for _, row := range rows {
+			key := [2]string{row.Period.String(), row.Currency}
+			if m, ok := merged[key]; ok {
+				m.Revenue += row.Revenue
+				m.Orders += row.Orders
+			} else {
+				merged[key] = &row
+			}
+		}
+	}
+
+	result := make([]RevenueRow, 0, len(merged))
+	for _, row := range merged {
+		result = append(result, *row)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].Period.Equal(result[j].Period) {
+			return result[i].Period.Before(result[j].Period)
+		}
+		return result[i].Currency < result[j].Currency
+	})
+	return result, nil
+}
+
+// TopBrands returns up to q.Limit brands by items sold.
+func (r *Repository) TopBrands(ctx context.Context, q Query) ([]BrandRow, error) {
+	const op = "repository.stats.TopBrands"
+
+	// items секционирована по date_created, как и orders
+	query := `
+		SELECT COALESCE(brand, '') AS brand, COUNT(*) AS items, COALESCE(SUM(total_price), 0) AS revenue
+		FROM items
+		WHERE date_created >= $1 AND date_created < $2
+		GROUP BY 1`
+	if r.viewsFor(q) {
+		query = `
+			SELECT brand, SUM(items)::BIGINT AS items, SUM(revenue)::BIGINT AS revenue
+			FROM stats_daily_brands
+			WHERE day >= $1 AND day < $2
+			GROUP BY 1`
+	}
+
+	merged := make(map[string]*BrandRow)
+	for _, db := range r.dbs {
+		var rows []BrandRow
+		if err := db.SelectContext(ctx, &rows, query, q.From, q.To); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		for _, row := range rows {
+			if m, ok := merged[row.Brand]; ok {
+				m.Items += row.Items
+				m.Revenue += row.Revenue
+			} else {
+				merged[row.Brand] = &row
+			}
+		}
+	}
+
+	result := make([]BrandRow, 0, len(merged))
+	for _, row := range merged {
+		result = append(result, *row)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Items != result[j].Items {
+			return result[i].Items > result[j].Items
+		}
+		return result[i].Brand < result[j].Brand
+	})
+	if q.Limit > 0 && len(result) > q.Limit {
+		result = result[:q.Limit]
+	}
+	return result, nil
+}
+
+func (r *Repository) DeliveryCost(ctx context.Context, q Query) ([]DeliveryCostRow, error) {
+	const op = "repository.stats.DeliveryCost"
+
+	type partial struct {
+		DeliveryService string `db:"delivery_service"`
+		Orders          int64  `db:"orders"`
+		Total           int64  `db:"total"`
+	}
+
+	merged := make(map[string]*partial)
+	for _, db := range r.dbs {
+		var rows []partial
+		err := db.SelectContext(ctx, &rows, `
+			SELECT COALESCE(o.delivery_service, '') AS delivery_service,
+			       COUNT(*) AS orders, COALESCE(SUM(p.delivery_cost), 0) AS total
+			FROM orders o JOIN payments p ON p.order_uid = o.order_uid
+			WHERE o.date_created >= $1 AND o.date_created < $2
+			GROUP BY 1`, q.From, q.To)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		for _, row := range rows {
+			if m, ok := merged[row.DeliveryService]; ok {
+				m.Orders += row.Orders
+				m.Total += row.Total
+			} else {
+				merged[row.DeliveryService] = &row
+			}
+		}
+	}
+
+	result := make([]DeliveryCostRow, 0, len(merged))
+	for _, row := range merged {
+		result = append(result, DeliveryCostRow{
+			DeliveryService: row.DeliveryService,
+			Orders:          row.Orders,
+			AvgDeliveryCost: float64(row.Total) / float64(row.Orders),
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].DeliveryService < result[j].DeliveryService
+	})
+	return result, nil
+}
+
+func (r *Repository) Regions(ctx context.Context, q Query) ([]RegionRow, error) {
+	const op = "repository.stats.Regions"
+
+	merged := make(map[string]*RegionRow)
+	for _, db := range r.dbs {
+		var rows []RegionRow
+		err := db.SelectContext(ctx, &rows, `
+			SELECT COALESCE(d.region, '') AS region, COUNT(*) AS orders
+			FROM orders o JOIN deliveries d ON d.order_uid = o.order_uid
+			WHERE o.date_created >= $1 AND o.date_created < $2
+			GROUP BY 1`, q.From, q.To)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		for _, row := range rows {
+			if m, ok := merged[row.Region]; ok {
+				m.Orders += row.Orders
+			} else {
+				merged[row.Region] = &row
+			}
+		}
+	}
+
+	result := make([]RegionRow, 0, len(merged))
+	for _, row := range merged {
+		result = append(result, *row)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Orders != result[j].Orders {
+			return result[i].Orders > result[j].Orders
+		}
+		return result[i].Region < result[j].Region
+	})
+	return result, nil
+}
+
+// Refresh recomputes the materialized views without blocking readers.
+func (r *Repository) Refresh(ctx context.Context) error {
+	const op = "repository.stats.Refresh"
+
+	for _, db := range r.dbs {
+		for _, view := range []string{"stats_daily_revenue", "stats_daily_brands"} {
+			if _, err := db.ExecContext(ctx, `REFRESH MATERIALIZED VIEW CONCURRENTLY `+view); err != nil {
+				return fmt.Errorf("%s: %s: %w", op, view, err)
+			}
+		}
+	}
+	return nil
+}
+
+// RunRefresh refreshes the views every interval until ctx is cancelled.
+func (r *Repository) RunRefresh(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		start := time.Now()
+		if err := r.Refresh(ctx); err != nil {
+			slog.Error("failed to refresh stats views", "error", err)
+			continue
+		}
+		slog.Debug("stats views refreshed", "took", time.Since(start))
+	}
+}
@@ -0,0 +1,65 @@
+package stats
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order/fixture"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order/repository"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/pgtest"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Тесты с настоящей БД пропускаются без TEST_POSTGRES_BIN, см. pgtest.
+func TestMain(m *testing.M) {
+	os.Exit(pgtest.Main(m))
+}
+
+func TestRepository_ViewsFor(t *testing.T) {
+	day := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	views := NewRepository(nil, WithViews())
+
+	assert.True(t, views.viewsFor(Query{From: day, To: day.AddDate(0, 0, 7)}))
+	assert.False(t, views.viewsFor(Query{From: day.Add(time.Hour), To: day.AddDate(0, 0, 7)}))
+	assert.False(t, views.viewsFor(Query{From: day, To: day.Add(time.Nanosecond)}))
+	assert.False(t, NewRepository(nil).viewsFor(Query{From: day, To: day.AddDate(0, 0, 1)}))
+}
+
+func TestRepository_ViewsMatchLive(t *testing.T) {
+	db := pgtest.NewDB(t)
+	orders := repository.NewOrderRepository(db)
+	gen := fixture.New(fixture.Options{Seed: 42})
+
+	day := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -1)
+	for _, offset := range []time.Duration{time.Hour, 13 * time.Hour, 23 * time.Hour} {
+		o := gen.Order()
+		o.DateCreated = day.Add(offset)
+		require.NoError(t, orders.SaveOrder(t.Context(), &o))
+	}
+
+	live := NewRepository([]*sqlx.DB{db})
+	views := NewRepository([]*sqlx.DB{db}, WithViews())
+	require.NoError(t, views.Refresh(t.Context()))
+
+	for name, q := range map[string]Query{
+		"whole days":  {From: day, To: day.AddDate(0, 0, 1), Group: GroupDay},
+		"partial day": {From: day.Add(12 * time.Hour), To: day.AddDate(0, 0, 1), Group: GroupDay},
+	} {
+		t.Run(name, func(t *testing.T) {
+			want, err := live.Revenue(t.Context(), q)
+			require.NoError(t, err)
+			got, err := views.Revenue(t.Context(), q)
+			require.NoError(t, err)
+			assert.Equal(t, want, got)
+
+			wantBrands, err := live.TopBrands(t.Context(), q)
+			require.NoError(t, err)
+			gotBrands, err := views.TopBrands(t.Context(), q)
+			require.NoError(t, err)
+			assert.Equal(t, wantBrands, gotBrands)
+		})
+	}
+}
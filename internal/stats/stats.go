@@ -0,0 +1,92 @@
+package stats
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// Periods revenue can be grouped by, passed to date_trunc.
+const (
+	GroupDay   = "day"
+	GroupWeek  = "week"
+	GroupMonth = "month"
+)
+
+var ErrInvalidGroup = errors.New("group must be day, week or month")
+
+// Query selects orders with From <= date_created < To.
+type Query struct {
+	From time.Time
+	To   time.Time
+	// Group is the revenue period.
+	Group string
+	// Currency limits revenue to one currency when set.
+	Currency string
+	// Limit caps the number of brands.
+	Limit int
+}
+
+// Store runs the aggregate queries. Implemented by Repository.
+type Store interface {
+	Revenue(ctx context.Context, q Query) ([]RevenueRow, error)
+	TopBrands(ctx context.Context, q Query) ([]BrandRow, error)
+	DeliveryCost(ctx context.Context, q Query) ([]DeliveryCostRow, error)
+	Regions(ctx context.Context, q Query) ([]RegionRow, error)
+	Refresh(ctx context.Context) error
+}
+
+// RevenueRow is the payment amount of orders created in Period.
+type RevenueRow struct {
+	Period   time.Time `json:"period" db:"period"`
+	Currency string    `json:"currency" db:"currency"`
+	Revenue  int64     `json:"revenue" db:"revenue"`
+	Orders   int64     `json:"orders" db:"orders"`
+}
+
+// BrandRow counts sold items of a brand.
+type BrandRow struct {
+	Brand   string `json:"brand" db:"brand"`
+	Items   int64  `json:"items" db:"items"`
+	Revenue int64  `json:"revenue" db:"revenue"`
+}
+
+type DeliveryCostRow struct {
+	DeliveryService string  `json:"delivery_service" db:"delivery_service"`
+	Orders          int64   `json:"orders" db:"orders"`
+	AvgDeliveryCost float64 `json:"avg_delivery_cost"`
+}
+
+type RegionRow struct {
+	Region string `json:"region" db:"region"`
+	Orders int64  `json:"orders" db:"orders"`
+}
+
+// CSV headers and records, in the order of the JSON fields.
+
+func (RevenueRow) header() []string { return []string{"period", "currency", "revenue", "orders"} }
+func (r RevenueRow) record() []string {
+	return []string{r.Period.Format(time.DateOnly), r.Currency, itoa(r.Revenue), itoa(r.Orders)}
+}
+
+func (BrandRow) header() []string { return []string{"brand", "items", "revenue"} }
+func (r BrandRow) record() []string {
+	return []string{r.Brand, itoa(r.Items), itoa(r.Revenue)}
+}
+
+func (DeliveryCostRow) header() []string {
+	return []string{"delivery_service", "orders", "avg_delivery_cost"}
+}
+func (r DeliveryCostRow) record() []string {
+	return []string{r.DeliveryService, itoa(r.Orders), strconv.FormatFloat(r.AvgDeliveryCost, 'f', 2, 64)}
+}
+
+func (RegionRow) header() []string { return []string{"region", "orders"} }
+func (r RegionRow) record() []string {
+	return []string{r.Region, itoa(r.Orders)}
+}
+
+func itoa(n int64) string {
+	return strconv.FormatInt(n, 10)
+}
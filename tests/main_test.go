@@ -1,3 +1,7 @@
+//go:build e2e
+
+// Проверка на поднятом docker-compose окружении: make test-e2e.
+
 package tests
 
 import (
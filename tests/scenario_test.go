@@ -0,0 +1,289 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	orderv1 "github.com/Egor-Pomidor-pdf/order-service/api/proto/order/v1"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/apptest"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/order/fixture"
+	"github.com/Egor-Pomidor-pdf/order-service/internal/pgtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Сценарии поднимают сервис целиком в процессе теста, нужен только
+// TEST_POSTGRES_BIN (см. pgtest). Kafka заменена на kafka.MemorySource.
+func TestMain(m *testing.M) {
+	os.Exit(pgtest.Main(m))
+}
+
+func newOrders(t *testing.T) *fixture.Generator {
+	t.Helper()
+	// заказы попадают в секции, созданные миграцией
+	return fixture.New(fixture.Options{Seed: 7, MaxItems: 3, MaxAge: time.Hour})
+}
+
+func assertOrder(t *testing.T, want order.Order, got *order.Order) {
+	t.Helper()
+	require.NotNil(t, got)
+	assert.Equal(t, want.OrderUID, got.OrderUID)
+	assert.Equal(t, want.TrackNumber, got.TrackNumber)
+	assert.Equal(t, want.Payment.Transaction, got.Payment.Transaction)
+	assert.Len(t, got.Items, len(want.Items))
+}
+
+func TestScenario_OrderFlow(t *testing.T) {
+	h := apptest.Start(t)
+	gen := newOrders(t)
+
+	orders := []order.Order{gen.Order(), gen.Order(), gen.Order()}
+	for _, o := range orders {
+		h.Publish(o)
+	}
+	for _, o := range orders {
+		assertOrder(t, o, h.WaitOrder(o.OrderUID))
+	}
+
+	got, status := h.GetOrder("missing")
+	assert.Nil(t, got)
+	assert.Equal(t, http.StatusNotFound, status)
+	assert.Empty(t, h.DLQ.All())
+}
+
+func TestScenario_RetryOnDatabaseError(t *testing.T) {
+	h := apptest.Start(t)
+	o := newOrders(t).Order()
+
+	restore := h.FailWrites(3)
+	defer restore()
+	offset := h.Publish(o)
+
+	assertOrder(t, o, h.WaitOrder(o.OrderUID))
+	h.WaitCommitted(offset)
+	assert.Equal(t, 4, h.WriteAttempts(), "three failed attempts and a successful one")
+	assert.Empty(t, h.DLQ.All(), "database errors are retried, not dead-lettered")
+}
+
+func TestScenario_DeadLetters(t *testing.T) {
+	h := apptest.Start(t)
+	gen := newOrders(t)
+
+	h.PublishRaw([]byte("broken"), []byte(`{"order_uid": `))
+	invalid := gen.Order()
+	invalid.Locale = "de"
+	h.Publish(invalid)
+	valid := gen.Order()
+	offset := h.Publish(valid)
+
+	// сообщения после невалидных не блокируются
+	assertOrder(t, valid, h.WaitOrder(valid.OrderUID))
+	h.WaitCommitted(offset)
+
+	letters := h.DLQ.All()
+	require.Len(t, letters, 2)
+	assert.Contains(t, letters[0].Reason, "INVALID_JSON")
+	assert.Contains(t, letters[1].Reason, "VALIDATION_ERROR")
+	assert.Equal(t, []byte(invalid.OrderUID), letters[1].Message.Key)
+	assert.NotContains(t, string(letters[1].Message.Value), invalid.Delivery.Phone, "PII is masked in the DLQ")
+
+	got, status := h.GetOrder(invalid.OrderUID)
+	assert.Nil(t, got)
+	assert.Equal(t, http.StatusNotFound, status)
+}
+
+func TestScenario_Duplicate(t *testing.T) {
+	h := apptest.Start(t)
+	gen := newOrders(t)
+
+	o := gen.Order()
+	h.Publish(o)
+	dup := gen.Order()
+	dup.OrderUID = o.OrderUID
+	offset := h.Publish(dup)
+	next := gen.Order()
+	h.Publish(next)
+
+	// повтор не блокирует партицию и не перезаписывает заказ
+	assertOrder(t, next, h.WaitOrder(next.OrderUID))
+	h.WaitCommitted(offset)
+	assertOrder(t, o, h.WaitOrder(o.OrderUID))
+	assert.Empty(t, h.DLQ.All())
+}
+
+func TestScenario_ShutdownDuringRetry(t *testing.T) {
+	h := apptest.Start(t)
+	o := newOrders(t).Order()
+
+	restore := h.FailWrites(-1)
+	offset := h.Publish(o)
+	h.WaitWriteAttempts(2)
+
+	// остановка прерывает повторы, сообщение остается незакоммиченным
+	stopped := time.Now()
+	h.Stop()
+	assert.Less(t, time.Since(stopped), apptest.Timeout)
+	assert.LessOrEqual(t, h.Source.Committed(), offset)
+
+	// после рестарта consumer читает его снова
+	restore()
+	h.Restart()
+	assertOrder(t, o, h.WaitOrder(o.OrderUID))
+	h.WaitCommitted(offset)
+	assert.Empty(t, h.DLQ.All())
+}
+
+// TestScenario_RoundTrip is a property test over random orders with unicode
+// text and edge values: whatever is accepted from Kafka is returned by the
+// API unchanged after a trip through Postgres.
+func TestScenario_RoundTrip(t *testing.T) {
+	h := apptest.Start(t)
+	gen := fixture.New(fixture.Options{Seed: 11, MaxItems: 20, MaxAge: time.Hour, Arbitrary: true})
+
+	orders := make([]order.Order, 50)
+	var offset int64
+	for i := range orders {
+		orders[i] = gen.Order()
+		offset = h.Publish(orders[i])
+	}
+	h.WaitCommitted(offset)
+	// второй экземпляр читает из базы, а не из кэша, заполненного consumer
+	h.Restart()
+
+	for _, want := range orders {
+		got := h.WaitOrder(want.OrderUID)
+		require.NotNil(t, got.IngestedAt)
+		got.IngestedAt = nil
+		assert.Equal(t, want, *got)
+	}
+}
+
+func TestScenario_GRPC(t *testing.T) {
+	h := apptest.Start(t)
+	client := h.GRPC()
+	gen := newOrders(t)
+
+	watch, err := client.WatchOrders(context.Background(), &orderv1.WatchOrdersRequest{})
+	require.NoError(t, err)
+	_, err = watch.Header()
+	require.NoError(t, err)
+
+	orders := []order.Order{gen.Order(), gen.Order()}
+	for _, o := range orders {
+		h.Publish(o)
+	}
+	for _, o := range orders {
+		resp, err := watch.Recv()
+		require.NoError(t, err)
+		assert.Equal(t, o.OrderUID, resp.GetOrder().GetOrderUid())
+	}
+
+	got, err := client.GetOrder(context.Background(), &orderv1.GetOrderRequest{OrderUid: orders[0].OrderUID})
+	require.NoError(t, err)
+	assert.Equal(t, orders[0].Payment.Transaction, got.GetOrder().GetPayment().GetTransaction())
+	assert.Len(t, got.GetOrder().GetItems(), len(orders[0].Items))
+
+	found, err := client.SearchOrders(context.Background(), &orderv1.SearchOrdersRequest{TrackNumber: orders[1].TrackNumber})
+	require.NoError(t, err)
+	require.Len(t, found.GetOrders(), 1)
+	assert.Equal(t, orders[1].OrderUID, found.GetOrders()[0].GetOrderUid())
+
+	list, err := client.ListOrders(context.Background(), &orderv1.ListOrdersRequest{})
+	require.NoError(t, err)
+	listed := 0
+	for {
+		_, err := list.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		listed++
+	}
+	assert.Equal(t, len(orders), listed)
+
+	// остановка сервиса завершает подписку, а не обрывает соединение
+	h.Stop()
+	_, err = watch.Recv()
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+}
+
+// openStream connects to GET /orders/stream. A new stream starts with a
+// comment sent once the subscription is active, openStream waits for it.
+func openStream(t *testing.T, h *apptest.Harness, lastEventID string) *bufio.Scanner {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.URL("/orders/stream"), nil)
+	require.NoError(t, err)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	lines := bufio.NewScanner(resp.Body)
+	if lastEventID == "" {
+		require.True(t, lines.Scan())
+		require.Equal(t, ": connected", lines.Text())
+	}
+	return lines
+}
+
+// nextEvent returns the id, type and data of the next event.
+func nextEvent(t *testing.T, lines *bufio.Scanner) (id, event, data string) {
+	t.Helper()
+	for lines.Scan() {
+		line := lines.Text()
+		if line == "" && event != "" {
+			return id, event, data
+		}
+		if k, v, ok := strings.Cut(line, ": "); ok {
+			switch k {
+			case "id":
+				id = v
+			case "event":
+				event = v
+			case "data":
+				data = v
+			}
+		}
+	}
+	t.Fatal("stream ended")
+	return
+}
+
+func TestScenario_OrderStream(t *testing.T) {
+	h := apptest.Start(t)
+	gen := newOrders(t)
+
+	all := openStream(t, h, "")
+	orders := []order.Order{gen.Order(), gen.Order()}
+	for _, o := range orders {
+		h.Publish(o)
+	}
+
+	var lastID string
+	for _, o := range orders {
+		id, event, data := nextEvent(t, all)
+		assert.Equal(t, "order", event)
+		assert.Contains(t, data, `"order_uid":"`+o.OrderUID+`"`)
+		assert.NotEqual(t, lastID, id)
+		lastID = id
+	}
+
+	// номера событий после рестарта не совпадают с прежними, клиент получает reset
+	h.Restart()
+	_, event, _ := nextEvent(t, openStream(t, h, lastID))
+	assert.Equal(t, "reset", event)
+}